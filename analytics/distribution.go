@@ -0,0 +1,158 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Distribution scores and durations of the submissions of a test, kept sorted so it can be updated
+// one submission at a time. It must be locked while it is read or updated.
+type Distribution struct {
+	sync.Mutex
+
+	// Watermark creation time of the last added submission, newer submissions are the ones to add
+	Watermark time.Time
+
+	seen      map[string]bool
+	scores    []float64
+	durations []float64
+}
+
+// Stats summary statistics of values
+type Stats struct {
+	Count     int     `json:"count"`
+	Mean      float64 `json:"mean"`
+	Median    float64 `json:"median"`
+	StdDev    float64 `json:"std_dev"`
+	Min       float64 `json:"min"`
+	Max       float64 `json:"max"`
+	Histogram []Bin   `json:"histogram"`
+}
+
+// Bin bin of a histogram, From is included and To is excluded except for the last bin
+type Bin struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count int     `json:"count"`
+}
+
+// NewDistribution create an empty distribution
+func NewDistribution() *Distribution {
+	return &Distribution{seen: make(map[string]bool)}
+}
+
+// Add add a submission, submissions already added are ignored. duration is nil when the attempt is not finished.
+func (d *Distribution) Add(id string, score float64, duration *float64, createdAt time.Time) {
+	if d.seen[id] {
+		return
+	}
+	d.seen[id] = true
+
+	d.scores = insertSorted(d.scores, score)
+	if duration != nil {
+		d.durations = insertSorted(d.durations, *duration)
+	}
+	if createdAt.After(d.Watermark) {
+		d.Watermark = createdAt
+	}
+}
+
+// Len number of submissions added
+func (d *Distribution) Len() int {
+	return len(d.scores)
+}
+
+// Scores statistics of the scores, the histogram has bins of the same width between low and high
+func (d *Distribution) Scores(low float64, high float64, bins int) Stats {
+	return newStats(d.scores, low, high, bins)
+}
+
+// Durations statistics of the durations of the finished attempts, the histogram goes from the shortest to the longest
+func (d *Distribution) Durations(bins int) Stats {
+	if len(d.durations) == 0 {
+		return newStats(nil, 0, 0, bins)
+	}
+
+	return newStats(d.durations, d.durations[0], d.durations[len(d.durations)-1], bins)
+}
+
+func newStats(sorted []float64, low float64, high float64, bins int) Stats {
+	stats := Stats{Count: len(sorted), Histogram: []Bin{}}
+	if len(sorted) == 0 {
+		return stats
+	}
+
+	stats.Mean, stats.StdDev = meanStdDev(sorted)
+	stats.Min, stats.Max = sorted[0], sorted[len(sorted)-1]
+	if middle := len(sorted) / 2; len(sorted)%2 == 0 {
+		stats.Median = (sorted[middle-1] + sorted[middle]) / 2
+	} else {
+		stats.Median = sorted[middle]
+	}
+
+	//values outside of the range widen it so every value has a bin
+	low, high = math.Min(low, stats.Min), math.Max(high, stats.Max)
+	if bins < 1 || high == low {
+		bins = 1
+	}
+	width := (high - low) / float64(bins)
+	for i := 0; i < bins; i++ {
+		stats.Histogram = append(stats.Histogram, Bin{From: low + float64(i)*width, To: low + float64(i+1)*width})
+	}
+	for _, v := range sorted {
+		i := bins - 1
+		if width > 0 && v < high {
+			i = int(math.Min(float64(bins-1), (v-low)/width))
+		}
+		stats.Histogram[i].Count++
+	}
+
+	return stats
+}
+
+func insertSorted(values []float64, value float64) []float64 {
+	i := sort.SearchFloat64s(values, value)
+	values = append(values, 0)
+	copy(values[i+1:], values[i:])
+	values[i] = value
+
+	return values
+}
+
+// Cache distributions by key, shared by the requests of the server
+type Cache struct {
+	mu    sync.Mutex
+	items map[string]*Distribution
+}
+
+// NewCache create an empty cache
+func NewCache() *Cache {
+	return &Cache{items: make(map[string]*Distribution)}
+}
+
+// Get the distribution of key, an empty one is created when there is none
+func (c *Cache) Get(key string) *Distribution {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d, ok := c.items[key]
+	if !ok {
+		d = NewDistribution()
+		c.items[key] = d
+	}
+
+	return d
+}
+
+// Reset replace the distribution of key with an empty one, it is returned
+func (c *Cache) Reset(key string) *Distribution {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d := NewDistribution()
+	c.items[key] = d
+
+	return d
+}
@@ -0,0 +1,110 @@
+package analytics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDistributionAdd(t *testing.T) {
+	d := NewDistribution()
+	start := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	minutes := func(m float64) *float64 { return &m }
+
+	d.Add("a", 10, minutes(30), start.Add(2*time.Hour))
+	d.Add("b", 4, nil, start)
+	d.Add("c", 7, minutes(10), start.Add(time.Hour))
+	//the same submission read again after the watermark, with any value, is ignored
+	d.Add("a", 99, minutes(99), start.Add(3*time.Hour))
+
+	if d.Len() != 3 {
+		t.Errorf("%d submissions, want 3", d.Len())
+	}
+	if want := start.Add(2 * time.Hour); !d.Watermark.Equal(want) {
+		t.Errorf("watermark %s, want %s", d.Watermark, want)
+	}
+	if !reflect.DeepEqual(d.scores, []float64{4, 7, 10}) {
+		t.Errorf("scores %v, want 4, 7, 10", d.scores)
+	}
+	if durations := d.Durations(1); durations.Count != 2 || durations.Min != 10 || durations.Max != 30 {
+		t.Errorf("durations %+v, want 2 from 10 to 30", durations)
+	}
+}
+
+func TestDistributionMedian(t *testing.T) {
+	tests := []struct {
+		scores []float64
+		median float64
+	}{
+		{[]float64{5}, 5},
+		{[]float64{9, 1, 5}, 5},
+		{[]float64{8, 2}, 5},
+		{[]float64{1, 10, 2, 3}, 2.5},
+		{[]float64{3, 3, 3, 3, 3, 3}, 3},
+	}
+
+	for _, v := range tests {
+		d := NewDistribution()
+		for k, score := range v.scores {
+			d.Add(string(rune('a'+k)), score, nil, time.Time{})
+		}
+		if stats := d.Scores(0, 10, 1); stats.Median != v.median {
+			t.Errorf("median of %v: %v, want %v", v.scores, stats.Median, v.median)
+		}
+	}
+}
+
+func TestDistributionHistogram(t *testing.T) {
+	tests := []struct {
+		name      string
+		scores    []float64
+		low, high float64
+		bins      int
+		want      []Bin
+	}{
+		{"values on the edges of bins go to the upper bin, the high end to the last bin",
+			[]float64{0, 1.9, 2, 4, 8, 9.99, 10}, 0, 10, 5,
+			[]Bin{{0, 2, 2}, {2, 4, 1}, {4, 6, 1}, {6, 8, 0}, {8, 10, 3}}},
+		{"values outside of the range widen it",
+			[]float64{-10, 0, 5, 20}, 0, 10, 3,
+			[]Bin{{-10, 0, 1}, {0, 10, 2}, {10, 20, 1}}},
+		{"a range of one value has a single bin",
+			[]float64{3, 3}, 3, 3, 4,
+			[]Bin{{3, 3, 2}}},
+		{"the number of bins is at least 1",
+			[]float64{1, 2}, 0, 4, 0,
+			[]Bin{{0, 4, 2}}},
+	}
+
+	for _, v := range tests {
+		d := NewDistribution()
+		for k, score := range v.scores {
+			d.Add(string(rune('a'+k)), score, nil, time.Time{})
+		}
+		if stats := d.Scores(v.low, v.high, v.bins); !reflect.DeepEqual(stats.Histogram, v.want) {
+			t.Errorf("%s: histogram %v, want %v", v.name, stats.Histogram, v.want)
+		}
+	}
+
+	empty := NewDistribution()
+	if stats := empty.Scores(0, 10, 5); stats.Count != 0 || stats.Histogram == nil || len(stats.Histogram) != 0 {
+		t.Errorf("empty distribution %+v, want no bin", stats)
+	}
+	if stats := empty.Durations(5); stats.Count != 0 || len(stats.Histogram) != 0 {
+		t.Errorf("empty durations %+v, want no bin", stats)
+	}
+}
+
+func TestCache(t *testing.T) {
+	c := NewCache()
+	d := c.Get("test")
+	d.Add("a", 1, nil, time.Time{})
+	if c.Get("test") != d || c.Get("other") == d {
+		t.Errorf("distributions are not cached by key")
+	}
+
+	reset := c.Reset("test")
+	if reset == d || reset.Len() != 0 || c.Get("test") != reset {
+		t.Errorf("reset does not replace the distribution with an empty one")
+	}
+}
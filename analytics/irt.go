@@ -0,0 +1,285 @@
+package analytics
+
+import "math"
+
+// item response theory models, the 1PL model has a discrimination of 1 for every item
+const (
+	Model1PL = "1pl"
+	Model2PL = "2pl"
+)
+
+// responses of a person to an item in a calibration matrix
+const (
+	ResponseMissing   = -1
+	ResponseIncorrect = 0
+	ResponseCorrect   = 1
+)
+
+// bounds of the estimated parameters, they keep the estimation finite when the data is sparse
+const (
+	maxTheta             = 4.0
+	maxDifficulty        = 5.0
+	minIRTDiscrimination = 0.2
+	maxIRTDiscrimination = 4.0
+
+	calibrationIterations = 200
+	calibrationTolerance  = 0.0001
+)
+
+// ItemParams parameters of an item. Calibrated is false when the item cannot be estimated, when it has no responses
+// or every response is correct or incorrect.
+type ItemParams struct {
+	Discrimination float64 `json:"discrimination"`
+	Difficulty     float64 `json:"difficulty"`
+	Responses      int     `json:"responses"`
+	Calibrated     bool    `json:"calibrated"`
+}
+
+// Ability ability estimate of a person with its standard error
+type Ability struct {
+	Theta         float64 `json:"theta"`
+	StandardError float64 `json:"standard_error"`
+}
+
+// Probability probability of a correct response at ability theta
+func Probability(theta float64, item ItemParams) float64 {
+	return 1 / (1 + math.Exp(-item.Discrimination*(theta-item.Difficulty)))
+}
+
+// Information fisher information of an item at ability theta
+func Information(theta float64, item ItemParams) float64 {
+	p := Probability(theta, item)
+	return item.Discrimination * item.Discrimination * p * (1 - p)
+}
+
+// Calibrate estimate the parameters of the items from a matrix of responses, one row per person with a column for every
+// item, with joint maximum likelihood. Persons and items whose responses are all correct or all incorrect carry
+// no information and are left out. The ability scale has a mean of 0 and, with the 2PL model, a standard deviation of 1.
+func Calibrate(responses [][]int, totalItem int, model string) []ItemParams {
+	items := make([]ItemParams, totalItem)
+	for k := range items {
+		items[k].Discrimination = 1
+	}
+
+	persons, usable := calibrationSet(responses, totalItem)
+	if len(persons) == 0 {
+		return items
+	}
+
+	//start from the logit of the proportions of correct responses
+	thetas := make([]float64, len(responses))
+	for _, p := range persons {
+		right, total := 0.0, 0.0
+		for i, x := range responses[p] {
+			if usable[i] && x != ResponseMissing {
+				right += float64(x)
+				total++
+			}
+		}
+		thetas[p] = math.Log(right / (total - right))
+	}
+	for i := range items {
+		if !usable[i] {
+			continue
+		}
+		right, total := 0.0, 0.0
+		for _, p := range persons {
+			if x := responses[p][i]; x != ResponseMissing {
+				right += float64(x)
+				total++
+			}
+		}
+		items[i].Difficulty = -math.Log(right / (total - right))
+		items[i].Responses = int(total)
+		items[i].Calibrated = true
+	}
+
+	for iteration := 0; iteration < calibrationIterations; iteration++ {
+		change := 0.0
+
+		for _, p := range persons {
+			gradient, hessian := 0.0, 0.0
+			for i, x := range responses[p] {
+				if !usable[i] || x == ResponseMissing {
+					continue
+				}
+				prob := Probability(thetas[p], items[i])
+				a := items[i].Discrimination
+				gradient += a * (float64(x) - prob)
+				hessian -= a * a * prob * (1 - prob)
+			}
+			if hessian == 0 {
+				continue
+			}
+			theta := clamp(thetas[p]-gradient/hessian, -maxTheta, maxTheta)
+			change = math.Max(change, math.Abs(theta-thetas[p]))
+			thetas[p] = theta
+		}
+		standardize(thetas, persons, model == Model2PL)
+
+		for i := range items {
+			if !usable[i] {
+				continue
+			}
+			gradientB, hessianB, gradientA, hessianA := 0.0, 0.0, 0.0, 0.0
+			for _, p := range persons {
+				x := responses[p][i]
+				if x == ResponseMissing {
+					continue
+				}
+				prob := Probability(thetas[p], items[i])
+				a := items[i].Discrimination
+				d := thetas[p] - items[i].Difficulty
+				gradientB -= a * (float64(x) - prob)
+				hessianB -= a * a * prob * (1 - prob)
+				gradientA += d * (float64(x) - prob)
+				hessianA -= d * d * prob * (1 - prob)
+			}
+			if hessianB != 0 {
+				b := clamp(items[i].Difficulty-gradientB/hessianB, -maxDifficulty, maxDifficulty)
+				change = math.Max(change, math.Abs(b-items[i].Difficulty))
+				items[i].Difficulty = b
+			}
+			if model == Model2PL && hessianA != 0 {
+				a := clamp(items[i].Discrimination-gradientA/hessianA, minIRTDiscrimination, maxIRTDiscrimination)
+				change = math.Max(change, math.Abs(a-items[i].Discrimination))
+				items[i].Discrimination = a
+			}
+		}
+
+		if change < calibrationTolerance {
+			break
+		}
+	}
+
+	return items
+}
+
+// calibrationSet leave out the persons and items whose responses are all correct or all incorrect until none is left.
+// It returns the persons which are kept and whether every item is kept.
+func calibrationSet(responses [][]int, totalItem int) ([]int, []bool) {
+	person := make([]bool, len(responses))
+	for k := range person {
+		person[k] = true
+	}
+	usable := make([]bool, totalItem)
+	for k := range usable {
+		usable[k] = true
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for p, row := range responses {
+			var right, wrong int
+			for i, x := range row {
+				if usable[i] {
+					right, wrong = countResponse(x, right, wrong)
+				}
+			}
+			if person[p] && (right == 0 || wrong == 0) {
+				person[p] = false
+				changed = true
+			}
+		}
+		for i := range usable {
+			var right, wrong int
+			for p, row := range responses {
+				if person[p] {
+					right, wrong = countResponse(row[i], right, wrong)
+				}
+			}
+			if usable[i] && (right == 0 || wrong == 0) {
+				usable[i] = false
+				changed = true
+			}
+		}
+	}
+
+	var persons []int
+	for k, v := range person {
+		if v {
+			persons = append(persons, k)
+		}
+	}
+
+	return persons, usable
+}
+
+func countResponse(x int, right int, wrong int) (int, int) {
+	switch x {
+	case ResponseCorrect:
+		right++
+	case ResponseIncorrect:
+		wrong++
+	}
+
+	return right, wrong
+}
+
+// standardize center the abilities on 0, and scale them to a standard deviation of 1 when scale is set
+func standardize(thetas []float64, persons []int, scale bool) {
+	values := make([]float64, len(persons))
+	for k, p := range persons {
+		values[k] = thetas[p]
+	}
+	mean, stdDev := meanStdDev(values)
+	if !scale || stdDev == 0 {
+		stdDev = 1
+	}
+	for _, p := range persons {
+		thetas[p] = (thetas[p] - mean) / stdDev
+	}
+}
+
+// EstimateAbility expected a posteriori estimate of the ability from the responses to items, with a standard normal
+// prior. It is finite even when every response is correct or incorrect, the standard error is the posterior
+// standard deviation.
+func EstimateAbility(items []ItemParams, correct []bool) Ability {
+	const points = 81
+
+	//log posteriors are shifted by their maximum so long tests do not underflow
+	thetas := make([]float64, points)
+	logs := make([]float64, points)
+	highest := math.Inf(-1)
+	for k := range thetas {
+		thetas[k] = -maxTheta + 2*maxTheta*float64(k)/(points-1)
+		logs[k] = -thetas[k] * thetas[k] / 2
+		for i, item := range items {
+			p := Probability(thetas[k], item)
+			if correct[i] {
+				logs[k] += math.Log(p)
+			} else {
+				logs[k] += math.Log(1 - p)
+			}
+		}
+		highest = math.Max(highest, logs[k])
+	}
+
+	var weights, sum, sumSquare float64
+	for k, theta := range thetas {
+		weight := math.Exp(logs[k] - highest)
+		weights += weight
+		sum += weight * theta
+		sumSquare += weight * theta * theta
+	}
+
+	mean := sum / weights
+	return Ability{Theta: mean, StandardError: math.Sqrt(math.Max(sumSquare/weights-mean*mean, 0))}
+}
+
+// MostInformative index of the item giving the most information at ability theta, the first one on ties,
+// -1 without item
+func MostInformative(theta float64, items []ItemParams) int {
+	best, information := -1, 0.0
+	for k, v := range items {
+		if i := Information(theta, v); best == -1 || i > information {
+			best, information = k, i
+		}
+	}
+
+	return best
+}
+
+func clamp(value, low, high float64) float64 {
+	return math.Max(low, math.Min(high, value))
+}
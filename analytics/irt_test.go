@@ -0,0 +1,189 @@
+package analytics
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// simulate responses of persons of ability drawn from a standard normal distribution to items
+func simulate(r *rand.Rand, items []ItemParams, totalPerson int) [][]int {
+	responses := make([][]int, totalPerson)
+	for p := range responses {
+		theta := r.NormFloat64()
+		responses[p] = make([]int, len(items))
+		for i, item := range items {
+			if r.Float64() < Probability(theta, item) {
+				responses[p][i] = ResponseCorrect
+			}
+		}
+	}
+
+	return responses
+}
+
+func TestCalibrate(t *testing.T) {
+	//joint maximum likelihood spreads the extreme difficulties and overestimates the discriminations a little,
+	//the tolerances allow for it
+	const (
+		difficultyTolerance     = 0.3
+		discriminationTolerance = 0.15
+	)
+	tests := []struct {
+		model          string
+		discrimination []float64
+	}{
+		{Model1PL, []float64{1}},
+		{Model2PL, []float64{0.6, 1, 1.6}},
+	}
+
+	for _, test := range tests {
+		r := rand.New(rand.NewSource(7))
+		var items []ItemParams
+		for i := 0; i < 60; i++ {
+			items = append(items, ItemParams{
+				Discrimination: test.discrimination[i%len(test.discrimination)],
+				Difficulty:     -2 + 4*float64(i)/59,
+			})
+		}
+		responses := simulate(r, items, 2000)
+
+		calibrated := Calibrate(responses, len(items), test.model)
+		discrimination := make([]float64, len(test.discrimination))
+		for i, item := range calibrated {
+			//persons with every response correct or incorrect are left out
+			if !item.Calibrated || item.Responses < 1900 {
+				t.Errorf("%s item %d: calibrated %t with %d responses", test.model, i, item.Calibrated, item.Responses)
+			}
+			if math.Abs(item.Difficulty-items[i].Difficulty) > difficultyTolerance {
+				t.Errorf("%s item %d: difficulty %.2f, want %.2f", test.model, i, item.Difficulty, items[i].Difficulty)
+			}
+			discrimination[i%len(discrimination)] += item.Discrimination / float64(len(items)/len(discrimination))
+		}
+		for k, v := range discrimination {
+			if want := test.discrimination[k]; math.Abs(v-want) > discriminationTolerance*want {
+				t.Errorf("%s: mean discrimination %.2f, want %.2f", test.model, v, want)
+			}
+		}
+	}
+}
+
+func TestCalibrateWithoutInformation(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses [][]int
+	}{
+		{"every response correct", [][]int{{1, 1, 1}, {1, 1, 1}, {1, 1, 1}}},
+		{"every response incorrect", [][]int{{0, 0, 0}, {0, 0, 0}}},
+		{"every response missing", [][]int{{-1, -1, -1}}},
+		{"persons all correct or all incorrect", [][]int{{1, 1, 1}, {0, 0, 0}, {1, 1, 1}}},
+		{"no person", nil},
+	}
+
+	for _, test := range tests {
+		for _, model := range []string{Model1PL, Model2PL} {
+			items := Calibrate(test.responses, 3, model)
+			for i, item := range items {
+				if item.Calibrated || item.Discrimination != 1 || item.Difficulty != 0 {
+					t.Errorf("%s, %s item %d: %+v, want an uncalibrated item", test.name, model, i, item)
+				}
+			}
+		}
+	}
+
+	//items answered by everyone alike are left out, the others are still calibrated with finite parameters
+	items := Calibrate([][]int{{1, 1, 0, 0}, {1, 0, 1, 0}, {1, 1, 1, 0}, {1, 0, 0, 0}}, 4, Model2PL)
+	if items[0].Calibrated || items[3].Calibrated || !items[1].Calibrated || !items[2].Calibrated {
+		t.Errorf("calibrated items %+v, want only items 1 and 2", items)
+	}
+	for i, item := range items {
+		if math.IsNaN(item.Difficulty) || math.IsInf(item.Difficulty, 0) || math.IsNaN(item.Discrimination) {
+			t.Errorf("item %d: %+v is not finite", i, item)
+		}
+	}
+}
+
+func TestEstimateAbility(t *testing.T) {
+	var items []ItemParams
+	for i := 0; i < 60; i++ {
+		items = append(items, ItemParams{Discrimination: 1.2, Difficulty: -3 + 6*float64(i)/59})
+	}
+
+	r := rand.New(rand.NewSource(11))
+	for _, theta := range []float64{-1.5, 0, 0.8, 2} {
+		//the estimates of many persons of the same ability are centered on it and spread by the standard error
+		var sum, sumError float64
+		const persons = 200
+		for p := 0; p < persons; p++ {
+			correct := make([]bool, len(items))
+			for i, item := range items {
+				correct[i] = r.Float64() < Probability(theta, item)
+			}
+			ability := EstimateAbility(items, correct)
+			sum += ability.Theta
+			sumError += ability.StandardError
+		}
+
+		information := 1.0
+		for _, item := range items {
+			information += Information(theta, item)
+		}
+		//the prior pulls the estimates toward 0
+		if mean := sum / persons; math.Abs(mean-theta) > 0.1+0.1*math.Abs(theta) {
+			t.Errorf("theta %.1f: mean estimate %.2f", theta, mean)
+		}
+		if se, want := sumError/persons, 1/math.Sqrt(information); math.Abs(se-want) > 0.2*want {
+			t.Errorf("theta %.1f: standard error %.3f, want %.3f", theta, se, want)
+		}
+	}
+}
+
+func TestEstimateAbilityExtremes(t *testing.T) {
+	items := []ItemParams{
+		{Discrimination: 1, Difficulty: -1},
+		{Discrimination: 1.5, Difficulty: 0},
+		{Discrimination: 0.8, Difficulty: 1},
+	}
+
+	right := EstimateAbility(items, []bool{true, true, true})
+	wrong := EstimateAbility(items, []bool{false, false, false})
+	for _, v := range []Ability{right, wrong} {
+		if math.IsNaN(v.Theta) || math.Abs(v.Theta) >= maxTheta || v.StandardError <= 0 || v.StandardError >= 1 {
+			t.Errorf("estimate %+v is not finite and within the ability scale", v)
+		}
+	}
+	if right.Theta <= 0 || wrong.Theta >= 0 {
+		t.Errorf("every response correct gives %.2f and incorrect %.2f", right.Theta, wrong.Theta)
+	}
+
+	none := EstimateAbility(nil, nil)
+	if math.Abs(none.Theta) > 1e-9 || math.Abs(none.StandardError-1) > 0.01 {
+		t.Errorf("estimate without responses %+v, want the standard normal prior", none)
+	}
+}
+
+func TestMostInformative(t *testing.T) {
+	items := []ItemParams{
+		{Discrimination: 1, Difficulty: -2},
+		{Discrimination: 1, Difficulty: 0.5},
+		{Discrimination: 1, Difficulty: 2},
+		{Discrimination: 2, Difficulty: 2.5},
+	}
+
+	tests := []struct {
+		theta float64
+		want  int
+	}{
+		{-3, 0},
+		{0.4, 1},
+		{2.5, 3},
+	}
+	for _, v := range tests {
+		if got := MostInformative(v.theta, items); got != v.want {
+			t.Errorf("theta %.1f: item %d, want %d", v.theta, got, v.want)
+		}
+	}
+	if got := MostInformative(0, nil); got != -1 {
+		t.Errorf("without item: %d, want -1", got)
+	}
+}
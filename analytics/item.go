@@ -0,0 +1,238 @@
+package analytics
+
+import "math"
+
+// flags of questions with problematic statistics
+const (
+	FlagTooHard                 = "too_hard"
+	FlagTooEasy                 = "too_easy"
+	FlagLowDiscrimination       = "low_discrimination"
+	FlagNegativeDiscrimination  = "negative_discrimination"
+	FlagNonFunctionalDistractor = "non_functional_distractor"
+	FlagDistractorOverKey       = "distractor_chosen_over_key"
+)
+
+// thresholds of the flags
+const (
+	minPValue             = 0.2
+	maxPValue             = 0.9
+	minDiscrimination     = 0.2
+	minDistractorSelected = 0.05
+)
+
+// Item question of a test and its choices
+type Item struct {
+	ID      string
+	Choices []Choice
+}
+
+// Choice choice of a question
+type Choice struct {
+	ID      string
+	Key     int
+	Correct bool
+}
+
+// Answer answer of a participant to a question, ChoiceIDs are the picked choices
+type Answer struct {
+	Answered  bool
+	Correct   bool
+	ChoiceIDs []string
+}
+
+// Report item analysis of a test
+type Report struct {
+	Participants int         `json:"participants"`
+	TotalItem    int         `json:"total_item"`
+	MeanScore    float64     `json:"mean_score"`
+	StdDev       float64     `json:"std_dev"`
+	KR20         float64     `json:"kr20"`
+	Alpha        float64     `json:"alpha"`
+	Items        []ItemStats `json:"items"`
+}
+
+// ItemStats statistics of a question, the score of a participant is the number of questions answered correctly
+type ItemStats struct {
+	ID            string        `json:"id"`
+	Number        int           `json:"number"`
+	Answered      int           `json:"answered"`
+	Omitted       int           `json:"omitted"`
+	PValue        float64       `json:"p_value"`
+	PointBiserial float64       `json:"point_biserial"`
+	Choices       []ChoiceStats `json:"choices"`
+	Flags         []string      `json:"flags"`
+}
+
+// ChoiceStats how often a choice was picked and the mean score of the participants who picked it
+type ChoiceStats struct {
+	ID         string  `json:"id"`
+	Key        int     `json:"key"`
+	Correct    bool    `json:"correct"`
+	Count      int     `json:"count"`
+	Proportion float64 `json:"proportion"`
+	MeanScore  float64 `json:"mean_score"`
+}
+
+// Analyze compute the item analysis of a test. answers holds a row per participant with the answer of every item,
+// in the order of items. Questions not answered count as incorrect.
+func Analyze(items []Item, answers [][]Answer) Report {
+	report := Report{Participants: len(answers), TotalItem: len(items)}
+
+	scores := make([]float64, len(answers))
+	for p, row := range answers {
+		for _, v := range row {
+			if v.Correct {
+				scores[p]++
+			}
+		}
+	}
+	report.MeanScore, report.StdDev = meanStdDev(scores)
+
+	variances := make([]float64, len(items))
+	for i, item := range items {
+		stats := ItemStats{ID: item.ID, Number: i + 1, Flags: []string{}}
+
+		correct := make([]float64, len(answers))
+		rest := make([]float64, len(answers))
+		picked := make(map[string][]float64)
+		for p, row := range answers {
+			v := row[i]
+			if v.Answered {
+				stats.Answered++
+			} else {
+				stats.Omitted++
+			}
+			if v.Correct {
+				correct[p] = 1
+			}
+			//the item is removed from the total so it is not correlated with itself
+			rest[p] = scores[p] - correct[p]
+
+			for _, id := range v.ChoiceIDs {
+				picked[id] = append(picked[id], scores[p])
+			}
+		}
+
+		stats.PValue, _ = meanStdDev(correct)
+		stats.PointBiserial = correlation(correct, rest)
+		variances[i] = stats.PValue * (1 - stats.PValue)
+
+		for _, choice := range item.Choices {
+			res := ChoiceStats{ID: choice.ID, Key: choice.Key, Correct: choice.Correct, Count: len(picked[choice.ID])}
+			if len(answers) > 0 {
+				res.Proportion = float64(res.Count) / float64(len(answers))
+			}
+			res.MeanScore, _ = meanStdDev(picked[choice.ID])
+			stats.Choices = append(stats.Choices, res)
+		}
+
+		if len(answers) > 0 {
+			stats.Flags = itemFlags(stats)
+		}
+		report.Items = append(report.Items, stats)
+	}
+
+	//items are scored 0 or 1, Cronbach's alpha is then the same as KR-20
+	report.KR20 = reliability(variances, report.StdDev*report.StdDev)
+	report.Alpha = report.KR20
+
+	return report
+}
+
+// itemFlags flag the statistics of an item which need a review
+func itemFlags(stats ItemStats) []string {
+	flags := []string{}
+	switch {
+	case stats.PValue < minPValue:
+		flags = append(flags, FlagTooHard)
+	case stats.PValue > maxPValue:
+		flags = append(flags, FlagTooEasy)
+	}
+
+	switch {
+	case stats.PointBiserial < 0:
+		flags = append(flags, FlagNegativeDiscrimination)
+	case stats.PointBiserial < minDiscrimination:
+		flags = append(flags, FlagLowDiscrimination)
+	}
+
+	keyCount := 0
+	for _, v := range stats.Choices {
+		if v.Correct && v.Count > keyCount {
+			keyCount = v.Count
+		}
+	}
+
+	nonFunctional, overKey := false, false
+	for _, v := range stats.Choices {
+		if v.Correct {
+			continue
+		}
+		if v.Proportion < minDistractorSelected {
+			nonFunctional = true
+		}
+		if v.Count > keyCount {
+			overKey = true
+		}
+	}
+	if nonFunctional {
+		flags = append(flags, FlagNonFunctionalDistractor)
+	}
+	if overKey {
+		flags = append(flags, FlagDistractorOverKey)
+	}
+
+	return flags
+}
+
+// reliability Cronbach's alpha from the variance of every item and of the total score
+func reliability(variances []float64, total float64) float64 {
+	k := float64(len(variances))
+	if k < 2 || total == 0 {
+		return 0
+	}
+
+	sum := 0.0
+	for _, v := range variances {
+		sum += v
+	}
+
+	return k / (k - 1) * (1 - sum/total)
+}
+
+// meanStdDev the mean and the population standard deviation of values
+func meanStdDev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
+
+// correlation the pearson correlation of x and y, 0 when one of them does not vary
+func correlation(x []float64, y []float64) float64 {
+	meanX, sdX := meanStdDev(x)
+	meanY, sdY := meanStdDev(y)
+	if sdX == 0 || sdY == 0 {
+		return 0
+	}
+
+	covariance := 0.0
+	for i := range x {
+		covariance += (x[i] - meanX) * (y[i] - meanY)
+	}
+	covariance /= float64(len(x))
+
+	return covariance / (sdX * sdY)
+}
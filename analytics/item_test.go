@@ -0,0 +1,110 @@
+package analytics
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	items := []Item{
+		{ID: "a", Choices: []Choice{{ID: "a1", Key: 1, Correct: true}, {ID: "a2", Key: 2}, {ID: "a3", Key: 3}}},
+		{ID: "b"},
+		{ID: "c"},
+	}
+	right := func(choiceIDs ...string) Answer { return Answer{Answered: true, Correct: true, ChoiceIDs: choiceIDs} }
+	wrong := func(choiceIDs ...string) Answer { return Answer{Answered: true, ChoiceIDs: choiceIDs} }
+	//scores 3, 2, 1 and 0
+	answers := [][]Answer{
+		{right("a1"), right(), right()},
+		{right("a1"), right(), wrong()},
+		{right("a1"), wrong(), wrong()},
+		{wrong("a2"), wrong(), {}},
+	}
+
+	report := Analyze(items, answers)
+	if report.Participants != 4 || report.TotalItem != 3 {
+		t.Errorf("%d participants and %d items, want 4 and 3", report.Participants, report.TotalItem)
+	}
+	checkFloat(t, "mean score", report.MeanScore, 1.5)
+	checkFloat(t, "std dev", report.StdDev, math.Sqrt(1.25))
+	//3/2 * (1 - (0.1875 + 0.25 + 0.1875) / 1.25)
+	checkFloat(t, "kr20", report.KR20, 0.75)
+	checkFloat(t, "alpha", report.Alpha, 0.75)
+
+	want := []struct {
+		pValue        float64
+		pointBiserial float64
+		omitted       int
+		flags         []string
+	}{
+		{0.75, 0.1875 / (math.Sqrt(0.1875) * math.Sqrt(0.6875)), 0, []string{FlagNonFunctionalDistractor}},
+		{0.5, 0.25 / (0.5 * math.Sqrt(0.5)), 0, []string{}},
+		{0.25, 0.1875 / (math.Sqrt(0.1875) * math.Sqrt(0.6875)), 1, []string{}},
+	}
+	for i, v := range want {
+		stats := report.Items[i]
+		if stats.Number != i+1 || stats.Omitted != v.omitted || stats.Answered != 4-v.omitted {
+			t.Errorf("item %d: number %d, answered %d, omitted %d", i+1, stats.Number, stats.Answered, stats.Omitted)
+		}
+		checkFloat(t, "p-value of item "+stats.ID, stats.PValue, v.pValue)
+		checkFloat(t, "point biserial of item "+stats.ID, stats.PointBiserial, v.pointBiserial)
+		if !reflect.DeepEqual(stats.Flags, v.flags) {
+			t.Errorf("item %s: flags %v, want %v", stats.ID, stats.Flags, v.flags)
+		}
+	}
+
+	choices := []ChoiceStats{
+		{ID: "a1", Key: 1, Correct: true, Count: 3, Proportion: 0.75, MeanScore: 2},
+		{ID: "a2", Key: 2, Count: 1, Proportion: 0.25, MeanScore: 0},
+		{ID: "a3", Key: 3},
+	}
+	if !reflect.DeepEqual(report.Items[0].Choices, choices) {
+		t.Errorf("choices %+v, want %+v", report.Items[0].Choices, choices)
+	}
+}
+
+func TestAnalyzeWithoutParticipant(t *testing.T) {
+	report := Analyze([]Item{{ID: "a", Choices: []Choice{{ID: "a1", Correct: true}}}}, nil)
+	if report.MeanScore != 0 || report.KR20 != 0 || report.Items[0].PValue != 0 || len(report.Items[0].Flags) != 0 {
+		t.Errorf("report without participant %+v", report)
+	}
+}
+
+func TestItemFlags(t *testing.T) {
+	key := ChoiceStats{ID: "key", Correct: true, Count: 10, Proportion: 0.5}
+	distractor := ChoiceStats{ID: "distractor", Count: 5, Proportion: 0.25}
+
+	tests := []struct {
+		name  string
+		stats ItemStats
+		want  []string
+	}{
+		{"good item", ItemStats{PValue: 0.5, PointBiserial: 0.4, Choices: []ChoiceStats{key, distractor}}, []string{}},
+		{"too hard", ItemStats{PValue: 0.19, PointBiserial: 0.4}, []string{FlagTooHard}},
+		{"hard enough", ItemStats{PValue: 0.2, PointBiserial: 0.4}, []string{}},
+		{"too easy", ItemStats{PValue: 0.91, PointBiserial: 0.4}, []string{FlagTooEasy}},
+		{"easy enough", ItemStats{PValue: 0.9, PointBiserial: 0.4}, []string{}},
+		{"low discrimination", ItemStats{PValue: 0.5, PointBiserial: 0.19}, []string{FlagLowDiscrimination}},
+		{"no discrimination", ItemStats{PValue: 0.5, PointBiserial: 0}, []string{FlagLowDiscrimination}},
+		{"negative discrimination", ItemStats{PValue: 0.1, PointBiserial: -0.3}, []string{FlagTooHard, FlagNegativeDiscrimination}},
+		{"non functional distractor", ItemStats{PValue: 0.5, PointBiserial: 0.4, Choices: []ChoiceStats{key, distractor,
+			{ID: "unused", Count: 1, Proportion: 0.04}}}, []string{FlagNonFunctionalDistractor}},
+		{"distractor chosen over the key", ItemStats{PValue: 0.5, PointBiserial: 0.4, Choices: []ChoiceStats{key,
+			{ID: "popular", Count: 11, Proportion: 0.55}}}, []string{FlagDistractorOverKey}},
+	}
+
+	for _, v := range tests {
+		if got := itemFlags(v.stats); !reflect.DeepEqual(got, v.want) {
+			t.Errorf("%s: flags %v, want %v", v.name, got, v.want)
+		}
+	}
+}
+
+func checkFloat(t *testing.T, name string, got float64, want float64) {
+	t.Helper()
+
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("%s %v, want %v", name, got, want)
+	}
+}
@@ -0,0 +1,68 @@
+package analytics
+
+import "sort"
+
+// Entry score of a participant, Duration is nil when the time to complete is unknown
+type Entry struct {
+	ID       string
+	Score    float64
+	Duration *float64
+}
+
+// Ranking position and percentile rank of an entry
+type Ranking struct {
+	Entry
+	Position       int
+	PercentileRank float64
+}
+
+// Rank order entries by score, ties are broken by the shortest duration and entries without duration come last.
+// Entries with the same score and duration share the position. The percentile rank only depends on the score,
+// it is the percentage of scores below plus half of the scores equal to the entry.
+func Rank(entries []Entry) []Ranking {
+	rankings := make([]Ranking, len(entries))
+	for k, v := range entries {
+		rankings[k].Entry = v
+	}
+
+	sort.SliceStable(rankings, func(i, j int) bool {
+		return better(rankings[i].Entry, rankings[j].Entry)
+	})
+
+	for k := range rankings {
+		if k > 0 && !better(rankings[k-1].Entry, rankings[k].Entry) {
+			rankings[k].Position = rankings[k-1].Position
+		} else {
+			rankings[k].Position = k + 1
+		}
+	}
+
+	//rankings are sorted by descending score, the scores below an entry are after its last equal score
+	total := float64(len(rankings))
+	for start := 0; start < len(rankings); {
+		end := start
+		for end < len(rankings) && rankings[end].Score == rankings[start].Score {
+			end++
+		}
+
+		below, equal := total-float64(end), float64(end-start)
+		for k := start; k < end; k++ {
+			rankings[k].PercentileRank = (below + equal/2) / total * 100
+		}
+		start = end
+	}
+
+	return rankings
+}
+
+// better check whether a is ranked before b
+func better(a Entry, b Entry) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	if a.Duration == nil || b.Duration == nil {
+		return a.Duration != nil && b.Duration == nil
+	}
+
+	return *a.Duration < *b.Duration
+}
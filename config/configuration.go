@@ -10,6 +10,8 @@ import (
 type Configuration struct {
 	Server   ServerConfiguration
 	Database DatabaseConfiguration
+	Storage  StorageConfiguration
+	Sandbox  SandboxConfiguration
 }
 
 // New create new configuration object
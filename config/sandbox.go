@@ -0,0 +1,28 @@
+package config
+
+type SandboxConfiguration struct {
+	// number of workers grading programming answers, grading is disabled when it is 0
+	Workers int
+	// isolate the code in linux namespaces, without network. Only disable it where namespaces are not available
+	// and the code of the candidates is trusted
+	Isolate bool
+	// paths hidden from the code with isolation, on top of /tmp, the working directory and the home of the server
+	HiddenPaths []string
+	// go and python3 binaries, looked up in PATH when they are not absolute
+	GoBinary     string
+	PythonBinary string
+	// shared go build cache, a cache per answer is used when it is empty. Isolated code sees it through an overlay,
+	// what it writes is thrown away with the sandbox
+	GoCache string
+	// default limits of a test case, questions can set their own
+	TimeLimit   int // milliseconds
+	MemoryLimit int // megabytes
+	// limits of the compilation
+	CompileTimeLimit   int // milliseconds
+	CompileMemoryLimit int // megabytes
+	// maximum number of processes of the user running the code, not set when it is 0. It must be set with
+	// isolation, the grader does not start without it
+	MaxProcesses int
+	// interval between two polls of the job queue in milliseconds
+	PollInterval int
+}
@@ -0,0 +1,17 @@
+package config
+
+type StorageConfiguration struct {
+	// local or s3
+	Type string
+	// directory of the local storage
+	Path string
+	// s3 compatible storage, PathStyle is needed by most self hosted services
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool
+	// maximum size of an uploaded file in bytes
+	MaxSize int64
+}
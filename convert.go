@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"okkybudiman/exchange"
+)
+
+const commandUsage = `Commands:
+  convert [-from format] [-to format] [-title title] input output
+        convert a question file between formats without starting the server.
+        Formats are csv, xlsx, qti, moodle and gift, they are guessed from the file extension when not set.
+`
+
+// runCommand run the command given after the flags of the server, it returns the exit code
+func runCommand(args []string) int {
+	switch args[0] {
+	case "convert":
+		if err := convert(args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	case "help":
+		fmt.Print(commandUsage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %s\n\n%s", args[0], commandUsage)
+		return 2
+	}
+
+	return 0
+}
+
+func convert(args []string) error {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	from := flags.String("from", "", "format of the input file")
+	to := flags.String("to", "", "format of the output file")
+	title := flags.String("title", "", "title of the test, used by qti")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		return fmt.Errorf("convert needs an input and an output file")
+	}
+	input, output := flags.Arg(0), flags.Arg(1)
+
+	if *from == "" {
+		*from = exchange.FormatFromName(input)
+	}
+	if *to == "" {
+		*to = exchange.FormatFromName(output)
+	}
+	if *title == "" {
+		*title = strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+	}
+
+	data, err := ioutil.ReadFile(input)
+	if err != nil {
+		return err
+	}
+
+	items, itemErrors, err := exchange.ReadItems(*from, data)
+	if err != nil {
+		return fmt.Errorf("read %s: %s", input, err)
+	}
+	report("skipped", itemErrors)
+
+	var buf bytes.Buffer
+	itemErrors, err = exchange.WriteItems(&buf, *to, *title, items)
+	if err != nil {
+		return fmt.Errorf("write %s: %s", output, err)
+	}
+	report("not written", itemErrors)
+
+	if err := ioutil.WriteFile(output, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("%d questions converted from %s to %s\n", len(items)-len(itemErrors), *from, *to)
+
+	return nil
+}
+
+func report(action string, itemErrors []exchange.ItemError) {
+	for _, v := range itemErrors {
+		source := v.Source
+		if source == "" {
+			source = fmt.Sprintf("item %d", v.Index+1)
+		}
+		if v.Row > 0 {
+			source = fmt.Sprintf("%s at %d", source, v.Row)
+		}
+		fmt.Fprintf(os.Stderr, "%s %s: %s\n", action, source, strings.Join(v.Errors, ", "))
+	}
+}
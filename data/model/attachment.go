@@ -0,0 +1,17 @@
+package model
+
+import uuid "github.com/satori/go.uuid"
+
+//modeling table Attachment
+type Attachment struct {
+	BaseModel
+	FileName    string `gorm:"type:varchar(255);"`
+	ContentType string `gorm:"type:varchar(100);"`
+	Size        int64
+	//key of the file in the storage
+	StorageKey string `gorm:"type:varchar(255);"`
+	//an attachment belongs to a question or to a choice
+	QuestionID       *uuid.UUID `gorm:"type:char(36)"`
+	QuestionChoiceID *uuid.UUID `gorm:"type:char(36)"`
+	UploaderID       *uuid.UUID `gorm:"type:char(36)"`
+}
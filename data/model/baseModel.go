@@ -7,6 +7,9 @@ import (
 	"github.com/satori/go.uuid"
 )
 
+// long text columns are tagged with a size above 65535 instead of a type: mysql makes them longtext, its text only
+// holds 64 KB, and the other databases make them text
+
 // BaseModel base model definition for common entity's field
 type BaseModel struct {
 	ID        uuid.UUID  `json:"id" gorm:"type:char(36); primary_key"`
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// status of a grading job, failed jobs could not be run after their last attempt
+const (
+	GradingJobQueued  = "queued"
+	GradingJobRunning = "running"
+	GradingJobDone    = "done"
+	GradingJobFailed  = "failed"
+)
+
+//modeling table GradingJob
+type GradingJob struct {
+	BaseModel
+	UserAnswerID uuid.UUID `gorm:"type:char(36)"`
+	Status       string    `gorm:"type:varchar(20);default:'queued'"`
+	Attempts     int
+	Error        string     `gorm:"type:text"`
+	RunAfter     *time.Time // a job failing to run is queued again to run after it
+	StartedAt    *time.Time
+	LeaseUntil   *time.Time // a running job is queued again when its worker did not renew the lease before it
+	FinishedAt   *time.Time
+	UserAnswer   UserAnswer
+}
@@ -0,0 +1,21 @@
+package model
+
+import uuid "github.com/satori/go.uuid"
+
+//modeling table PracticeAnswer
+type PracticeAnswer struct {
+	BaseModel
+	UserAttemptTestID uuid.UUID `gorm:"type:char(36)"`
+	UserID            uuid.UUID `gorm:"type:char(36)"`
+	TestID            uuid.UUID `gorm:"type:char(36)"`
+	QuestionID        uuid.UUID `gorm:"type:char(36)"`
+	Point             int
+	Status            string `gorm:"type:varchar(20)"`
+
+	QuestionChoiceID uuid.UUID `gorm:"type:char(36)"`
+	//answer of the question types other than single choice, encoded as json
+	Response string `gorm:"size:65536"`
+
+	UserAttemptTest UserAttemptTest
+	Question        Question
+}
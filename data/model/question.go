@@ -1,13 +1,204 @@
 package model
 
-import uuid "github.com/satori/go.uuid"
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// type of a question, calculated questions are templates with variables drawn per attempt answered with a number.
+// Programming questions are answered with code graded against their test cases. Matching questions pair every choice
+// with the item of its Match, ordering questions put their choices back in the order of their keys. Cloze questions
+// are passages with blanks, each answered on its own.
+const (
+	QuestionTypeSingleChoice   = "single_choice"
+	QuestionTypeMultipleChoice = "multiple_choice"
+	QuestionTypeTextEntry      = "text_entry"
+	QuestionTypeCalculated     = "calculated"
+	QuestionTypeProgramming    = "programming"
+	QuestionTypeMatching       = "matching"
+	QuestionTypeOrdering       = "ordering"
+	QuestionTypeCloze          = "cloze"
+)
+
+// point of an answer right as a whole and of a wrong answer, partial credit, cloze and programming questions are worth
+// the points of their parts instead
+const (
+	PointRight = 4
+	PointWrong = -2
+)
+
+// type of a blank of a cloze question, a dropdown blank is answered with one of its options, a text blank is typed
+const (
+	BlankTypeDropdown = "dropdown"
+	BlankTypeText     = "text"
+)
+
+// format of the text of a question and its choices, the explanation and the feedback are always markdown
+const (
+	QuestionFormatPlain        = "plain"
+	QuestionFormatMarkdown     = "markdown"
+	QuestionFormatMarkdownMath = "markdown_math"
+)
+
+// difficulty level of a question
+const (
+	QuestionDifficultyEasy   = "easy"
+	QuestionDifficultyMedium = "medium"
+	QuestionDifficultyHard   = "hard"
+)
 
 //modeling table Question
 type Question struct {
 	BaseModel
-	Question        string    `gorm:"type:varchar(100);"`
-	Answer          string    `gorm:"type:varchar(100);"`
-	TestID          uuid.UUID `gorm:"type:char(36)" gorm:"default:18"`
+	Question string `gorm:"size:65536"`
+	Type     string `gorm:"type:varchar(20);default:'single_choice'"`
+	Format   string `gorm:"type:varchar(20);default:'plain'"`
+	//accepted answer of text entry questions, the answer key of choice questions is kept in QuestionChoice.IsCorrect
+	Answer     string `gorm:"size:65536"`
+	Difficulty string `gorm:"type:varchar(20);default:'medium'"`
+	//worked solution shown in review, in markdown
+	Explanation     string     `gorm:"size:65536"`
+	TestID          uuid.UUID  `gorm:"type:char(36)" gorm:"default:18"`
+	TopicID         *uuid.UUID `gorm:"type:char(36)"`
+	SectionID       *uuid.UUID `gorm:"type:char(36)"`
 	Test            Test
+	Topic           Topic
 	QuestionChoices []QuestionChoice
+	Tags            []Tag `gorm:"many2many:question_tags;"`
+	Attachments     []Attachment
+
+	//variables of calculated questions encoded as json, the expression of their answer and the accepted difference
+	Variables        string `gorm:"size:65536"`
+	AnswerExpression string `gorm:"size:65536"`
+	AnswerTolerance  float64
+
+	//language of the code of programming questions, any supported language when it is empty,
+	//and the limits of a test case, the defaults of the sandbox when they are 0
+	Language    string `gorm:"type:varchar(20)"`
+	TimeLimit   int    // milliseconds
+	MemoryLimit int    // megabytes
+	TestCases   []TestCase
+
+	//matching and ordering questions get ItemPoint for every right pair or position when PartialCredit is set,
+	//otherwise they are right only when every pair or position is
+	PartialCredit bool
+	ItemPoint     int `gorm:"default:1"`
+
+	//blanks of cloze questions encoded as json, blank n is written [[n]] in the question
+	Blanks string `gorm:"size:65536"`
+
+	//item response theory parameters, nil until the question is calibrated
+	IrtDiscrimination *float64
+	IrtDifficulty     *float64
+}
+
+// IsValidQuestionType check whether t is one of the question type
+func IsValidQuestionType(t string) bool {
+	return t == QuestionTypeSingleChoice || t == QuestionTypeMultipleChoice || t == QuestionTypeTextEntry || t == QuestionTypeCalculated ||
+		t == QuestionTypeProgramming || t == QuestionTypeMatching || t == QuestionTypeOrdering || t == QuestionTypeCloze
+}
+
+// IsValidQuestionFormat check whether format is one of the question format
+func IsValidQuestionFormat(format string) bool {
+	return format == QuestionFormatPlain || format == QuestionFormatMarkdown || format == QuestionFormatMarkdownMath
+}
+
+// IsValidQuestionDifficulty check whether difficulty is one of the question difficulty level
+func IsValidQuestionDifficulty(difficulty string) bool {
+	return difficulty == QuestionDifficultyEasy || difficulty == QuestionDifficultyMedium || difficulty == QuestionDifficultyHard
+}
+
+// ExplanationFormat format of the explanation and the feedback of the question, markdown with math when the
+// question itself is written with math
+func (q Question) ExplanationFormat() string {
+	if q.Format == QuestionFormatMarkdownMath {
+		return QuestionFormatMarkdownMath
+	}
+
+	return QuestionFormatMarkdown
+}
+
+// HasChoices check whether the question is answered by picking choices
+func (q Question) HasChoices() bool {
+	return q.Type != QuestionTypeTextEntry && q.Type != QuestionTypeCalculated && q.Type != QuestionTypeProgramming &&
+		q.Type != QuestionTypeCloze
+}
+
+// HasAnswerKeys check whether the question is answered right by picking the choices marked as correct, the choices of
+// matching and ordering questions are arranged instead
+func (q Question) HasAnswerKeys() bool {
+	return q.HasChoices() && q.Type != QuestionTypeMatching && q.Type != QuestionTypeOrdering
+}
+
+// MaxPoint the point of an answer with every part right. The choices of matching and ordering questions and the test
+// cases of programming questions must be loaded.
+func (q Question) MaxPoint() int {
+	point := 0
+	switch {
+	case q.Type == QuestionTypeProgramming:
+		for _, v := range q.TestCases {
+			point += v.Point
+		}
+	case q.Type == QuestionTypeCloze:
+		for _, v := range q.ClozeBlanks() {
+			point += v.Point
+		}
+	case (q.Type == QuestionTypeMatching || q.Type == QuestionTypeOrdering) && q.PartialCredit:
+		for _, v := range q.QuestionChoices {
+			//distractors of matching questions are not paired with any choice
+			if q.Type == QuestionTypeOrdering || strings.TrimSpace(v.Choice) != "" {
+				point += q.ItemPoint
+			}
+		}
+	default:
+		point = PointRight
+	}
+
+	return point
+}
+
+// MatchID id of an item of a matching question as it is sent to the users. It only depends on the question and the
+// text of the item, so choices paired with the same item share it and it does not tell which choice it belongs to.
+func MatchID(questionID uuid.UUID, match string) uuid.UUID {
+	return uuid.NewV5(questionID, match)
+}
+
+// ClozeBlank blank of a cloze question as saved in Question.Blanks. A dropdown blank is right when the option picked is
+// one of its answers, a text blank when the text typed is any of its accepted answers.
+type ClozeBlank struct {
+	Type    string   `json:"type"`
+	Options []string `json:"options,omitempty"`
+	Answers []string `json:"answers"`
+	Point   int      `json:"point"`
+}
+
+// blankMarker marker of a blank in the text of a cloze question
+var blankMarker = regexp.MustCompile(`\[\[(\d+)\]\]`)
+
+// ClozeBlanks the blanks of a cloze question, blank n is the one written [[n]] in the question
+func (q Question) ClozeBlanks() []ClozeBlank {
+	var blanks []ClozeBlank
+	if q.Blanks != "" {
+		json.Unmarshal([]byte(q.Blanks), &blanks)
+	}
+
+	return blanks
+}
+
+// BlankNumbers the numbers of the blank markers of text in the order they are written
+func BlankNumbers(text string) []int {
+	var numbers []int
+	for _, v := range blankMarker.FindAllStringSubmatch(text, -1) {
+		number, err := strconv.Atoi(v[1])
+		if err != nil {
+			number = -1
+		}
+		numbers = append(numbers, number)
+	}
+
+	return numbers
 }
@@ -5,9 +5,17 @@ import uuid "github.com/satori/go.uuid"
 //modeling table QuestionChoice
 type QuestionChoice struct {
 	BaseModel
-	Choice string `gorm:"type:varchar(100);"`
-	Key    int    `gorm:"type:varchar(100);"`
+	//written in the format of the question
+	Choice    string `gorm:"size:65536"`
+	Key       int    `gorm:"type:varchar(100);"`
+	IsCorrect bool
+	//message shown in review when the choice is picked, in markdown
+	Feedback string `gorm:"size:65536"`
+	//item the choice is paired with in matching questions, a choice with a Match and no text is a distractor
+	Match string `gorm:"size:65536"`
 
 	QuestionID uuid.UUID `gorm:"type:char(36)" gorm:"default:18"`
 	Question   Question
+
+	Attachments []Attachment
 }
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+//modeling table ReviewItem
+type ReviewItem struct {
+	BaseModel
+	UserID     uuid.UUID `gorm:"type:char(36)"`
+	QuestionID uuid.UUID `gorm:"type:char(36)"`
+	//spaced repetition schedule
+	EaseFactor     float64
+	IntervalDays   int
+	Repetitions    int
+	Lapses         int
+	DueAt          time.Time
+	LastReviewedAt *time.Time
+
+	User     User
+	Question Question
+}
+
+//modeling table ReviewLog
+type ReviewLog struct {
+	BaseModel
+	ReviewItemID uuid.UUID `gorm:"type:char(36)"`
+	UserID       uuid.UUID `gorm:"type:char(36)"`
+	QuestionID   uuid.UUID `gorm:"type:char(36)"`
+	Quality      int
+	IntervalDays int
+	EaseFactor   float64
+	ReviewedAt   time.Time
+}
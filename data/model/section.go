@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+//modeling table Section
+type Section struct {
+	BaseModel
+	TestID uuid.UUID `gorm:"type:char(36)"`
+	Name   string    `gorm:"type:varchar(100)"`
+	//sections are answered in the order of their key, a candidate cannot go back to an earlier section
+	Key int
+	//seconds to answer the section, 0 has no limit
+	TimeLimit int
+}
+
+// Deadline the end of the time of the section started at startedAt, nil when it has no limit
+func (s Section) Deadline(startedAt time.Time) *time.Time {
+	if s.TimeLimit <= 0 {
+		return nil
+	}
+	deadline := startedAt.Add(time.Duration(s.TimeLimit) * time.Second)
+
+	return &deadline
+}
@@ -0,0 +1,10 @@
+package model
+
+//modeling table Subject
+type Subject struct {
+	BaseModel
+	Name        string `gorm:"type:varchar(100);"`
+	Description string `gorm:"type:varchar(255);"`
+
+	Topics []Topic
+}
@@ -0,0 +1,7 @@
+package model
+
+//modeling table Tag
+type Tag struct {
+	BaseModel
+	Name string `gorm:"type:varchar(50);"`
+}
@@ -1,11 +1,71 @@
 package model
 
+import (
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// status of a test
+const (
+	TestStatusDraft     = "draft"
+	TestStatusPublished = "published"
+	TestStatusArchived  = "archived"
+)
+
+// leaderboard of a test, it is disabled unless the names, initials or anonymous mode is chosen
+const (
+	LeaderboardDisabled  = "disabled"
+	LeaderboardNames     = "names"
+	LeaderboardInitials  = "initials"
+	LeaderboardAnonymous = "anonymous"
+)
+
+// when the answers of a test can be reviewed by the participants after they submit them
+const (
+	ReviewImmediately = "immediately"
+	ReviewAfterClose  = "after_close"
+	ReviewNever       = "never"
+)
+
 //modeling table Test
 type Test struct {
 	BaseModel
-	Name          string `json:"name" gorm:"type:varchar(100);"`
-	Description   string `json:"description" gorm:"type:varchar(255);"`
-	TotalQuestion int    `json:"total_question"`
+	Name          string     `json:"name" gorm:"type:varchar(100);"`
+	Description   string     `json:"description" gorm:"type:varchar(255);"`
+	TotalQuestion int        `json:"total_question"`
+	Status        string     `json:"status" gorm:"type:varchar(20);default:'published'"`
+	CreatorID     *uuid.UUID `json:"creator_id" gorm:"type:char(36)"`
+	SubjectID     *uuid.UUID `json:"subject_id" gorm:"type:char(36)"`
+	Leaderboard   string     `json:"leaderboard" gorm:"type:varchar(20);default:'disabled'"`
+	ReviewPolicy  string     `json:"review_policy" gorm:"type:varchar(20);default:'never'"`
+	//the test cannot be attempted after AvailableUntil, it is the end of the window of the after_close review policy
+	AvailableUntil *time.Time `json:"available_until"`
+	//official attempts allowed per user, 0 is unlimited
+	MaxAttempts int `json:"max_attempts"`
+	//practice attempts are only allowed when AllowPractice is set, they show the answers of the questions
+	AllowPractice bool `json:"allow_practice"`
+	//adaptive attempts stop once the standard error of the ability estimate is at most TargetStandardError
+	TargetStandardError float64 `json:"target_standard_error"`
 
 	Questions []Question `json:"questions"`
+	Sections  []Section  `json:"-"`
+	Subject   Subject    `json:"-"`
+	Tags      []Tag      `json:"-" gorm:"many2many:test_tags;"`
+}
+
+// IsValidTestStatus check whether status is one of the test status
+func IsValidTestStatus(status string) bool {
+	return status == TestStatusDraft || status == TestStatusPublished || status == TestStatusArchived
+}
+
+// IsValidLeaderboard check whether leaderboard is one of the leaderboard modes
+func IsValidLeaderboard(leaderboard string) bool {
+	return leaderboard == LeaderboardDisabled || leaderboard == LeaderboardNames ||
+		leaderboard == LeaderboardInitials || leaderboard == LeaderboardAnonymous
+}
+
+// IsValidReviewPolicy check whether policy is one of the review policies
+func IsValidReviewPolicy(policy string) bool {
+	return policy == ReviewImmediately || policy == ReviewAfterClose || policy == ReviewNever
 }
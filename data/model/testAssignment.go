@@ -0,0 +1,15 @@
+package model
+
+import uuid "github.com/satori/go.uuid"
+
+//modeling table TestAssignment
+type TestAssignment struct {
+	BaseModel
+	TestID uuid.UUID `gorm:"type:char(36)"`
+	Test   Test
+
+	UserID uuid.UUID `gorm:"type:char(36)"`
+	User   User
+
+	Cohort string `gorm:"type:varchar(50);"`
+}
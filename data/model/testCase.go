@@ -0,0 +1,16 @@
+package model
+
+import uuid "github.com/satori/go.uuid"
+
+//modeling table TestCase
+type TestCase struct {
+	BaseModel
+	QuestionID uuid.UUID `gorm:"type:char(36)"`
+	//test cases are run in the order of their key
+	Key            int
+	Input          string `gorm:"size:65536"`
+	ExpectedOutput string `gorm:"size:65536"`
+	Point          int
+	//hidden test cases are only seen by admins, the others are shown to the candidates as examples
+	Hidden bool
+}
@@ -0,0 +1,19 @@
+package model
+
+import uuid "github.com/satori/go.uuid"
+
+//modeling table TestCaseResult
+type TestCaseResult struct {
+	BaseModel
+	UserAnswerID uuid.UUID `gorm:"type:char(36)"`
+	TestCaseID   uuid.UUID `gorm:"type:char(36)"`
+	//passed, wrong_answer, time_limit, memory_limit, output_limit, runtime_error or compile_error
+	Status string `gorm:"type:varchar(20)"`
+	Point  int
+	TimeMs int
+	//start of the output and of the error output of the code
+	Output  string `gorm:"type:text"`
+	Message string `gorm:"type:text"`
+
+	TestCase TestCase
+}
@@ -0,0 +1,12 @@
+package model
+
+import uuid "github.com/satori/go.uuid"
+
+//modeling table Topic
+type Topic struct {
+	BaseModel
+	Name        string    `gorm:"type:varchar(100);"`
+	Description string    `gorm:"type:varchar(255);"`
+	SubjectID   uuid.UUID `gorm:"type:char(36)"`
+	Subject     Subject
+}
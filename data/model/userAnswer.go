@@ -2,6 +2,13 @@ package model
 
 import uuid "github.com/satori/go.uuid"
 
+// grading status of a programming answer, failed when the code could not be run
+const (
+	GradingPending = "pending"
+	GradingGraded  = "graded"
+	GradingFailed  = "failed"
+)
+
 //modeling table UserAnswer
 type UserAnswer struct {
 	BaseModel
@@ -11,8 +18,22 @@ type UserAnswer struct {
 	Answer     string    `gorm:"type:char(36)" gorm:"default:18"`
 	Point      int
 
+	QuestionChoiceID uuid.UUID `gorm:"type:char(36)"`
+	//answer of the question types other than single choice, encoded as json
+	Response          string    `gorm:"size:65536"`
+	UserAttemptTestID uuid.UUID `gorm:"type:char(36)"`
+	//grading of programming answers, pending until a worker ran the code against the test cases
+	GradingStatus   string `gorm:"type:varchar(20)"`
+	TestCaseResults []TestCaseResult
+
 	User           User
 	Test           Test
 	Question       Question
 	QuestionChoice QuestionChoice
 }
+
+// ProgrammingResponse answer of a programming question as saved in UserAnswer.Response
+type ProgrammingResponse struct {
+	Language string `json:"language"`
+	Code     string `json:"code"`
+}
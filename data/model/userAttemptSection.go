@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+//modeling table UserAttemptSection
+type UserAttemptSection struct {
+	BaseModel
+	//a section is started once by attempt
+	UserAttemptTestID uuid.UUID `gorm:"type:char(36);unique_index:idx_attempt_section"`
+	SectionID         uuid.UUID `gorm:"type:char(36);unique_index:idx_attempt_section"`
+	StartedAt         time.Time
+	//the section is locked once it is submitted or its time is up
+	SubmittedAt *time.Time
+}
@@ -6,6 +6,14 @@ import (
 	uuid "github.com/satori/go.uuid"
 )
 
+// mode of an attempt, practice attempts are graded per question and are not part of the results of the test.
+// Adaptive attempts are served the calibrated questions of the test one by one, chosen from the ability estimate.
+const (
+	AttemptModeOfficial = "official"
+	AttemptModePractice = "practice"
+	AttemptModeAdaptive = "adaptive"
+)
+
 //modeling table UserAttempTask
 type UserAttemptTest struct {
 	BaseModel
@@ -20,4 +28,7 @@ type UserAttemptTest struct {
 	FinishTime string `gorm:"type:char(36)" gorm:"default:18"`
 
 	IsFinished bool
+	Mode       string `gorm:"type:varchar(20);default:'official'"`
+	//seed of the values drawn for the calculated questions, they are the same every time the attempt is graded or reviewed
+	Seed int64
 }
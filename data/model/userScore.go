@@ -11,8 +11,14 @@ type UserScore struct {
 	TestID uuid.UUID `gorm:"type:char(36)" gorm:"default:18"`
 	Test   Test
 
+	//attempt the score was counted from, a user has a score for every finished attempt
+	UserAttemptTestID uuid.UUID `gorm:"type:char(36)"`
+
 	TotalNotAnswered   int
 	TotalRightAnswered int
 	TotalWrongAnswered int
 	Score              int
+	//ability estimate of adaptive attempts
+	Theta         *float64
+	StandardError *float64
 }
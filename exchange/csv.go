@@ -0,0 +1,24 @@
+package exchange
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// ReadCSV read every row of a csv file, rows may have different number of columns
+func ReadCSV(r io.Reader) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	return reader.ReadAll()
+}
+
+// WriteCSV write rows as a csv file
+func WriteCSV(w io.Writer, rows [][]string) error {
+	writer := csv.NewWriter(w)
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+
+	return writer.Error()
+}
@@ -0,0 +1,81 @@
+package exchange
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// formats of question files
+const (
+	FormatCSV    = "csv"
+	FormatXLSX   = "xlsx"
+	FormatQTI    = "qti"
+	FormatMoodle = "moodle"
+	FormatGIFT   = "gift"
+)
+
+// FormatFromName guess the format of a file from its extension, empty when unknown
+func FormatFromName(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return FormatCSV
+	case ".xlsx":
+		return FormatXLSX
+	case ".zip":
+		return FormatQTI
+	case ".xml":
+		return FormatMoodle
+	case ".gift", ".txt":
+		return FormatGIFT
+	}
+
+	return ""
+}
+
+// ReadItems read the items of a question file in any format
+func ReadItems(format string, data []byte) ([]Item, []ItemError, error) {
+	switch format {
+	case FormatCSV, FormatXLSX:
+		var rows [][]string
+		var err error
+		if format == FormatCSV {
+			rows, err = ReadCSV(bytes.NewReader(data))
+		} else {
+			rows, err = ReadXLSX(bytes.NewReader(data), int64(len(data)))
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return RowsToItems(rows, nil)
+	case FormatQTI:
+		_, items, itemErrors, err := ReadQTI(bytes.NewReader(data), int64(len(data)))
+		return items, itemErrors, err
+	case FormatMoodle:
+		return ReadMoodle(bytes.NewReader(data))
+	case FormatGIFT:
+		return ReadGIFT(bytes.NewReader(data))
+	}
+
+	return nil, nil, fmt.Errorf("format must be csv, xlsx, qti, moodle or gift")
+}
+
+// WriteItems write items as a question file in any format, title is used by the formats which have one
+func WriteItems(w io.Writer, format string, title string, items []Item) ([]ItemError, error) {
+	switch format {
+	case FormatCSV:
+		return nil, WriteCSV(w, ItemsToRows(items))
+	case FormatXLSX:
+		return nil, WriteXLSX(w, ItemsToRows(items))
+	case FormatQTI:
+		return WriteQTI(w, title, items)
+	case FormatMoodle:
+		return WriteMoodle(w, items)
+	case FormatGIFT:
+		return WriteGIFT(w, items)
+	}
+
+	return nil, fmt.Errorf("format must be csv, xlsx, qti, moodle or gift")
+}
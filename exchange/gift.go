@@ -0,0 +1,431 @@
+package exchange
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// characters which have a meaning in gift and are escaped with a backslash
+const giftSpecial = "~=#{}:"
+
+type giftAnswer struct {
+	correct bool
+	weight  *float64
+	text    string
+}
+
+// ReadGIFT read the questions of a GIFT file.
+// Multiple choice, true false, short answer and matching questions are converted, other questions are reported.
+func ReadGIFT(r io.Reader) ([]Item, []ItemError, error) {
+	var items []Item
+	var itemErrors []ItemError
+
+	category := ""
+	var block []string
+	var tags, partialCredit []string
+	index, line, start := 0, 0, 0
+
+	flush := func() {
+		if len(block) == 0 {
+			tags, partialCredit = nil, nil
+			return
+		}
+
+		item, err := parseGIFTQuestion(strings.Join(block, "\n"))
+		if err != nil {
+			itemErrors = append(itemErrors, ItemError{Index: index, Row: start, Errors: []string{err.Error()}})
+		} else {
+			item.Category = category
+			item.Tags = tags
+			item.Row = start
+			if item.Type == TypeMatching && len(partialCredit) > 0 {
+				item.PartialCredit = partialCredit[len(partialCredit)-1] != "false"
+			}
+			items = append(items, item)
+		}
+		index++
+		block, tags, partialCredit = nil, nil, nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		trimmed := strings.TrimSpace(text)
+
+		switch {
+		case strings.HasPrefix(trimmed, "//"):
+			tags = append(tags, giftCommentValues(trimmed, "tag")...)
+			partialCredit = append(partialCredit, giftCommentValues(trimmed, "partial_credit")...)
+		case trimmed == "":
+			flush()
+		case len(block) == 0 && strings.HasPrefix(trimmed, "$CATEGORY:"):
+			category = ParseCategory(strings.TrimPrefix(trimmed, "$CATEGORY:"))
+			tags, partialCredit = nil, nil
+		default:
+			if len(block) == 0 {
+				start = line
+			}
+			block = append(block, text)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	flush()
+
+	return items, itemErrors, nil
+}
+
+// giftCommentValues the values of name in a comment, written as [name:value]. Tags are written as [tag:name] and
+// whether a matching question has partial credit as [partial_credit:false].
+func giftCommentValues(comment string, name string) []string {
+	var values []string
+	prefix := "[" + name + ":"
+	for {
+		start := strings.Index(comment, prefix)
+		if start < 0 {
+			return values
+		}
+		comment = comment[start+len(prefix):]
+		end := strings.Index(comment, "]")
+		if end < 0 {
+			return values
+		}
+		values = append(values, strings.TrimSpace(comment[:end]))
+		comment = comment[end+1:]
+	}
+}
+
+func parseGIFTQuestion(text string) (Item, error) {
+	var item Item
+
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "::") {
+		end := giftIndex(text[2:], "::")
+		if end < 0 {
+			return item, fmt.Errorf("title is not closed")
+		}
+		text = strings.TrimSpace(text[end+4:])
+	}
+
+	format := ""
+	if strings.HasPrefix(text, "[") {
+		if end := strings.Index(text, "]"); end > 0 {
+			format = text[1:end]
+			text = text[end+1:]
+		}
+	}
+
+	open := giftIndex(text, "{")
+	if open < 0 {
+		return item, fmt.Errorf("question has no answer")
+	}
+	end := giftIndex(text[open:], "}")
+	if end < 0 {
+		return item, fmt.Errorf("answer is not closed")
+	}
+	end += open
+
+	before, answer, after := text[:open], strings.TrimSpace(text[open+1:end]), text[end+1:]
+	question := giftUnescape(before)
+	if strings.TrimSpace(after) != "" {
+		//missing word question, the answer takes the place of the blank
+		question = strings.TrimSpace(question) + " _____ " + strings.TrimSpace(giftUnescape(after))
+	}
+	if format == "html" {
+		question = moodlePlainText(moodleText{Format: format, Text: question})
+	}
+	if format == "markdown" {
+		item.Format = TextMarkdown
+	}
+	item.Question = strings.TrimSpace(question)
+
+	switch {
+	case answer == "":
+		return item, fmt.Errorf("essay question is not supported")
+	case strings.HasPrefix(answer, "#"):
+		return item, fmt.Errorf("numerical question is not supported")
+	}
+
+	switch strings.ToUpper(strings.SplitN(answer, "#", 2)[0]) {
+	case "T", "TRUE", "F", "FALSE":
+		item.Type = TypeSingleChoice
+		item.Choices = []string{"True", "False"}
+		item.AnswerKeys = []int{1}
+		if strings.HasPrefix(strings.ToUpper(answer), "F") {
+			item.AnswerKeys = []int{2}
+		}
+		return item, nil
+	}
+
+	answers, err := parseGIFTAnswers(answer)
+	if err != nil {
+		return item, err
+	}
+
+	if strings.Contains(answers[0].text, "->") {
+		return parseGIFTMatching(item, answers)
+	}
+
+	hasWrong, hasRight, weighted := false, false, false
+	for _, v := range answers {
+		if !v.correct {
+			hasWrong = true
+		} else {
+			hasRight = true
+		}
+		if v.weight != nil {
+			weighted = true
+		}
+	}
+
+	if !hasWrong {
+		item.Type = TypeTextEntry
+		for _, v := range answers {
+			if v.weight == nil || *v.weight == 100 {
+				item.Answer = v.text
+				return item, nil
+			}
+		}
+		return item, fmt.Errorf("short answer question has no full mark answer")
+	}
+
+	item.Type = TypeSingleChoice
+	for k, v := range answers {
+		item.Choices = append(item.Choices, v.text)
+		if v.correct || (v.weight != nil && *v.weight > 0) {
+			item.AnswerKeys = append(item.AnswerKeys, k+1)
+		}
+	}
+	//multiple answer questions weight every answer and have no answer written with =, even with one right answer
+	if weighted && (len(item.AnswerKeys) > 1 || (!hasRight && len(item.AnswerKeys) == 1)) {
+		item.Type = TypeMultipleChoice
+	}
+	if item.Type == TypeSingleChoice && len(item.AnswerKeys) != 1 {
+		return item, fmt.Errorf("single choice question has exactly one right answer")
+	}
+
+	return item, nil
+}
+
+// parseGIFTMatching read the pairs of a matching question written as =choice -> match, a pair without choice is a
+// distractor. Moodle grades every pair on its own, so the item gets partial credit unless a comment says otherwise.
+func parseGIFTMatching(item Item, answers []giftAnswer) (Item, error) {
+	item.Type = TypeMatching
+	item.PartialCredit = true
+	for k, v := range answers {
+		pair := strings.SplitN(v.text, "->", 2)
+		if !v.correct || len(pair) != 2 {
+			return item, fmt.Errorf("answer %d of matching question must be written as =choice -> match", k+1)
+		}
+		if strings.TrimSpace(pair[1]) == "" {
+			return item, fmt.Errorf("answer %d of matching question has no match", k+1)
+		}
+		item.Choices = append(item.Choices, strings.TrimSpace(pair[0]))
+		item.Matches = append(item.Matches, strings.TrimSpace(pair[1]))
+	}
+
+	return item, nil
+}
+
+func parseGIFTAnswers(text string) ([]giftAnswer, error) {
+	var answers []giftAnswer
+	var current *giftAnswer
+	var buf strings.Builder
+
+	end := func() {
+		if current != nil {
+			value := buf.String()
+			if feedback := giftIndex(value, "#"); feedback >= 0 {
+				value = value[:feedback]
+			}
+			current.text = strings.TrimSpace(giftUnescape(value))
+			answers = append(answers, *current)
+		}
+		buf.Reset()
+	}
+
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		if ch == '\\' && i+1 < len(text) {
+			buf.WriteByte(ch)
+			buf.WriteByte(text[i+1])
+			i++
+			continue
+		}
+		if ch != '=' && ch != '~' {
+			if current == nil && !isSpace(ch) {
+				return nil, fmt.Errorf("answer must start with = or ~")
+			}
+			buf.WriteByte(ch)
+			continue
+		}
+
+		end()
+		current = &giftAnswer{correct: ch == '='}
+		if rest := text[i+1:]; strings.HasPrefix(rest, "%") {
+			closing := strings.Index(rest[1:], "%")
+			if closing < 0 {
+				return nil, fmt.Errorf("answer weight is not closed")
+			}
+			weight, err := strconv.ParseFloat(rest[1:closing+1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("answer weight %s is not a number", rest[1:closing+1])
+			}
+			current.weight = &weight
+			i += closing + 2
+		}
+	}
+	end()
+
+	if len(answers) == 0 {
+		return nil, fmt.Errorf("question has no answer")
+	}
+
+	return answers, nil
+}
+
+// WriteGIFT write items as a GIFT file, a category line is written every time the category changes.
+// Items which cannot be written are skipped and reported.
+func WriteGIFT(w io.Writer, items []Item) ([]ItemError, error) {
+	var buf bytes.Buffer
+	var itemErrors []ItemError
+
+	category := ""
+	for k, v := range items {
+		if v.Category != category && v.Category != "" {
+			category = v.Category
+			fmt.Fprintf(&buf, "$CATEGORY: %s\n\n", FormatCategory(category))
+		}
+
+		var answers []string
+		switch v.Type {
+		case "", TypeSingleChoice, TypeMultipleChoice:
+			correct := make(map[int]bool)
+			for _, key := range v.AnswerKeys {
+				correct[key] = true
+			}
+			for key, choice := range v.Choices {
+				switch {
+				case v.Type == TypeMultipleChoice && correct[key+1]:
+					answers = append(answers, fmt.Sprintf("~%%%s%%%s", moodleFraction(len(v.AnswerKeys)), giftEscape(choice)))
+				case v.Type == TypeMultipleChoice:
+					answers = append(answers, fmt.Sprintf("~%%-100%%%s", giftEscape(choice)))
+				case correct[key+1]:
+					answers = append(answers, "="+giftEscape(choice))
+				default:
+					answers = append(answers, "~"+giftEscape(choice))
+				}
+			}
+
+		case TypeTextEntry:
+			answers = append(answers, "="+giftEscape(v.Answer))
+
+		case TypeMatching:
+			for key, choice := range v.Choices {
+				match := ""
+				if key < len(v.Matches) {
+					match = v.Matches[key]
+				}
+				answers = append(answers, fmt.Sprintf("=%s -> %s", giftEscape(choice), giftEscape(match)))
+			}
+
+		default:
+			itemErrors = append(itemErrors, ItemError{Index: k, Errors: []string{fmt.Sprintf("type %s cannot be converted to gift", v.Type)}})
+			continue
+		}
+
+		for _, tag := range v.Tags {
+			fmt.Fprintf(&buf, "// [tag:%s]\n", strings.Replace(tag, "]", "", -1))
+		}
+		if v.Type == TypeMatching && !v.PartialCredit {
+			buf.WriteString("// [partial_credit:false]\n")
+		}
+		giftFormat := "plain"
+		if v.Format == TextMarkdown || v.Format == TextMarkdownMath {
+			giftFormat = "markdown"
+		}
+		fmt.Fprintf(&buf, "::%s::[%s]%s {\n", giftEscape(truncate(v.Question, 50)), giftFormat, giftEscape(v.Question))
+		for _, answer := range answers {
+			fmt.Fprintf(&buf, "\t%s\n", answer)
+		}
+		buf.WriteString("}\n\n")
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return itemErrors, err
+}
+
+// giftIndex the index of the first unescaped sub in text, or -1
+func giftIndex(text string, sub string) int {
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(text[i:], sub) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// giftEscape escape the special characters, backslashes and new lines of text
+func giftEscape(text string) string {
+	var buf strings.Builder
+	for _, ch := range text {
+		switch {
+		case ch == '\\':
+			buf.WriteString(`\\`)
+		case ch == '\n':
+			buf.WriteString(`\n`)
+		case ch == '\r':
+		case strings.ContainsRune(giftSpecial, ch):
+			buf.WriteByte('\\')
+			buf.WriteRune(ch)
+		default:
+			buf.WriteRune(ch)
+		}
+	}
+
+	return buf.String()
+}
+
+// giftUnescape reverse giftEscape, unknown escapes are kept as they are
+func giftUnescape(text string) string {
+	var buf strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' || i+1 == len(text) {
+			buf.WriteByte(text[i])
+			continue
+		}
+
+		next := text[i+1]
+		switch {
+		case next == 'n':
+			buf.WriteByte('\n')
+		case next == '\\' || strings.IndexByte(giftSpecial, next) >= 0:
+			buf.WriteByte(next)
+		default:
+			buf.WriteByte('\\')
+			buf.WriteByte(next)
+		}
+		i++
+	}
+
+	return buf.String()
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
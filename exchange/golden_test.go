@@ -0,0 +1,179 @@
+package exchange
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// checkGolden compare data with the golden file name in testdata, the file is written with -update
+func checkGolden(t *testing.T, name string, data []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Errorf("%s differs from the golden file:\n%s", name, data)
+	}
+}
+
+// readGolden read the items of the file name in testdata
+func readGolden(t *testing.T, name string, read func(r *os.File) ([]Item, []ItemError, error)) []Item {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	items, itemErrors, err := read(f)
+	if err != nil || len(itemErrors) > 0 {
+		t.Fatalf("read %s: %v %v", name, err, itemErrors)
+	}
+	for k := range items {
+		items[k].Row = 0
+	}
+
+	return items
+}
+
+func TestReadGIFTEscapes(t *testing.T) {
+	items := readGolden(t, "escape.gift", func(f *os.File) ([]Item, []ItemError, error) { return ReadGIFT(f) })
+
+	want := []Item{
+		{
+			Question:   "Is {x} a set with 1 = 1?",
+			Type:       TypeSingleChoice,
+			Category:   "Escapes",
+			Tags:       []string{"escape"},
+			Choices:    []string{"Yes, {x} ~ set", "No # never"},
+			AnswerKeys: []int{1},
+		},
+		{
+			Question: `Time is 10:30 and a path is C:\temp`,
+			Type:     TypeTextEntry,
+			Category: "Escapes",
+			Answer:   `a\b:c`,
+		},
+		{
+			Question: "Line one\nline two with a \\n that stays",
+			Type:     TypeTextEntry,
+			Category: "Escapes",
+			Answer:   "ok",
+		},
+		{
+			Question: `Escaped \x is kept`,
+			Type:     TypeTextEntry,
+			Category: "Escapes",
+			Answer:   `\x`,
+		},
+		{
+			Question:   "Pick ~ all",
+			Type:       TypeMultipleChoice,
+			Category:   "Escapes",
+			Choices:    []string{"one = 1", "two#2", "none"},
+			AnswerKeys: []int{1, 2},
+		},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("items are\n%#v\nwant\n%#v", items, want)
+	}
+}
+
+func TestWriteGIFTEscapes(t *testing.T) {
+	items := []Item{
+		{
+			Question:   "Is {x} = 1 # 2 ~ 3: yes?",
+			Type:       TypeSingleChoice,
+			Category:   "Escapes/Gift",
+			Tags:       []string{"escape", "brackets]"},
+			Choices:    []string{`a\b`, "{c}"},
+			AnswerKeys: []int{2},
+		},
+		{
+			Question: "Line one\r\nline two",
+			Type:     TypeTextEntry,
+			Format:   TextMarkdown,
+			Category: "Escapes/Gift",
+			Answer:   "x:=1",
+		},
+	}
+
+	var buf bytes.Buffer
+	if _, err := WriteGIFT(&buf, items); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "escape.golden.gift", buf.Bytes())
+}
+
+// html is reduced to its text, entities and non breaking spaces included
+func TestReadMoodleEntities(t *testing.T) {
+	items := readGolden(t, "cdata.xml", func(f *os.File) ([]Item, []ItemError, error) { return ReadMoodle(f) })
+
+	want := []Item{
+		{
+			Question:   "Is 1 < 2 & 3 > 2? Second]]> paragraph",
+			Type:       TypeSingleChoice,
+			Category:   "Entities & CDATA",
+			Choices:    []string{"Yes & no", "No"},
+			AnswerKeys: []int{1},
+		},
+		{
+			Question: "5 < x && x > 2 \u2013 say \"x\"",
+			Type:     TypeTextEntry,
+			Category: "Entities & CDATA",
+			Answer:   `a<b & "c"`,
+		},
+		{
+			Question: "Line *one*\n  & <line> two",
+			Type:     TypeTextEntry,
+			Format:   TextMarkdown,
+			Category: "Entities & CDATA",
+			Answer:   "x",
+		},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("items are\n%#v\nwant\n%#v", items, want)
+	}
+}
+
+func TestWriteMoodleEntities(t *testing.T) {
+	items := []Item{
+		{
+			Question:   `Is 1 < 2 & "3" > 'x'?`,
+			Type:       TypeSingleChoice,
+			Category:   "Entities & <more>",
+			Tags:       []string{"a&b"},
+			Choices:    []string{"<yes>", "]]>"},
+			AnswerKeys: []int{1},
+		},
+		{
+			Question: "Line *one*\nline two",
+			Type:     TypeTextEntry,
+			Format:   TextMarkdown,
+			Category: "Entities & <more>",
+			Answer:   "a&b",
+		},
+	}
+
+	var buf bytes.Buffer
+	if _, err := WriteMoodle(&buf, items); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "entities.golden.xml", buf.Bytes())
+}
@@ -0,0 +1,51 @@
+package exchange
+
+// type of the items, they are the question types every format can carry
+const (
+	TypeSingleChoice   = "single_choice"
+	TypeMultipleChoice = "multiple_choice"
+	TypeTextEntry      = "text_entry"
+	TypeMatching       = "matching"
+	TypeOrdering       = "ordering"
+)
+
+// format of the text of the items and their choices, as the format of a question
+const (
+	TextPlain        = "plain"
+	TextMarkdown     = "markdown"
+	TextMarkdownMath = "markdown_math"
+)
+
+// Item question in a format independent shape, every import and export format is converted from and to it
+type Item struct {
+	Question   string
+	Type       string
+	Format     string
+	Difficulty string
+	TopicID    string
+	// category path of the item, subject and topic names separated by /
+	Category string
+	Tags     []string
+	Choices  []string
+
+	// keys of the correct choices, starting from 1
+	AnswerKeys []int
+	// accepted answer of text entry items
+	Answer string
+	// item paired with every choice of matching items, choices without text are distractors.
+	// The choices of ordering items are in the right order.
+	Matches []string
+	// matching and ordering items get a point for every right pair or position
+	PartialCredit bool
+
+	// row or position of the item in the imported file, starting from 1
+	Row int
+}
+
+// ItemError errors of an item which cannot be read, Index is the position of the item in the file
+type ItemError struct {
+	Index  int      `json:"index"`
+	Row    int      `json:"row,omitempty"`
+	Source string   `json:"source,omitempty"`
+	Errors []string `json:"errors"`
+}
@@ -0,0 +1,387 @@
+package exchange
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// prefixes of moodle category paths which are not part of the category
+var moodleContexts = map[string]bool{
+	"$system$": true,
+	"$course$": true,
+	"$module$": true,
+	"$cat1$":   true,
+	"top":      true,
+}
+
+// grading types of the ordering plugin, partial credit is read from any other type
+const (
+	moodleAllOrNothing     = "ALL_OR_NOTHING"
+	moodleAbsolutePosition = "ABSOLUTE_POSITION"
+)
+
+// html tags, block tags are replaced by a space and inline tags are removed
+var (
+	htmlBlockTag = regexp.MustCompile(`(?i)</?(p|br|div|li|ul|ol|h[1-6]|table|tr|td|th)\b[^>]*>`)
+	htmlTag      = regexp.MustCompile(`<[^>]*>`)
+)
+
+type moodleText struct {
+	Format string `xml:"format,attr"`
+	Text   string `xml:"text"`
+}
+
+type moodleQuestion struct {
+	Type         string     `xml:"type,attr"`
+	Category     moodleText `xml:"category"`
+	Name         moodleText `xml:"name"`
+	QuestionText moodleText `xml:"questiontext"`
+	Single       string     `xml:"single"`
+	Answers      []struct {
+		Fraction string `xml:"fraction,attr"`
+		Format   string `xml:"format,attr"`
+		Text     string `xml:"text"`
+	} `xml:"answer"`
+	Tags []string `xml:"tags>tag>text"`
+
+	//pairs of matching questions, and how ordering questions are graded
+	Subquestions []struct {
+		Format string `xml:"format,attr"`
+		Text   string `xml:"text"`
+		Answer string `xml:"answer>text"`
+	} `xml:"subquestion"`
+	GradingType string `xml:"gradingtype"`
+	//written for matching questions graded as a whole, moodle itself ignores it
+	PartialCredit string `xml:"partialcredit"`
+}
+
+// ReadMoodle read the questions of a Moodle XML file.
+// Multichoice, truefalse, shortanswer, matching and ordering questions are converted, other questions are reported.
+func ReadMoodle(r io.Reader) ([]Item, []ItemError, error) {
+	var quiz struct {
+		Questions []moodleQuestion `xml:"question"`
+	}
+	if err := xml.NewDecoder(r).Decode(&quiz); err != nil {
+		return nil, nil, fmt.Errorf("invalid moodle xml: %s", err)
+	}
+
+	var items []Item
+	var itemErrors []ItemError
+	category := ""
+	for k, v := range quiz.Questions {
+		if v.Type == "category" {
+			category = ParseCategory(v.Category.Text)
+			continue
+		}
+
+		item, err := moodleItem(v)
+		if err != nil {
+			itemErrors = append(itemErrors, ItemError{
+				Index:  k,
+				Row:    k + 1,
+				Source: v.Name.Text,
+				Errors: []string{err.Error()},
+			})
+			continue
+		}
+
+		item.Category = category
+		item.Row = k + 1
+		items = append(items, item)
+	}
+
+	return items, itemErrors, nil
+}
+
+func moodleItem(q moodleQuestion) (Item, error) {
+	item := Item{
+		Question: moodlePlainText(q.QuestionText),
+		Tags:     q.Tags,
+	}
+	//markdown is kept as it is, its line breaks matter
+	if q.QuestionText.Format == "markdown" {
+		item.Question = strings.TrimSpace(q.QuestionText.Text)
+		item.Format = TextMarkdown
+	}
+
+	switch q.Type {
+	case "multichoice", "truefalse":
+		item.Type = TypeSingleChoice
+		if q.Type == "multichoice" && !isMoodleTrue(q.Single) {
+			item.Type = TypeMultipleChoice
+		}
+
+		best := 0.0
+		for _, v := range q.Answers {
+			if fraction, _ := strconv.ParseFloat(v.Fraction, 64); fraction > best {
+				best = fraction
+			}
+		}
+		for k, v := range q.Answers {
+			text := moodlePlainText(moodleText{Format: v.Format, Text: v.Text})
+			if q.Type == "truefalse" {
+				text = strings.Title(strings.ToLower(text))
+			}
+			item.Choices = append(item.Choices, text)
+
+			fraction, _ := strconv.ParseFloat(v.Fraction, 64)
+			if (item.Type == TypeMultipleChoice && fraction > 0) || (item.Type == TypeSingleChoice && fraction > 0 && fraction == best) {
+				item.AnswerKeys = append(item.AnswerKeys, k+1)
+			}
+		}
+		if item.Type == TypeSingleChoice && len(item.AnswerKeys) > 1 {
+			return item, fmt.Errorf("single choice question has more than one full mark answer")
+		}
+
+	case "shortanswer":
+		item.Type = TypeTextEntry
+		for _, v := range q.Answers {
+			if fraction, _ := strconv.ParseFloat(v.Fraction, 64); fraction == 100 {
+				item.Answer = moodlePlainText(moodleText{Format: v.Format, Text: v.Text})
+				break
+			}
+		}
+		if item.Answer == "" {
+			return item, fmt.Errorf("short answer question has no full mark answer")
+		}
+
+	case "matching":
+		//moodle grades every pair on its own, subquestions without text are distractors
+		item.Type = TypeMatching
+		item.PartialCredit = q.PartialCredit == "" || isMoodleTrue(q.PartialCredit)
+		for _, v := range q.Subquestions {
+			item.Choices = append(item.Choices, moodlePlainText(moodleText{Format: v.Format, Text: v.Text}))
+			item.Matches = append(item.Matches, strings.TrimSpace(v.Answer))
+		}
+		if len(item.Choices) == 0 {
+			return item, fmt.Errorf("matching question has no subquestion")
+		}
+
+	case "ordering":
+		//the fraction of the answers of the ordering plugin is their position
+		item.Type = TypeOrdering
+		item.PartialCredit = q.GradingType != "" && q.GradingType != moodleAllOrNothing
+		answers := q.Answers
+		sort.SliceStable(answers, func(i, j int) bool {
+			a, _ := strconv.ParseFloat(answers[i].Fraction, 64)
+			b, _ := strconv.ParseFloat(answers[j].Fraction, 64)
+			return a < b
+		})
+		for _, v := range answers {
+			item.Choices = append(item.Choices, moodlePlainText(moodleText{Format: v.Format, Text: v.Text}))
+		}
+
+	default:
+		return item, fmt.Errorf("question type %s is not supported", q.Type)
+	}
+
+	return item, nil
+}
+
+// WriteMoodle write items as a Moodle XML file, a category question is written every time the category changes.
+// Items which cannot be written are skipped and reported.
+func WriteMoodle(w io.Writer, items []Item) ([]ItemError, error) {
+	var buf bytes.Buffer
+	var itemErrors []ItemError
+
+	buf.WriteString(xml.Header)
+	buf.WriteString("<quiz>\n")
+
+	category := ""
+	for k, v := range items {
+		if v.Category != category && v.Category != "" {
+			category = v.Category
+			fmt.Fprintf(&buf, "  <question type=\"category\">\n    <category><text>%s</text></category>\n  </question>\n",
+				escapeXML(FormatCategory(category)))
+		}
+
+		var answers []string
+		questionType := "multichoice"
+		options := fmt.Sprintf("    <single>%t</single>\n    <shuffleanswers>0</shuffleanswers>\n    <answernumbering>abc</answernumbering>\n",
+			v.Type != TypeMultipleChoice)
+		switch v.Type {
+		case "", TypeSingleChoice, TypeMultipleChoice:
+			correct := make(map[int]bool)
+			for _, key := range v.AnswerKeys {
+				correct[key] = true
+			}
+			fraction := "100"
+			if v.Type == TypeMultipleChoice {
+				fraction = moodleFraction(len(v.AnswerKeys))
+			}
+			for key, choice := range v.Choices {
+				f := "0"
+				if correct[key+1] {
+					f = fraction
+				} else if v.Type == TypeMultipleChoice {
+					f = "-100"
+				}
+				answers = append(answers, moodleAnswer(f, moodleFormat(v.Format), choice))
+			}
+
+		case TypeTextEntry:
+			questionType = "shortanswer"
+			options = "    <usecase>0</usecase>\n"
+			answers = append(answers, moodleAnswer("100", "plain_text", v.Answer))
+
+		case TypeMatching:
+			//moodle always grades the pairs on their own, partialcredit keeps the grading of the item when it is read back
+			questionType = "matching"
+			options = fmt.Sprintf("    <shuffleanswers>true</shuffleanswers>\n    <partialcredit>%t</partialcredit>\n", v.PartialCredit)
+			for key, choice := range v.Choices {
+				match := ""
+				if key < len(v.Matches) {
+					match = v.Matches[key]
+				}
+				answers = append(answers, fmt.Sprintf("    <subquestion format=\"%s\"><text>%s</text><answer><text>%s</text></answer></subquestion>\n",
+					moodleFormat(v.Format), escapeXML(choice), escapeXML(match)))
+			}
+
+		case TypeOrdering:
+			questionType = "ordering"
+			gradingType := moodleAllOrNothing
+			if v.PartialCredit {
+				gradingType = moodleAbsolutePosition
+			}
+			options = fmt.Sprintf("    <layouttype>VERTICAL</layouttype>\n    <selecttype>ALL</selecttype>\n    <selectcount>0</selectcount>\n    <gradingtype>%s</gradingtype>\n", gradingType)
+			for key, choice := range v.Choices {
+				answers = append(answers, moodleAnswer(strconv.Itoa(key+1), moodleFormat(v.Format), choice))
+			}
+
+		default:
+			itemErrors = append(itemErrors, ItemError{Index: k, Errors: []string{fmt.Sprintf("type %s cannot be converted to moodle xml", v.Type)}})
+			continue
+		}
+
+		fmt.Fprintf(&buf, "  <question type=\"%s\">\n", questionType)
+		fmt.Fprintf(&buf, "    <name><text>%s</text></name>\n", escapeXML(truncate(v.Question, 50)))
+		fmt.Fprintf(&buf, "    <questiontext format=\"%s\"><text>%s</text></questiontext>\n", moodleFormat(v.Format), escapeXML(v.Question))
+		buf.WriteString("    <defaultgrade>1</defaultgrade>\n")
+		buf.WriteString(options)
+		for _, answer := range answers {
+			buf.WriteString(answer)
+		}
+		if len(v.Tags) > 0 {
+			buf.WriteString("    <tags>\n")
+			for _, tag := range v.Tags {
+				fmt.Fprintf(&buf, "      <tag><text>%s</text></tag>\n", escapeXML(tag))
+			}
+			buf.WriteString("    </tags>\n")
+		}
+		buf.WriteString("  </question>\n")
+	}
+	buf.WriteString("</quiz>\n")
+
+	_, err := w.Write(buf.Bytes())
+	return itemErrors, err
+}
+
+func moodleAnswer(fraction string, format string, text string) string {
+	return fmt.Sprintf("    <answer fraction=\"%s\" format=\"%s\"><text>%s</text></answer>\n", fraction, format, escapeXML(text))
+}
+
+// moodleFormat the moodle text format of an item format, math is written in markdown as moodle filters render it
+func moodleFormat(format string) string {
+	if format == TextMarkdown || format == TextMarkdownMath {
+		return "markdown"
+	}
+
+	return "plain_text"
+}
+
+// moodleFraction the fraction of every correct answer of a multiple choice question, rounded as moodle does
+func moodleFraction(correct int) string {
+	if correct == 0 {
+		return "0"
+	}
+
+	fraction := math.Floor(100/float64(correct)*100000+0.5) / 100000
+	return strconv.FormatFloat(fraction, 'f', -1, 64)
+}
+
+// moodlePlainText the text of a moodle text element, html is reduced to its text
+func moodlePlainText(text moodleText) string {
+	value := text.Text
+	if text.Format == "" || text.Format == "html" || text.Format == "moodle_auto_format" {
+		value = htmlBlockTag.ReplaceAllString(value, " ")
+		value = html.UnescapeString(htmlTag.ReplaceAllString(value, ""))
+	}
+
+	return strings.TrimSpace(strings.Join(strings.Fields(value), " "))
+}
+
+func isMoodleTrue(value string) bool {
+	value = strings.ToLower(strings.TrimSpace(value))
+	return value == "true" || value == "1"
+}
+
+// ParseCategory convert a moodle category path into a category, the context prefixes are removed.
+// A / inside a category name is written as // in the path.
+func ParseCategory(path string) string {
+	var names []string
+	for _, name := range splitCategory(strings.TrimSpace(path)) {
+		name = strings.TrimSpace(name)
+		if name == "" || (len(names) == 0 && moodleContexts[name]) {
+			continue
+		}
+		names = append(names, strings.Replace(name, "/", "//", -1))
+	}
+
+	return strings.Join(names, "/")
+}
+
+// FormatCategory convert a category into a moodle category path of the course
+func FormatCategory(category string) string {
+	return "$course$/top/" + category
+}
+
+// CategoryNames the names of a category, the first one is the subject and the last one the topic
+func CategoryNames(category string) []string {
+	var names []string
+	for _, name := range splitCategory(category) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// JoinCategory build a category from names, / inside the names is escaped
+func JoinCategory(names ...string) string {
+	var escaped []string
+	for _, name := range names {
+		escaped = append(escaped, strings.Replace(name, "/", "//", -1))
+	}
+
+	return strings.Join(escaped, "/")
+}
+
+// splitCategory split a category path on single /, a double // is kept as a / inside the name
+func splitCategory(path string) []string {
+	var names []string
+	var name strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] != '/' {
+			name.WriteByte(path[i])
+			continue
+		}
+		if i+1 < len(path) && path[i+1] == '/' {
+			name.WriteByte('/')
+			i++
+			continue
+		}
+		names = append(names, name.String())
+		name.Reset()
+	}
+
+	return append(names, name.String())
+}
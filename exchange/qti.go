@@ -0,0 +1,629 @@
+package exchange
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	qtiNamespace      = "http://www.imsglobal.org/xsd/imsqti_v2p1"
+	qtiMatchCorrect   = "http://www.imsglobal.org/question/qti_v2p1/rptemplates/match_correct"
+	qtiMapResponse    = "http://www.imsglobal.org/question/qti_v2p1/rptemplates/map_response"
+	qtiItemResource   = "imsqti_item_xmlv2p1"
+	qtiTestResource   = "imsqti_test_xmlv2p1"
+	qtiManifest       = "imsmanifest.xml"
+	qtiTestHref       = "assessment.xml"
+	qtiResponse       = "RESPONSE"
+	qtiChoicePrefix   = "CHOICE_"
+	qtiMatchPrefix    = "MATCH_"
+	qtiItemIdentifier = "ITEM_"
+)
+
+type qtiManifestXML struct {
+	XMLName   xml.Name `xml:"manifest"`
+	Resources []struct {
+		Identifier string `xml:"identifier,attr"`
+		Type       string `xml:"type,attr"`
+		Href       string `xml:"href,attr"`
+	} `xml:"resources>resource"`
+}
+
+type qtiTestXML struct {
+	Title    string `xml:"title,attr"`
+	ItemRefs []struct {
+		Href string `xml:"href,attr"`
+	} `xml:"testPart>assessmentSection>assessmentItemRef"`
+}
+
+type qtiResponseDeclaration struct {
+	Identifier      string   `xml:"identifier,attr"`
+	Cardinality     string   `xml:"cardinality,attr"`
+	BaseType        string   `xml:"baseType,attr"`
+	CorrectResponse []string `xml:"correctResponse>value"`
+	Mapping         []struct {
+		MapKey string `xml:"mapKey,attr"`
+	} `xml:"mapping>mapEntry"`
+}
+
+type qtiItemXML struct {
+	XMLName              xml.Name                 `xml:"assessmentItem"`
+	Identifier           string                   `xml:"identifier,attr"`
+	Title                string                   `xml:"title,attr"`
+	ResponseDeclarations []qtiResponseDeclaration `xml:"responseDeclaration"`
+	ResponseProcessing   struct {
+		Template string `xml:"template,attr"`
+		//indexes of the response checked by a custom response processing
+		Indexes []struct {
+			N string `xml:"n,attr"`
+		} `xml:"responseCondition>responseIf>match>index"`
+	} `xml:"responseProcessing"`
+}
+
+// qtiBody content of the itemBody of an item which can be mapped to an Item
+type qtiBody struct {
+	text         strings.Builder
+	interactions []string
+	response     string
+	maxChoices   string
+	choiceIDs    []string
+	choices      []string
+	//identifiers and texts of the choices of every simpleMatchSet of a matchInteraction
+	matchIDs   [][]string
+	matchTexts [][]string
+}
+
+// WriteQTI write items as an IMS QTI 2.1 content package.
+// When title is set the package has an assessment test referring every item.
+// Items which cannot be written are skipped and reported, their Index is the position in items.
+func WriteQTI(w io.Writer, title string, items []Item) ([]ItemError, error) {
+	var itemErrors []ItemError
+	var hrefs []string
+
+	archive := zip.NewWriter(w)
+	for k, v := range items {
+		identifier := fmt.Sprintf("%s%d", qtiItemIdentifier, k+1)
+		href := fmt.Sprintf("items/%s.xml", strings.ToLower(identifier))
+
+		data, err := qtiItem(identifier, v)
+		if err != nil {
+			itemErrors = append(itemErrors, ItemError{Index: k, Errors: []string{err.Error()}})
+			continue
+		}
+
+		f, err := archive.Create(href)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(data); err != nil {
+			return nil, err
+		}
+		hrefs = append(hrefs, href)
+	}
+
+	if title != "" {
+		f, err := archive.Create(qtiTestHref)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(qtiTest(title, hrefs)); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := archive.Create(qtiManifest)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(qtiManifestFile(title != "", hrefs)); err != nil {
+		return nil, err
+	}
+
+	return itemErrors, archive.Close()
+}
+
+func qtiItem(identifier string, item Item) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	fmt.Fprintf(&buf, `<assessmentItem xmlns="%s" identifier="%s" title="%s" adaptive="false" timeDependent="false">`,
+		qtiNamespace, identifier, escapeXML(truncate(item.Question, 100)))
+
+	template := qtiMatchCorrect
+
+	switch item.Type {
+	case "", TypeSingleChoice, TypeMultipleChoice:
+		cardinality, maxChoices := "single", 1
+		if item.Type == TypeMultipleChoice {
+			cardinality, maxChoices = "multiple", 0
+		}
+
+		fmt.Fprintf(&buf, `<responseDeclaration identifier="%s" cardinality="%s" baseType="identifier"><correctResponse>`, qtiResponse, cardinality)
+		for _, key := range item.AnswerKeys {
+			fmt.Fprintf(&buf, `<value>%s%d</value>`, qtiChoicePrefix, key)
+		}
+		buf.WriteString(`</correctResponse></responseDeclaration>`)
+		buf.WriteString(`<outcomeDeclaration identifier="SCORE" cardinality="single" baseType="float"/>`)
+
+		fmt.Fprintf(&buf, `<itemBody><choiceInteraction responseIdentifier="%s" shuffle="false" maxChoices="%d"><prompt>%s</prompt>`,
+			qtiResponse, maxChoices, escapeXML(item.Question))
+		for k, v := range item.Choices {
+			fmt.Fprintf(&buf, `<simpleChoice identifier="%s%d">%s</simpleChoice>`, qtiChoicePrefix, k+1, escapeXML(v))
+		}
+		buf.WriteString(`</choiceInteraction></itemBody>`)
+
+	case TypeTextEntry:
+		fmt.Fprintf(&buf, `<responseDeclaration identifier="%s" cardinality="single" baseType="string"><correctResponse><value>%s</value></correctResponse></responseDeclaration>`,
+			qtiResponse, escapeXML(item.Answer))
+		buf.WriteString(`<outcomeDeclaration identifier="SCORE" cardinality="single" baseType="float"/>`)
+		fmt.Fprintf(&buf, `<itemBody><p>%s</p><p><textEntryInteraction responseIdentifier="%s" expectedLength="%d"/></p></itemBody>`,
+			escapeXML(item.Question), qtiResponse, len(item.Answer)+5)
+
+	case TypeMatching:
+		//a match shared by several choices is a single choice of the second set, choices without text are only there
+		//as distractors. Partial credit maps every right pair to a point.
+		var matches []string
+		matchIDs := make(map[string]string)
+		for _, v := range item.Matches {
+			if _, ok := matchIDs[v]; !ok {
+				matches = append(matches, v)
+				matchIDs[v] = fmt.Sprintf("%s%d", qtiMatchPrefix, len(matches))
+			}
+		}
+		var pairs []string
+		for k, v := range item.Choices {
+			if v != "" && k < len(item.Matches) {
+				pairs = append(pairs, fmt.Sprintf("%s%d %s", qtiChoicePrefix, k+1, matchIDs[item.Matches[k]]))
+			}
+		}
+
+		fmt.Fprintf(&buf, `<responseDeclaration identifier="%s" cardinality="multiple" baseType="directedPair"><correctResponse>`, qtiResponse)
+		for _, pair := range pairs {
+			fmt.Fprintf(&buf, `<value>%s</value>`, pair)
+		}
+		buf.WriteString(`</correctResponse>`)
+		if item.PartialCredit {
+			template = qtiMapResponse
+			buf.WriteString(`<mapping defaultValue="0">`)
+			for _, pair := range pairs {
+				fmt.Fprintf(&buf, `<mapEntry mapKey="%s" mappedValue="1"/>`, pair)
+			}
+			buf.WriteString(`</mapping>`)
+		}
+		buf.WriteString(`</responseDeclaration>`)
+		buf.WriteString(`<outcomeDeclaration identifier="SCORE" cardinality="single" baseType="float"/>`)
+
+		fmt.Fprintf(&buf, `<itemBody><matchInteraction responseIdentifier="%s" shuffle="true" maxAssociations="%d"><prompt>%s</prompt><simpleMatchSet>`,
+			qtiResponse, len(pairs), escapeXML(item.Question))
+		for k, v := range item.Choices {
+			if v != "" {
+				fmt.Fprintf(&buf, `<simpleAssociableChoice identifier="%s%d" matchMax="1">%s</simpleAssociableChoice>`, qtiChoicePrefix, k+1, escapeXML(v))
+			}
+		}
+		buf.WriteString(`</simpleMatchSet><simpleMatchSet>`)
+		for _, v := range matches {
+			fmt.Fprintf(&buf, `<simpleAssociableChoice identifier="%s" matchMax="0">%s</simpleAssociableChoice>`, matchIDs[v], escapeXML(v))
+		}
+		buf.WriteString(`</simpleMatchSet></matchInteraction></itemBody>`)
+
+	case TypeOrdering:
+		//the response processing templates have no point by position, partial credit is written as a custom
+		//response processing adding a point for every choice at its index
+		if item.PartialCredit {
+			template = ""
+		}
+		fmt.Fprintf(&buf, `<responseDeclaration identifier="%s" cardinality="ordered" baseType="identifier"><correctResponse>`, qtiResponse)
+		for k := range item.Choices {
+			fmt.Fprintf(&buf, `<value>%s%d</value>`, qtiChoicePrefix, k+1)
+		}
+		buf.WriteString(`</correctResponse></responseDeclaration>`)
+		buf.WriteString(`<outcomeDeclaration identifier="SCORE" cardinality="single" baseType="float"/>`)
+
+		fmt.Fprintf(&buf, `<itemBody><orderInteraction responseIdentifier="%s" shuffle="true"><prompt>%s</prompt>`,
+			qtiResponse, escapeXML(item.Question))
+		for k, v := range item.Choices {
+			fmt.Fprintf(&buf, `<simpleChoice identifier="%s%d">%s</simpleChoice>`, qtiChoicePrefix, k+1, escapeXML(v))
+		}
+		buf.WriteString(`</orderInteraction></itemBody>`)
+
+	default:
+		return nil, fmt.Errorf("type %s cannot be converted to QTI", item.Type)
+	}
+
+	if template == "" {
+		buf.WriteString(qtiPositionProcessing(len(item.Choices)))
+	} else {
+		fmt.Fprintf(&buf, `<responseProcessing template="%s"/>`, template)
+	}
+	buf.WriteString(`</assessmentItem>`)
+	return buf.Bytes(), nil
+}
+
+// qtiPositionProcessing the response processing of an ordering item with partial credit, the score is the number of
+// choices at their right index
+func qtiPositionProcessing(total int) string {
+	var buf bytes.Buffer
+	buf.WriteString(`<responseProcessing><setOutcomeValue identifier="SCORE"><baseValue baseType="float">0</baseValue></setOutcomeValue>`)
+	for k := 1; k <= total; k++ {
+		fmt.Fprintf(&buf, `<responseCondition><responseIf><match><index n="%d"><variable identifier="%s"/></index>`+
+			`<baseValue baseType="identifier">%s%d</baseValue></match>`+
+			`<setOutcomeValue identifier="SCORE"><sum><variable identifier="SCORE"/><baseValue baseType="float">1</baseValue></sum></setOutcomeValue>`+
+			`</responseIf></responseCondition>`, k, qtiResponse, qtiChoicePrefix, k)
+	}
+	buf.WriteString(`</responseProcessing>`)
+
+	return buf.String()
+}
+
+func qtiTest(title string, hrefs []string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	fmt.Fprintf(&buf, `<assessmentTest xmlns="%s" identifier="TEST" title="%s">`, qtiNamespace, escapeXML(title))
+	buf.WriteString(`<testPart identifier="PART_1" navigationMode="nonlinear" submissionMode="simultaneous">`)
+	buf.WriteString(`<assessmentSection identifier="SECTION_1" title="Section 1" visible="true">`)
+	for k, v := range hrefs {
+		fmt.Fprintf(&buf, `<assessmentItemRef identifier="%s%d" href="%s"/>`, qtiItemIdentifier, k+1, v)
+	}
+	buf.WriteString(`</assessmentSection></testPart></assessmentTest>`)
+
+	return buf.Bytes()
+}
+
+func qtiManifestFile(hasTest bool, hrefs []string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<manifest xmlns="http://www.imsglobal.org/xsd/imscp_v1p1" identifier="MANIFEST"><organizations/><resources>`)
+	if hasTest {
+		fmt.Fprintf(&buf, `<resource identifier="TEST" type="%s" href="%s"><file href="%s"/>`, qtiTestResource, qtiTestHref, qtiTestHref)
+		for k := range hrefs {
+			fmt.Fprintf(&buf, `<dependency identifierref="%s%d"/>`, qtiItemIdentifier, k+1)
+		}
+		buf.WriteString(`</resource>`)
+	}
+	for k, v := range hrefs {
+		fmt.Fprintf(&buf, `<resource identifier="%s%d" type="%s" href="%s"><file href="%s"/></resource>`, qtiItemIdentifier, k+1, qtiItemResource, v, v)
+	}
+	buf.WriteString(`</resources></manifest>`)
+
+	return buf.Bytes()
+}
+
+// ReadQTI read the items of an IMS QTI 2.1 content package, the title of its assessment test is returned when there is one.
+// Items which cannot be converted are reported with the file they come from.
+func ReadQTI(r io.ReaderAt, size int64) (string, []Item, []ItemError, error) {
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("not a QTI package: %s", err)
+	}
+
+	files := make(map[string]*zip.File)
+	for _, f := range reader.File {
+		files[strings.TrimPrefix(f.Name, "/")] = f
+	}
+
+	title, hrefs, err := qtiResources(files)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	var items []Item
+	var itemErrors []ItemError
+	for k, href := range hrefs {
+		data, err := readZipFile(files, href)
+		if err == nil {
+			var item Item
+			item, err = parseQTIItem(data)
+			if err == nil {
+				item.Row = k + 1
+				items = append(items, item)
+				continue
+			}
+		}
+
+		itemErrors = append(itemErrors, ItemError{Index: k, Source: href, Errors: []string{err.Error()}})
+	}
+
+	return title, items, itemErrors, nil
+}
+
+// qtiResources get the title of the assessment test and the items of the package following the manifest.
+// Packages without manifest are read item by item.
+func qtiResources(files map[string]*zip.File) (string, []string, error) {
+	var hrefs []string
+	title := ""
+
+	data, err := readZipFile(files, qtiManifest)
+	if err != nil {
+		for name := range files {
+			if strings.HasSuffix(name, ".xml") {
+				hrefs = append(hrefs, name)
+			}
+		}
+		sort.Strings(hrefs)
+		return title, hrefs, nil
+	}
+
+	var manifest qtiManifestXML
+	if err := xml.Unmarshal(data, &manifest); err != nil {
+		return "", nil, fmt.Errorf("invalid %s: %s", qtiManifest, err)
+	}
+
+	for _, v := range manifest.Resources {
+		switch {
+		case strings.HasPrefix(v.Type, qtiItemResource):
+			hrefs = append(hrefs, path.Clean(v.Href))
+		case strings.HasPrefix(v.Type, qtiTestResource) && title == "":
+			var test qtiTestXML
+			if data, err := readZipFile(files, path.Clean(v.Href)); err == nil && xml.Unmarshal(data, &test) == nil {
+				title = test.Title
+			}
+		}
+	}
+
+	return title, hrefs, nil
+}
+
+func parseQTIItem(data []byte) (Item, error) {
+	var item Item
+	var doc qtiItemXML
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return item, fmt.Errorf("not an assessment item: %s", err)
+	}
+
+	body, err := parseQTIBody(data)
+	if err != nil {
+		return item, err
+	}
+	if len(body.interactions) != 1 {
+		return item, fmt.Errorf("item %s must have exactly one interaction, found %d", doc.Identifier, len(body.interactions))
+	}
+
+	var declaration *qtiResponseDeclaration
+	for k, v := range doc.ResponseDeclarations {
+		if v.Identifier == body.response {
+			declaration = &doc.ResponseDeclarations[k]
+		}
+	}
+	if declaration == nil || len(declaration.CorrectResponse) == 0 {
+		return item, fmt.Errorf("item %s has no correct response", doc.Identifier)
+	}
+
+	item.Question = strings.Join(strings.Fields(body.text.String()), " ")
+	if item.Question == "" {
+		item.Question = doc.Title
+	}
+
+	switch body.interactions[0] {
+	case "choiceInteraction":
+		item.Type = TypeSingleChoice
+		if declaration.Cardinality == "multiple" {
+			item.Type = TypeMultipleChoice
+		}
+
+		item.Choices = body.choices
+		for _, value := range declaration.CorrectResponse {
+			key := 0
+			for k, id := range body.choiceIDs {
+				if id == strings.TrimSpace(value) {
+					key = k + 1
+				}
+			}
+			if key == 0 {
+				return item, fmt.Errorf("item %s correct response %s is not a choice", doc.Identifier, value)
+			}
+			item.AnswerKeys = append(item.AnswerKeys, key)
+		}
+
+	case "textEntryInteraction":
+		item.Type = TypeTextEntry
+		item.Answer = strings.TrimSpace(declaration.CorrectResponse[0])
+
+	case "matchInteraction":
+		return parseQTIMatching(item, doc, declaration, body)
+
+	case "orderInteraction":
+		//an ordering item checking the choices at their index gets a point for every one of them
+		item.Type = TypeOrdering
+		item.PartialCredit = doc.ResponseProcessing.Template == "" && len(doc.ResponseProcessing.Indexes) > 0
+		texts := make(map[string]string)
+		for k, id := range body.choiceIDs {
+			texts[id] = body.choices[k]
+		}
+		for _, value := range declaration.CorrectResponse {
+			text, ok := texts[strings.TrimSpace(value)]
+			if !ok {
+				return item, fmt.Errorf("item %s correct response %s is not a choice", doc.Identifier, value)
+			}
+			item.Choices = append(item.Choices, text)
+		}
+		if len(item.Choices) != len(body.choices) {
+			return item, fmt.Errorf("item %s correct response does not order every choice", doc.Identifier)
+		}
+
+	default:
+		return item, fmt.Errorf("item %s uses %s which is not supported", doc.Identifier, body.interactions[0])
+	}
+
+	return item, nil
+}
+
+// parseQTIMatching read the pairs of a matchInteraction, the choices of the second set paired with no choice of the
+// first one are distractors. The item has partial credit when its pairs are mapped to points.
+func parseQTIMatching(item Item, doc qtiItemXML, declaration *qtiResponseDeclaration, body *qtiBody) (Item, error) {
+	if len(body.matchIDs) != 2 {
+		return item, fmt.Errorf("item %s must have two match sets, found %d", doc.Identifier, len(body.matchIDs))
+	}
+	item.Type = TypeMatching
+	item.PartialCredit = len(declaration.Mapping) > 0 || doc.ResponseProcessing.Template == qtiMapResponse
+
+	first := make(map[string]bool)
+	for _, id := range body.matchIDs[0] {
+		first[id] = true
+	}
+	matches := make(map[string]string)
+	for k, id := range body.matchIDs[1] {
+		matches[id] = body.matchTexts[1][k]
+	}
+
+	pairs := make(map[string]string)
+	for _, value := range declaration.CorrectResponse {
+		pair := strings.Fields(value)
+		if len(pair) != 2 {
+			return item, fmt.Errorf("item %s correct response %s is not a pair", doc.Identifier, value)
+		}
+		//pairs may be written from the second set to the first one
+		if !first[pair[0]] {
+			pair[0], pair[1] = pair[1], pair[0]
+		}
+		pairs[pair[0]] = pair[1]
+	}
+
+	paired := make(map[string]bool)
+	for k, id := range body.matchIDs[0] {
+		match, ok := matches[pairs[id]]
+		if !ok {
+			return item, fmt.Errorf("item %s choice %s has no match", doc.Identifier, id)
+		}
+		item.Choices = append(item.Choices, body.matchTexts[0][k])
+		item.Matches = append(item.Matches, match)
+		paired[pairs[id]] = true
+	}
+	for k, id := range body.matchIDs[1] {
+		if !paired[id] {
+			item.Choices = append(item.Choices, "")
+			item.Matches = append(item.Matches, body.matchTexts[1][k])
+		}
+	}
+
+	return item, nil
+}
+
+// parseQTIBody collect the text, the interactions and the choices of the itemBody of an item
+func parseQTIBody(data []byte) (*qtiBody, error) {
+	body := new(qtiBody)
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	inBody, inChoice := false, false
+	var choice strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid item: %s", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Local == "itemBody":
+				inBody = true
+			case !inBody:
+			case t.Name.Local == "simpleChoice":
+				inChoice = true
+				choice.Reset()
+				body.choiceIDs = append(body.choiceIDs, attr(t, "identifier"))
+			case t.Name.Local == "simpleMatchSet":
+				body.matchIDs = append(body.matchIDs, nil)
+				body.matchTexts = append(body.matchTexts, nil)
+			case t.Name.Local == "simpleAssociableChoice" && len(body.matchIDs) > 0:
+				inChoice = true
+				choice.Reset()
+				last := len(body.matchIDs) - 1
+				body.matchIDs[last] = append(body.matchIDs[last], attr(t, "identifier"))
+			case strings.HasSuffix(t.Name.Local, "Interaction"):
+				body.interactions = append(body.interactions, t.Name.Local)
+				body.response = attr(t, "responseIdentifier")
+				body.maxChoices = attr(t, "maxChoices")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "itemBody":
+				inBody = false
+			case "simpleChoice":
+				inChoice = false
+				body.choices = append(body.choices, strings.Join(strings.Fields(choice.String()), " "))
+			case "simpleAssociableChoice":
+				if last := len(body.matchTexts) - 1; inChoice && last >= 0 {
+					body.matchTexts[last] = append(body.matchTexts[last], strings.Join(strings.Fields(choice.String()), " "))
+				}
+				inChoice = false
+			}
+		case xml.CharData:
+			if inChoice {
+				choice.Write(t)
+			} else if inBody {
+				body.text.WriteString(" ")
+				body.text.Write(t)
+			}
+		}
+	}
+
+	return body, nil
+}
+
+func attr(element xml.StartElement, name string) string {
+	for _, v := range element.Attr {
+		if v.Name.Local == name {
+			return v.Value
+		}
+	}
+
+	return ""
+}
+
+func readZipFile(files map[string]*zip.File, name string) ([]byte, error) {
+	f, ok := files[name]
+	if !ok {
+		return nil, fmt.Errorf("package has no %s", name)
+	}
+
+	return readZipEntry(f)
+}
+
+// readZipEntry read a file of an archive, files larger than maxZipEntrySize once uncompressed are rejected so a small
+// archive cannot fill the memory
+func readZipEntry(f *zip.File) ([]byte, error) {
+	if f.UncompressedSize64 > maxZipEntrySize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", f.Name, maxZipEntrySize)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	//the size in the header is not trusted
+	data, err := ioutil.ReadAll(io.LimitReader(rc, maxZipEntrySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxZipEntrySize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", f.Name, maxZipEntrySize)
+	}
+
+	return data, nil
+}
+
+func escapeXML(text string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(text))
+
+	return buf.String()
+}
+
+func truncate(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+
+	return string(runes[:length])
+}
@@ -0,0 +1,120 @@
+package exchange
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// roundTripItems items every format can carry, their text uses the characters which are escaped by the formats
+var roundTripItems = []Item{
+	{
+		Question:   `Which of {a, b} = #1: ~c~ \ d?`,
+		Type:       TypeSingleChoice,
+		Category:   "Math/Algebra",
+		Tags:       []string{"sets"},
+		Choices:    []string{"a & b", "<c>", `\d`},
+		AnswerKeys: []int{2},
+	},
+	{
+		Question:   "Pick the primes",
+		Type:       TypeMultipleChoice,
+		Category:   "Math/Algebra",
+		Choices:    []string{"2", "4", "5"},
+		AnswerKeys: []int{1, 3},
+	},
+	{
+		Question:   "Pick the even prime",
+		Type:       TypeMultipleChoice,
+		Category:   "Math/Algebra",
+		Choices:    []string{"2", "3", "5"},
+		AnswerKeys: []int{1},
+	},
+	{
+		Question: "Capital of *France*",
+		Type:     TypeTextEntry,
+		Format:   TextMarkdown,
+		Category: "Geography",
+		Answer:   "Paris",
+	},
+}
+
+// arrangedItems matching and ordering items with and without partial credit, gift has no ordering items
+var arrangedItems = []Item{
+	{
+		Question:      "Match the capitals",
+		Type:          TypeMatching,
+		Choices:       []string{"France", "Japan", ""},
+		Matches:       []string{"Paris", "Tokyo", "Berlin"},
+		PartialCredit: true,
+	},
+	{
+		Question: "Match the symbols",
+		Type:     TypeMatching,
+		Choices:  []string{"Iron", "Gold"},
+		Matches:  []string{"Fe", "Au"},
+	},
+	{
+		Question:      "Sort ascending",
+		Type:          TypeOrdering,
+		Choices:       []string{"1", "2", "3"},
+		PartialCredit: true,
+	},
+	{
+		Question: "Sort descending",
+		Type:     TypeOrdering,
+		Choices:  []string{"3", "2", "1"},
+	},
+}
+
+// roundTripFields the fields of an item a format keeps
+var roundTripFields = map[string]func(Item) Item{
+	FormatCSV:    func(v Item) Item { return v },
+	FormatXLSX:   func(v Item) Item { return v },
+	FormatMoodle: func(v Item) Item { v.Difficulty, v.TopicID = "", ""; return v },
+	FormatGIFT:   func(v Item) Item { v.Difficulty, v.TopicID = "", ""; return v },
+	FormatQTI: func(v Item) Item {
+		v.Difficulty, v.TopicID, v.Category, v.Tags, v.Format = "", "", "", nil, ""
+		return v
+	},
+}
+
+func TestRoundTrip(t *testing.T) {
+	for format, fields := range roundTripFields {
+		checkRoundTrip(t, format, roundTripItems, fields)
+
+		arranged := arrangedItems
+		if format == FormatGIFT {
+			arranged = arrangedItems[:2]
+		}
+		checkRoundTrip(t, format, arranged, fields)
+	}
+}
+
+// checkRoundTrip write items in a format and read them back, fields are the fields the format keeps
+func checkRoundTrip(t *testing.T, format string, written []Item, fields func(Item) Item) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	itemErrors, err := WriteItems(&buf, format, "Round trip", written)
+	if err != nil || len(itemErrors) > 0 {
+		t.Fatalf("%s: write: %v %v", format, err, itemErrors)
+	}
+
+	items, itemErrors, err := ReadItems(format, buf.Bytes())
+	if err != nil || len(itemErrors) > 0 {
+		t.Fatalf("%s: read: %v %v", format, err, itemErrors)
+	}
+	if len(items) != len(written) {
+		t.Fatalf("%s: read %d items, want %d", format, len(items), len(written))
+	}
+	for k, v := range items {
+		v.Row = 0
+		if len(v.Choices) == 0 {
+			v.Choices = nil
+		}
+		if want := fields(written[k]); !reflect.DeepEqual(fields(v), want) {
+			t.Errorf("%s: item %d is\n%#v\nwant\n%#v", format, k+1, fields(v), want)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package exchange
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// columns of the spreadsheet format, choices are written as choice_1, choice_2, ... and the items they are paired with
+// in matching questions as match_1, match_2, ...
+const (
+	ColumnQuestion   = "question"
+	ColumnType       = "type"
+	ColumnFormat     = "format"
+	ColumnDifficulty = "difficulty"
+	ColumnTopicID    = "topic_id"
+	ColumnCategory   = "category"
+	ColumnTags       = "tags"
+	ColumnAnswerKey  = "answer_key"
+	ColumnAnswer     = "answer"
+	ColumnChoice     = "choice_"
+	ColumnMatch      = "match_"
+	// true when matching and ordering items get a point for every right pair or position
+	ColumnPartialCredit = "partial_credit"
+
+	// separator of the values in the tags and answer_key columns
+	listSeparator = "|"
+)
+
+// ItemsToRows convert items into spreadsheet rows, the first row is the header.
+// The match and partial credit columns are only written when there are matching or ordering items.
+func ItemsToRows(items []Item) [][]string {
+	totalChoice, totalMatch := 2, 0
+	arranged := false
+	for _, v := range items {
+		if len(v.Choices) > totalChoice {
+			totalChoice = len(v.Choices)
+		}
+		if len(v.Matches) > totalMatch {
+			totalMatch = len(v.Matches)
+		}
+		if v.Type == TypeMatching || v.Type == TypeOrdering {
+			arranged = true
+		}
+	}
+
+	header := []string{ColumnQuestion, ColumnType, ColumnFormat, ColumnDifficulty, ColumnTopicID, ColumnCategory, ColumnTags, ColumnAnswerKey, ColumnAnswer}
+	if arranged {
+		header = append(header, ColumnPartialCredit)
+	}
+	for i := 1; i <= totalChoice; i++ {
+		header = append(header, ColumnChoice+strconv.Itoa(i))
+	}
+	for i := 1; i <= totalMatch; i++ {
+		header = append(header, ColumnMatch+strconv.Itoa(i))
+	}
+
+	rows := [][]string{header}
+	for _, v := range items {
+		var keys []string
+		for _, key := range v.AnswerKeys {
+			keys = append(keys, strconv.Itoa(key))
+		}
+
+		row := []string{
+			v.Question,
+			v.Type,
+			v.Format,
+			v.Difficulty,
+			v.TopicID,
+			v.Category,
+			strings.Join(v.Tags, listSeparator),
+			strings.Join(keys, listSeparator),
+			v.Answer,
+		}
+		if arranged {
+			partialCredit := ""
+			if v.Type == TypeMatching || v.Type == TypeOrdering {
+				partialCredit = strconv.FormatBool(v.PartialCredit)
+			}
+			row = append(row, partialCredit)
+		}
+		for i := 0; i < totalChoice; i++ {
+			choice := ""
+			if i < len(v.Choices) {
+				choice = v.Choices[i]
+			}
+			row = append(row, choice)
+		}
+		for i := 0; i < totalMatch; i++ {
+			match := ""
+			if i < len(v.Matches) {
+				match = v.Matches[i]
+			}
+			row = append(row, match)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// RowsToItems convert spreadsheet rows into items. The first row is the header.
+// mapping maps a column of the format to the header used by the file, unmapped columns are matched by name.
+// Empty rows are skipped, rows which cannot be read are reported by their 1-based row number.
+func RowsToItems(rows [][]string, mapping map[string]string) ([]Item, []ItemError, error) {
+	if len(rows) == 0 {
+		return nil, nil, fmt.Errorf("file is empty")
+	}
+
+	headers := make(map[string]int)
+	for k, v := range rows[0] {
+		//csv files saved by spreadsheet applications may start with a byte order mark
+		v = strings.TrimPrefix(v, "\ufeff")
+		headers[strings.ToLower(strings.TrimSpace(v))] = k
+	}
+
+	column := func(name string) (int, bool) {
+		header := name
+		if v, ok := mapping[name]; ok {
+			header = v
+		}
+		k, ok := headers[strings.ToLower(strings.TrimSpace(header))]
+		return k, ok
+	}
+
+	questionColumn, ok := column(ColumnQuestion)
+	if !ok {
+		return nil, nil, fmt.Errorf("column %s is missing", ColumnQuestion)
+	}
+	answerKeyColumn, hasAnswerKey := column(ColumnAnswerKey)
+	answerColumn, hasAnswer := column(ColumnAnswer)
+	if !hasAnswerKey && !hasAnswer {
+		return nil, nil, fmt.Errorf("column %s or %s is missing", ColumnAnswerKey, ColumnAnswer)
+	}
+	typeColumn, hasType := column(ColumnType)
+	formatColumn, hasFormat := column(ColumnFormat)
+	difficultyColumn, hasDifficulty := column(ColumnDifficulty)
+	topicColumn, hasTopic := column(ColumnTopicID)
+	categoryColumn, hasCategory := column(ColumnCategory)
+	tagsColumn, hasTags := column(ColumnTags)
+	partialCreditColumn, hasPartialCredit := column(ColumnPartialCredit)
+
+	var choiceColumns []int
+	for i := 1; ; i++ {
+		k, ok := column(ColumnChoice + strconv.Itoa(i))
+		if !ok {
+			break
+		}
+		choiceColumns = append(choiceColumns, k)
+	}
+	var matchColumns []int
+	for i := 1; ; i++ {
+		k, ok := column(ColumnMatch + strconv.Itoa(i))
+		if !ok {
+			break
+		}
+		matchColumns = append(matchColumns, k)
+	}
+
+	var items []Item
+	var itemErrors []ItemError
+	for r, row := range rows[1:] {
+		if isEmptyRow(row) {
+			continue
+		}
+
+		//text is kept as it is so exported files round trip, only the other columns are trimmed
+		cell := func(k int) string {
+			if k < len(row) {
+				return row[k]
+			}
+			return ""
+		}
+
+		item := Item{Question: cell(questionColumn), Row: r + 2}
+		if hasType {
+			item.Type = strings.ToLower(strings.TrimSpace(cell(typeColumn)))
+		}
+		if hasFormat {
+			item.Format = strings.ToLower(strings.TrimSpace(cell(formatColumn)))
+		}
+		if hasAnswer {
+			item.Answer = cell(answerColumn)
+		}
+		if hasDifficulty {
+			item.Difficulty = strings.ToLower(strings.TrimSpace(cell(difficultyColumn)))
+		}
+		if hasTopic {
+			item.TopicID = strings.TrimSpace(cell(topicColumn))
+		}
+		if hasCategory {
+			item.Category = strings.TrimSpace(cell(categoryColumn))
+		}
+		if hasTags && cell(tagsColumn) != "" {
+			for _, tag := range strings.Split(cell(tagsColumn), listSeparator) {
+				if strings.TrimSpace(tag) != "" {
+					item.Tags = append(item.Tags, strings.TrimSpace(tag))
+				}
+			}
+		}
+
+		//trailing empty choices come from the fixed width of the sheet, empty choices between filled ones are kept.
+		//A choice of a matching item without text but with a match is a distractor.
+		for _, k := range choiceColumns {
+			item.Choices = append(item.Choices, cell(k))
+		}
+		for _, k := range matchColumns {
+			item.Matches = append(item.Matches, cell(k))
+		}
+		for len(item.Matches) < len(item.Choices) {
+			item.Matches = append(item.Matches, "")
+		}
+		for len(item.Choices) < len(item.Matches) {
+			item.Choices = append(item.Choices, "")
+		}
+		for n := len(item.Choices); n > 0 && strings.TrimSpace(item.Choices[n-1]) == "" && strings.TrimSpace(item.Matches[n-1]) == ""; n-- {
+			item.Choices, item.Matches = item.Choices[:n-1], item.Matches[:n-1]
+		}
+		if isEmptyRow(item.Matches) {
+			item.Matches = nil
+		}
+
+		if hasPartialCredit && strings.TrimSpace(cell(partialCreditColumn)) != "" {
+			partialCredit, err := strconv.ParseBool(strings.TrimSpace(cell(partialCreditColumn)))
+			if err != nil {
+				itemErrors = append(itemErrors, ItemError{
+					Index:  len(items),
+					Row:    item.Row,
+					Errors: []string{fmt.Sprintf("%s %q is not true or false", ColumnPartialCredit, cell(partialCreditColumn))},
+				})
+			}
+			item.PartialCredit = partialCredit
+		}
+
+		if hasAnswerKey && strings.TrimSpace(cell(answerKeyColumn)) != "" {
+			for _, v := range strings.Split(cell(answerKeyColumn), listSeparator) {
+				key, err := strconv.Atoi(strings.TrimSpace(v))
+				if err != nil {
+					itemErrors = append(itemErrors, ItemError{
+						Index:  len(items),
+						Row:    item.Row,
+						Errors: []string{fmt.Sprintf("%s %q is not a number", ColumnAnswerKey, v)},
+					})
+					continue
+				}
+				item.AnswerKeys = append(item.AnswerKeys, key)
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	return items, itemErrors, nil
+}
+
+func isEmptyRow(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,272 @@
+package exchange
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// limits of the files read from archives, the size is uncompressed, and of a sheet
+const (
+	maxZipEntrySize = 32 << 20
+	maxXLSXRows     = 1048576
+	maxXLSXColumns  = 16384
+)
+
+// parts of a xlsx file which are needed to read the first sheet
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+
+	var text strings.Builder
+	for _, v := range t.Runs {
+		text.WriteString(v.Text)
+	}
+	return text.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Ref   string `xml:"r,attr"`
+		Cells []struct {
+			Ref    string    `xml:"r,attr"`
+			Type   string    `xml:"t,attr"`
+			Value  string    `xml:"v"`
+			Inline *xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// ReadXLSX read every row of the first sheet of a xlsx file
+func ReadXLSX(r io.ReaderAt, size int64) ([][]string, error) {
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not a xlsx file: %s", err)
+	}
+
+	files := make(map[string]*zip.File)
+	for _, f := range reader.File {
+		files[strings.TrimPrefix(f.Name, "/")] = f
+	}
+
+	var workbook xlsxWorkbook
+	var relationships xlsxRelationships
+	if err := readXMLPart(files, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	if err := readXMLPart(files, "xl/_rels/workbook.xml.rels", &relationships); err != nil {
+		return nil, err
+	}
+	if len(workbook.Sheets) == 0 {
+		return nil, fmt.Errorf("xlsx file has no sheet")
+	}
+
+	sheetPath := ""
+	for _, v := range relationships.Relationships {
+		if v.ID == workbook.Sheets[0].RID {
+			sheetPath = v.Target
+		}
+	}
+	if strings.HasPrefix(sheetPath, "/") {
+		sheetPath = strings.TrimPrefix(sheetPath, "/")
+	} else {
+		sheetPath = path.Join("xl", sheetPath)
+	}
+
+	var sharedStrings xlsxSharedStrings
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := readXMLPart(files, "xl/sharedStrings.xml", &sharedStrings); err != nil {
+			return nil, err
+		}
+	}
+
+	var sheet xlsxSheet
+	if err := readXMLPart(files, sheetPath, &sheet); err != nil {
+		return nil, err
+	}
+
+	//rows and cells are placed by their reference, the empty ones are usually left out. Rows and cells without
+	//reference follow the previous one.
+	var rows [][]string
+	for _, row := range sheet.Rows {
+		index := len(rows)
+		if row.Ref != "" {
+			number, err := strconv.Atoi(row.Ref)
+			if err != nil || number < 1 || number > maxXLSXRows {
+				return nil, fmt.Errorf("row %q is not a valid row number", row.Ref)
+			}
+			if number-1 < len(rows) {
+				return nil, fmt.Errorf("row %d is out of order", number)
+			}
+			index = number - 1
+		}
+		for len(rows) < index {
+			rows = append(rows, nil)
+		}
+
+		var values []string
+		for _, cell := range row.Cells {
+			column := len(values)
+			if cell.Ref != "" {
+				column = columnIndex(cell.Ref)
+				if column < 0 || column >= maxXLSXColumns || cell.Ref[len(columnName(column)):] != strconv.Itoa(index+1) {
+					return nil, fmt.Errorf("cell %q is not a valid reference in row %d", cell.Ref, index+1)
+				}
+				if column < len(values) {
+					return nil, fmt.Errorf("cell %s is out of order", cell.Ref)
+				}
+			}
+			for len(values) <= column {
+				values = append(values, "")
+			}
+
+			switch cell.Type {
+			case "s":
+				item, err := strconv.Atoi(cell.Value)
+				if err != nil || item < 0 || item >= len(sharedStrings.Items) {
+					return nil, fmt.Errorf("cell %s refers to an unknown shared string", cell.Ref)
+				}
+				values[column] = sharedStrings.Items[item].String()
+			case "inlineStr":
+				if cell.Inline != nil {
+					values[column] = cell.Inline.String()
+				}
+			default:
+				values[column] = cell.Value
+			}
+		}
+		rows = append(rows, values)
+	}
+
+	return rows, nil
+}
+
+func readXMLPart(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("xlsx file has no %s", name)
+	}
+
+	data, err := readZipEntry(f)
+	if err != nil {
+		return err
+	}
+
+	return xml.Unmarshal(data, v)
+}
+
+// columnIndex get the 0-based column of a cell reference like "AB12"
+func columnIndex(ref string) int {
+	column := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		column = column*26 + int(r-'A'+1)
+		//references beyond the last column are invalid, they must not overflow
+		if column > maxXLSXColumns {
+			return -1
+		}
+	}
+
+	return column - 1
+}
+
+// columnName get the letters of a 0-based column
+func columnName(column int) string {
+	name := ""
+	for column++; column > 0; column = (column - 1) / 26 {
+		name = string(rune('A'+(column-1)%26)) + name
+	}
+
+	return name
+}
+
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+	xlsxRootRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxWorkbookPart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="questions" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+)
+
+// WriteXLSX write rows as the only sheet of a xlsx file, every cell is written as text
+func WriteXLSX(w io.Writer, rows [][]string) error {
+	archive := zip.NewWriter(w)
+
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRelationships},
+		{"xl/workbook.xml", xlsxWorkbookPart},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRelationships},
+	}
+	for _, v := range parts {
+		f, err := archive.Create(v.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, v.content); err != nil {
+			return err
+		}
+	}
+
+	f, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+
+	io.WriteString(f, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+"\n")
+	io.WriteString(f, `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for r, row := range rows {
+		fmt.Fprintf(f, `<row r="%d">`, r+1)
+		for k, value := range row {
+			fmt.Fprintf(f, `<c r="%s%d" t="inlineStr"><is><t xml:space="preserve">`, columnName(k), r+1)
+			if err := xml.EscapeText(f, []byte(value)); err != nil {
+				return err
+			}
+			io.WriteString(f, `</t></is></c>`)
+		}
+		io.WriteString(f, `</row>`)
+	}
+	if _, err := io.WriteString(f, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
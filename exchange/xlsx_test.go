@@ -0,0 +1,149 @@
+package exchange
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// xlsxFile a xlsx file with sheetData as the data of its sheet, and the parts of extra
+func xlsxFile(t *testing.T, sheetData string, extra map[string]io.Reader) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	parts := map[string]io.Reader{
+		"[Content_Types].xml":        strings.NewReader(xlsxContentTypes),
+		"_rels/.rels":                strings.NewReader(xlsxRootRelationships),
+		"xl/workbook.xml":            strings.NewReader(xlsxWorkbookPart),
+		"xl/_rels/workbook.xml.rels": strings.NewReader(xlsxWorkbookRelationships),
+		"xl/sharedStrings.xml": strings.NewReader(`<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<si><t>question</t></si><si><r><t>rich </t></r><r><t>text</t></r></si></sst>`),
+		"xl/worksheets/sheet1.xml": strings.NewReader(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<sheetData>` + sheetData + `</sheetData></worksheet>`),
+	}
+	for name, r := range extra {
+		parts[name] = r
+	}
+	for name, r := range parts {
+		f, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.Copy(f, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestReadXLSX(t *testing.T) {
+	tests := []struct {
+		name      string
+		sheetData string
+		want      [][]string
+	}{
+		{"cells placed by reference",
+			`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="s"><v>1</v></c></row>` +
+				`<row r="2"><c r="B2" t="inlineStr"><is><t>b</t></is></c><c r="AA2"><v>42</v></c></row>`,
+			[][]string{{"question", "", "rich text"}, append(append([]string{"", "b"}, make([]string, 24)...), "42")}},
+		{"empty rows left out",
+			`<row r="2"><c r="A2"><v>1</v></c></row><row r="5"><c r="B5"><v>2</v></c></row>`,
+			[][]string{nil, {"1"}, nil, nil, {"", "2"}}},
+		{"rows and cells without reference follow the previous one",
+			`<row><c><v>1</v></c><c r="C1"><v>3</v></c><c><v>4</v></c></row><row r="3"><c><v>5</v></c></row><row><c><v>6</v></c></row>`,
+			[][]string{{"1", "", "3", "4"}, nil, {"5"}, {"6"}}},
+	}
+
+	for _, v := range tests {
+		data := xlsxFile(t, v.sheetData, nil)
+		rows, err := ReadXLSX(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Errorf("%s: %s", v.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(rows, v.want) {
+			t.Errorf("%s: rows %q, want %q", v.name, rows, v.want)
+		}
+	}
+}
+
+func TestReadXLSXErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		sheetData string
+		err       string
+	}{
+		{"row out of order", `<row r="3"></row><row r="2"></row>`, "row 2 is out of order"},
+		{"row beyond the sheet", `<row r="1048577"></row>`, "not a valid row number"},
+		{"invalid row", `<row r="x"></row>`, "not a valid row number"},
+		{"cell out of order", `<row r="1"><c r="C1"><v>1</v></c><c r="B1"><v>2</v></c></row>`, "cell B1 is out of order"},
+		{"cell of another row", `<row r="1"><c r="A2"><v>1</v></c></row>`, `cell "A2" is not a valid reference in row 1`},
+		{"cell beyond the sheet", `<row r="1"><c r="XFE1"><v>1</v></c></row>`, "not a valid reference"},
+		{"overflowing cell", `<row r="1"><c r="ZZZZZZZZZZZZZZZZ1"><v>1</v></c></row>`, "not a valid reference"},
+		{"lowercase cell", `<row r="1"><c r="a1"><v>1</v></c></row>`, "not a valid reference"},
+		{"unknown shared string", `<row r="1"><c r="A1" t="s"><v>2</v></c></row>`, "unknown shared string"},
+	}
+
+	for _, v := range tests {
+		data := xlsxFile(t, v.sheetData, nil)
+		_, err := ReadXLSX(bytes.NewReader(data), int64(len(data)))
+		if err == nil || !strings.Contains(err.Error(), v.err) {
+			t.Errorf("%s: error %v, want %q", v.name, err, v.err)
+		}
+	}
+}
+
+// zeros reader of n zero bytes, they compress to almost nothing
+type zeros int64
+
+func (z *zeros) Read(p []byte) (int, error) {
+	if *z <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > int64(*z) {
+		p = p[:*z]
+	}
+	for k := range p {
+		p[k] = 0
+	}
+	*z -= zeros(len(p))
+
+	return len(p), nil
+}
+
+func TestReadXLSXEntrySize(t *testing.T) {
+	large := zeros(maxZipEntrySize + 1)
+	data := xlsxFile(t, "", map[string]io.Reader{"xl/sharedStrings.xml": &large})
+	if len(data) > 1<<20 {
+		t.Fatalf("archive of %d bytes, want a small one", len(data))
+	}
+
+	_, err := ReadXLSX(bytes.NewReader(data), int64(len(data)))
+	if err == nil || !strings.Contains(err.Error(), "xl/sharedStrings.xml is larger than") {
+		t.Errorf("error %v, want the shared strings rejected", err)
+	}
+
+	//an entry lying about its size is not read past it
+	var entry zip.File
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range reader.File {
+		if f.Name == "xl/sharedStrings.xml" {
+			entry = *f
+		}
+	}
+	entry.UncompressedSize64 = 10
+	if data, err := readZipEntry(&entry); err == nil {
+		t.Errorf("entry with a wrong size: read %d bytes, want it rejected", len(data))
+	}
+}
@@ -0,0 +1,439 @@
+package formula
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// limits of an expression, they keep the evaluation of expressions written by admins cheap
+const (
+	maxExpressionLength = 1000
+	maxExpressionDepth  = 50
+)
+
+// constants usable in expressions
+var constants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+// functions usable in expressions with their number of arguments, -1 is any number from 1
+var functions = map[string]struct {
+	args int
+	call func(args []float64) float64
+}{
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"sqrt":  {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"floor": {1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	"ceil":  {1, func(a []float64) float64 { return math.Ceil(a[0]) }},
+	"exp":   {1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"ln":    {1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"log":   {1, func(a []float64) float64 { return math.Log10(a[0]) }},
+	"sin":   {1, func(a []float64) float64 { return math.Sin(a[0]) }},
+	"cos":   {1, func(a []float64) float64 { return math.Cos(a[0]) }},
+	"tan":   {1, func(a []float64) float64 { return math.Tan(a[0]) }},
+	"round": {2, func(a []float64) float64 { return Round(a[0], int(a[1])) }},
+	"min":   {-1, func(a []float64) float64 { return reduce(a, math.Min) }},
+	"max":   {-1, func(a []float64) float64 { return reduce(a, math.Max) }},
+}
+
+// Expression parsed arithmetic expression. It only computes numbers: there is no assignment, no loop and no access
+// to anything but the values of its variables, so expressions written by admins are safe to evaluate.
+type Expression struct {
+	source string
+	root   node
+}
+
+type node interface {
+	eval(values map[string]float64) (float64, error)
+}
+
+type number float64
+
+type variable string
+
+type unary struct {
+	op      byte
+	operand node
+}
+
+type binary struct {
+	op          byte
+	left, right node
+}
+
+type call struct {
+	name string
+	args []node
+}
+
+// Parse parse an expression made of numbers, variables, the constants pi and e, the operators + - * / % ^,
+// parentheses and the functions abs, sqrt, floor, ceil, exp, ln, log, sin, cos, tan, round(x, decimals), min and max
+func Parse(source string) (*Expression, error) {
+	if strings.TrimSpace(source) == "" {
+		return nil, fmt.Errorf("expression is empty")
+	}
+	if len(source) > maxExpressionLength {
+		return nil, fmt.Errorf("expression is longer than %d characters", maxExpressionLength)
+	}
+
+	p := &parser{source: source}
+	root, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.source) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.source[p.pos], p.pos+1)
+	}
+
+	return &Expression{source: source, root: root}, nil
+}
+
+// String the source of the expression
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Variables the names of the variables used by the expression, constants are not included
+func (e *Expression) Variables() []string {
+	seen := make(map[string]bool)
+	var names []string
+	var walk func(n node)
+	walk = func(n node) {
+		switch v := n.(type) {
+		case variable:
+			if _, ok := constants[string(v)]; ok {
+				return
+			}
+			if !seen[string(v)] {
+				seen[string(v)] = true
+				names = append(names, string(v))
+			}
+		case unary:
+			walk(v.operand)
+		case binary:
+			walk(v.left)
+			walk(v.right)
+		case call:
+			for _, arg := range v.args {
+				walk(arg)
+			}
+		}
+	}
+	walk(e.root)
+
+	return names
+}
+
+// Eval evaluate the expression with the values of its variables, the result is always a finite number
+func (e *Expression) Eval(values map[string]float64) (float64, error) {
+	result, err := e.root.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return 0, fmt.Errorf("result is not a finite number")
+	}
+
+	return result, nil
+}
+
+// Evaluate parse and evaluate an expression
+func Evaluate(source string, values map[string]float64) (float64, error) {
+	e, err := Parse(source)
+	if err != nil {
+		return 0, err
+	}
+
+	return e.Eval(values)
+}
+
+func (n number) eval(values map[string]float64) (float64, error) {
+	return float64(n), nil
+}
+
+func (n variable) eval(values map[string]float64) (float64, error) {
+	if v, ok := values[string(n)]; ok {
+		return v, nil
+	}
+	if v, ok := constants[string(n)]; ok {
+		return v, nil
+	}
+
+	return 0, fmt.Errorf("unknown variable %s", string(n))
+}
+
+func (n unary) eval(values map[string]float64) (float64, error) {
+	v, err := n.operand.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	if n.op == '-' {
+		return -v, nil
+	}
+
+	return v, nil
+}
+
+func (n binary) eval(values map[string]float64) (float64, error) {
+	left, err := n.left.eval(values)
+	if err != nil {
+		return 0, err
+	}
+	right, err := n.right.eval(values)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n.op {
+	case '+':
+		return left + right, nil
+	case '-':
+		return left - right, nil
+	case '*':
+		return left * right, nil
+	case '/':
+		if right == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return left / right, nil
+	case '%':
+		if right == 0 {
+			return 0, fmt.Errorf("division by zero")
+		}
+		return math.Mod(left, right), nil
+	}
+
+	return math.Pow(left, right), nil
+}
+
+func (n call) eval(values map[string]float64) (float64, error) {
+	args := make([]float64, len(n.args))
+	for k, v := range n.args {
+		arg, err := v.eval(values)
+		if err != nil {
+			return 0, err
+		}
+		args[k] = arg
+	}
+
+	result := functions[n.name].call(args)
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return 0, fmt.Errorf("%s is not defined for %s", n.name, formatArgs(args))
+	}
+
+	return result, nil
+}
+
+// parser recursive descent parser of expressions, operators are parsed by precedence climbing
+type parser struct {
+	source string
+	pos    int
+	depth  int
+}
+
+// precedence of the binary operators, ^ is right associative
+var precedence = map[byte]int{
+	'+': 1,
+	'-': 1,
+	'*': 2,
+	'/': 2,
+	'%': 2,
+	'^': 4,
+}
+
+// precedence of the unary minus, between the multiplication and the power so -2^2 is -4
+const unaryPrecedence = 3
+
+func (p *parser) expression(minPrecedence int) (node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxExpressionDepth {
+		return nil, fmt.Errorf("expression is nested deeper than %d levels", maxExpressionDepth)
+	}
+
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.source) {
+			return left, nil
+		}
+		op := p.source[p.pos]
+		prec, ok := precedence[op]
+		if !ok || prec < minPrecedence {
+			return left, nil
+		}
+		p.pos++
+
+		next := prec + 1
+		if op == '^' {
+			next = prec
+		}
+		right, err := p.expression(next)
+		if err != nil {
+			return nil, err
+		}
+		left = binary{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) operand() (node, error) {
+	p.skipSpace()
+	if p.pos >= len(p.source) {
+		return nil, fmt.Errorf("expression ends unexpectedly")
+	}
+
+	c := p.source[p.pos]
+	switch {
+	case c == '-' || c == '+':
+		p.pos++
+		operand, err := p.expression(unaryPrecedence)
+		if err != nil {
+			return nil, err
+		}
+		return unary{op: c, operand: operand}, nil
+
+	case c == '(':
+		p.pos++
+		inner, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(')'); err != nil {
+			return nil, err
+		}
+		return inner, nil
+
+	case isDigit(c) || c == '.':
+		start := p.pos
+		for p.pos < len(p.source) && (isDigit(p.source[p.pos]) || p.source[p.pos] == '.') {
+			p.pos++
+		}
+		//exponent of numbers such as 1e-3
+		if p.pos < len(p.source) && (p.source[p.pos] == 'e' || p.source[p.pos] == 'E') {
+			end := p.pos + 1
+			if end < len(p.source) && (p.source[end] == '-' || p.source[end] == '+') {
+				end++
+			}
+			if end < len(p.source) && isDigit(p.source[end]) {
+				for end < len(p.source) && isDigit(p.source[end]) {
+					end++
+				}
+				p.pos = end
+			}
+		}
+		v, err := strconv.ParseFloat(p.source[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", p.source[start:p.pos])
+		}
+		return number(v), nil
+
+	case isLetter(c):
+		start := p.pos
+		for p.pos < len(p.source) && (isLetter(p.source[p.pos]) || isDigit(p.source[p.pos])) {
+			p.pos++
+		}
+		name := p.source[start:p.pos]
+
+		p.skipSpace()
+		if p.pos >= len(p.source) || p.source[p.pos] != '(' {
+			return variable(name), nil
+		}
+
+		fn, ok := functions[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown function %s", name)
+		}
+		p.pos++
+		var args []node
+		p.skipSpace()
+		if p.pos < len(p.source) && p.source[p.pos] == ')' {
+			p.pos++
+		} else {
+			for {
+				arg, err := p.expression(0)
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, arg)
+				p.skipSpace()
+				if p.pos < len(p.source) && p.source[p.pos] == ',' {
+					p.pos++
+					continue
+				}
+				if err := p.expect(')'); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+		if (fn.args < 0 && len(args) == 0) || (fn.args >= 0 && len(args) != fn.args) {
+			return nil, fmt.Errorf("wrong number of arguments for %s", name)
+		}
+		return call{name: name, args: args}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected %q at position %d", c, p.pos+1)
+}
+
+func (p *parser) expect(c byte) error {
+	p.skipSpace()
+	if p.pos >= len(p.source) || p.source[p.pos] != c {
+		return fmt.Errorf("%q is missing at position %d", c, p.pos+1)
+	}
+	p.pos++
+
+	return nil
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.source) && (p.source[p.pos] == ' ' || p.source[p.pos] == '\t' || p.source[p.pos] == '\n' || p.source[p.pos] == '\r') {
+		p.pos++
+	}
+}
+
+// Round round v to decimals digits after the decimal point, half away from zero
+func Round(v float64, decimals int) float64 {
+	if decimals > 15 {
+		decimals = 15
+	}
+	if decimals < -15 {
+		decimals = -15
+	}
+	scale := math.Pow(10, float64(decimals))
+
+	return math.Round(v*scale) / scale
+}
+
+func reduce(values []float64, f func(float64, float64) float64) float64 {
+	result := values[0]
+	for _, v := range values[1:] {
+		result = f(result, v)
+	}
+
+	return result
+}
+
+func formatArgs(args []float64) string {
+	var values []string
+	for _, v := range args {
+		values = append(values, Format(v))
+	}
+
+	return strings.Join(values, ", ")
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+}
@@ -0,0 +1,114 @@
+package formula
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	values := map[string]float64{"x": 3, "y": 4}
+	tests := []struct {
+		source string
+		want   float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"12 / 3 / 2", 2},
+		{"7 % 4", 3},
+		{"-2^2", -4},
+		{"(-2)^2", 4},
+		{"2^3^2", 512},
+		{"2 * -3", -6},
+		{"--2", 2},
+		{"+x", 3},
+		{"x^2 + y^2", 25},
+		{"sqrt(x^2 + y^2)", 5},
+		{"min(y, x, 5)", 3},
+		{"max(x, y)", 4},
+		{"round(pi, 2)", 3.14},
+		{"abs(x - y)", 1},
+		{"1.5e2", 150},
+	}
+
+	for _, v := range tests {
+		got, err := Evaluate(v.source, values)
+		if err != nil {
+			t.Errorf("%s: %s", v.source, err)
+			continue
+		}
+		if math.Abs(got-v.want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", v.source, got, v.want)
+		}
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{"", "expression is empty"},
+		{"   ", "expression is empty"},
+		{"1 / 0", "division by zero"},
+		{"1 / (x - 3)", "division by zero"},
+		{"5 % 0", "division by zero"},
+		{"z + 1", "unknown variable z"},
+		{"sqrt(-1)", "sqrt is not defined"},
+		{"ln(0)", "ln is not defined"},
+		{"1 +", "expression ends unexpectedly"},
+		{"(1 + 2", "')' is missing"},
+		{"1 2", "unexpected '2' at position 3"},
+		{"2x", "unexpected 'x' at position 2"},
+		{"foo(1)", "unknown function foo"},
+		{"sqrt(1, 2)", "wrong number of arguments for sqrt"},
+		{strings.Repeat("1+", 500) + "1", "longer than 1000 characters"},
+		{strings.Repeat("(", 60) + "1" + strings.Repeat(")", 60), "nested deeper than 50 levels"},
+		{strings.Repeat("-", 60) + "1", "nested deeper than 50 levels"},
+		{strings.Repeat("2^", 60) + "1", "nested deeper than 50 levels"},
+	}
+
+	for _, v := range tests {
+		_, err := Evaluate(v.source, map[string]float64{"x": 3})
+		if err == nil {
+			t.Errorf("%.20q: want an error", v.source)
+			continue
+		}
+		if !strings.Contains(err.Error(), v.err) {
+			t.Errorf("%.20q: error %q, want %q", v.source, err, v.err)
+		}
+	}
+}
+
+func TestExpressionVariables(t *testing.T) {
+	expression, err := Parse("a * x^2 + b * x + pi + max(c, a)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := strings.Join(expression.Variables(), ",")
+	if got != "a,x,b,c" {
+		t.Errorf("variables %s, want a,x,b,c", got)
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		value    float64
+		decimals int
+		want     float64
+	}{
+		{3.14159, 2, 3.14},
+		{2.5, 0, 3},
+		{-2.5, 0, -3},
+		{1234, -2, 1200},
+		{0.1 + 0.2, 9, 0.3},
+	}
+
+	for _, v := range tests {
+		if got := Round(v.value, v.decimals); got != v.want {
+			t.Errorf("Round(%v, %d) = %v, want %v", v.value, v.decimals, got, v.want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package formula
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// limits of the variables of a template
+const (
+	maxVariables = 20
+	// a set of values which cannot be used, e.g. a division by zero, is drawn again up to maxDraws times
+	maxDraws = 50
+)
+
+// Variable variable of a template, its values are drawn from Min to Max by Step (1 when it is not set)
+type Variable struct {
+	Name string  `json:"name"`
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Step float64 `json:"step"`
+}
+
+// Template text with variables written {name} and expressions written {=expression}, and the expression of its answer
+type Template struct {
+	Variables []Variable
+	Answer    *Expression
+}
+
+// Sample values of the variables of a template and the answer they give
+type Sample struct {
+	Values map[string]float64 `json:"values"`
+	Answer float64            `json:"answer"`
+}
+
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// placeholders of a text, {name} or {=expression}. Braces of other content, such as {1} in LaTeX, are left as they are.
+var placeholder = regexp.MustCompile(`\{(=[^{}]+|[A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// NewTemplate check the variables and the answer expression of a template
+func NewTemplate(variables []Variable, answer string) (*Template, error) {
+	if len(variables) > maxVariables {
+		return nil, fmt.Errorf("maximum total variables is %d", maxVariables)
+	}
+
+	declared := make(map[string]bool)
+	for k, v := range variables {
+		if !variableName.MatchString(v.Name) {
+			return nil, fmt.Errorf("variable %d must have a name made of letters, digits and _", k+1)
+		}
+		if _, ok := constants[v.Name]; ok {
+			return nil, fmt.Errorf("variable %s is the name of a constant", v.Name)
+		}
+		if _, ok := functions[v.Name]; ok {
+			return nil, fmt.Errorf("variable %s is the name of a function", v.Name)
+		}
+		if declared[v.Name] {
+			return nil, fmt.Errorf("variable %s is duplicated", v.Name)
+		}
+		declared[v.Name] = true
+
+		if v.Min > v.Max {
+			return nil, fmt.Errorf("min of variable %s is greater than its max", v.Name)
+		}
+		if v.Step < 0 {
+			return nil, fmt.Errorf("step of variable %s is negative", v.Name)
+		}
+		if math.IsInf(v.Min, 0) || math.IsInf(v.Max, 0) || math.IsNaN(v.Min) || math.IsNaN(v.Max) {
+			return nil, fmt.Errorf("range of variable %s is not finite", v.Name)
+		}
+	}
+
+	expression, err := Parse(answer)
+	if err != nil {
+		return nil, fmt.Errorf("answer expression: %s", err)
+	}
+	if err := checkVariables(expression, declared); err != nil {
+		return nil, fmt.Errorf("answer expression: %s", err)
+	}
+
+	return &Template{Variables: variables, Answer: expression}, nil
+}
+
+// CheckText check the placeholders of a text written for the template: expressions must be valid and only use
+// the variables of the template
+func (t *Template) CheckText(text string) error {
+	declared := make(map[string]bool)
+	for _, v := range t.Variables {
+		declared[v.Name] = true
+	}
+
+	for _, match := range placeholder.FindAllStringSubmatch(text, -1) {
+		if !strings.HasPrefix(match[1], "=") {
+			continue
+		}
+		expression, err := Parse(match[1][1:])
+		if err != nil {
+			return fmt.Errorf("%s: %s", match[0], err)
+		}
+		if err := checkVariables(expression, declared); err != nil {
+			return fmt.Errorf("%s: %s", match[0], err)
+		}
+	}
+
+	return nil
+}
+
+// Draw draw the values of the variables from seed and compute the answer. The same seed always gives the same
+// values, values giving no answer are drawn again.
+func (t *Template) Draw(seed int64) (Sample, error) {
+	r := rand.New(rand.NewSource(seed))
+
+	var err error
+	for i := 0; i < maxDraws; i++ {
+		values := make(map[string]float64)
+		for _, v := range t.Variables {
+			values[v.Name] = drawValue(r, v)
+		}
+
+		var answer float64
+		answer, err = t.Answer.Eval(values)
+		if err == nil {
+			return Sample{Values: values, Answer: answer}, nil
+		}
+	}
+
+	return Sample{}, fmt.Errorf("no values of the variables give an answer: %s", err)
+}
+
+// Render replace the placeholders of text with the values of the sample, unknown names are left as they are
+func (t *Template) Render(text string, sample Sample) string {
+	return placeholder.ReplaceAllStringFunc(text, func(match string) string {
+		inner := match[1 : len(match)-1]
+		if strings.HasPrefix(inner, "=") {
+			v, err := Evaluate(inner[1:], sample.Values)
+			if err != nil {
+				return match
+			}
+			return Format(v)
+		}
+		if v, ok := sample.Values[inner]; ok {
+			return Format(v)
+		}
+		return match
+	})
+}
+
+// Format format a number without trailing zeros, rounding the noise of floating point arithmetic (0.1+0.2 is 0.3)
+func Format(v float64) string {
+	v = Round(v, 9)
+	if v == 0 {
+		//no negative zero
+		v = 0
+	}
+
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// drawValue draw a value of a variable, a multiple of its step from its min
+func drawValue(r *rand.Rand, v Variable) float64 {
+	step := v.Step
+	if step == 0 {
+		step = 1
+	}
+
+	steps := math.Floor((v.Max-v.Min)/step + 1e-9)
+	if steps > 1e15 {
+		steps = 1e15
+	}
+	value := v.Min + float64(r.Int63n(int64(steps)+1))*step
+
+	//keep the decimals of the step, 0.1 * 3 is 0.3 and not 0.30000000000000004
+	return Round(value, 9)
+}
+
+func checkVariables(expression *Expression, declared map[string]bool) error {
+	for _, name := range expression.Variables() {
+		if !declared[name] {
+			return fmt.Errorf("variable %s is not declared", name)
+		}
+	}
+
+	return nil
+}
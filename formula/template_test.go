@@ -0,0 +1,102 @@
+package formula
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewTemplateErrors(t *testing.T) {
+	tests := []struct {
+		variables []Variable
+		answer    string
+		err       string
+	}{
+		{[]Variable{{Name: "a", Min: 1, Max: 9}}, "a + b", "variable b is not declared"},
+		{[]Variable{{Name: "1a", Min: 1, Max: 9}}, "1", "variable 1 must have a name"},
+		{[]Variable{{Name: "pi", Min: 1, Max: 9}}, "pi", "variable pi is the name of a constant"},
+		{[]Variable{{Name: "sqrt", Min: 1, Max: 9}}, "1", "variable sqrt is the name of a function"},
+		{[]Variable{{Name: "a", Min: 1, Max: 9}, {Name: "a", Min: 1, Max: 9}}, "a", "variable a is duplicated"},
+		{[]Variable{{Name: "a", Min: 9, Max: 1}}, "a", "min of variable a is greater than its max"},
+		{[]Variable{{Name: "a", Min: 1, Max: 9, Step: -1}}, "a", "step of variable a is negative"},
+		{[]Variable{{Name: "a", Min: 1, Max: 9}}, "a +", "answer expression: expression ends unexpectedly"},
+	}
+
+	for _, v := range tests {
+		_, err := NewTemplate(v.variables, v.answer)
+		if err == nil {
+			t.Errorf("%s: want an error", v.answer)
+			continue
+		}
+		if !strings.Contains(err.Error(), v.err) {
+			t.Errorf("%s: error %q, want %q", v.answer, err, v.err)
+		}
+	}
+}
+
+func TestTemplateDraw(t *testing.T) {
+	template, err := NewTemplate([]Variable{
+		{Name: "a", Min: 1, Max: 1000},
+		{Name: "b", Min: 0, Max: 1, Step: 0.1},
+	}, "a * b + pi")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := template.Draw(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := template.Draw(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, again) {
+		t.Errorf("seed 42 drew %v then %v", first, again)
+	}
+
+	differs := false
+	for seed := int64(1); seed <= 10; seed++ {
+		sample, err := template.Draw(seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sample.Values["a"] < 1 || sample.Values["a"] > 1000 || sample.Values["a"] != float64(int(sample.Values["a"])) {
+			t.Errorf("seed %d: a = %v is not a step of its range", seed, sample.Values["a"])
+		}
+		if b := sample.Values["b"]; b < 0 || b > 1 || Round(b, 1) != b {
+			t.Errorf("seed %d: b = %v is not a step of its range", seed, b)
+		}
+		if !reflect.DeepEqual(sample.Values, first.Values) {
+			differs = true
+		}
+	}
+	if !differs {
+		t.Errorf("seeds 1 to 10 all drew the values of seed 42")
+	}
+}
+
+func TestTemplateDrawAgain(t *testing.T) {
+	//x is 0 about half the time, those values are drawn again
+	template, err := NewTemplate([]Variable{{Name: "x", Min: 0, Max: 1}}, "1 / x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seed := int64(0); seed < 20; seed++ {
+		sample, err := template.Draw(seed)
+		if err != nil {
+			t.Fatalf("seed %d: %s", seed, err)
+		}
+		if sample.Values["x"] != 1 || sample.Answer != 1 {
+			t.Errorf("seed %d: drew %v", seed, sample)
+		}
+	}
+
+	template, err = NewTemplate([]Variable{{Name: "x", Min: 0, Max: 0}}, "1 / x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := template.Draw(1); err == nil || !strings.Contains(err.Error(), "division by zero") {
+		t.Errorf("drawing from x = 0 only: error %v, want division by zero", err)
+	}
+}
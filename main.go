@@ -1,6 +1,7 @@
 package main
 
 import (
+	"database/sql"
 	"flag"
 	"fmt"
 	"log"
@@ -10,15 +11,19 @@ import (
 	dataModel "okkybudiman/data/model"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 
 	"okkybudiman/module/admin"
 	"okkybudiman/module/user"
+	"okkybudiman/sandbox"
+	"okkybudiman/storage"
 	u "okkybudiman/utility"
 
 	"github.com/appleboy/gin-jwt"
 	"github.com/gin-gonic/gin"
 	"github.com/golang/glog"
+	"github.com/jinzhu/gorm"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -32,6 +37,7 @@ var (
 	dbFactory       *data.DBFactory
 	adminController *admin.Controller
 	userController  *user.Controller
+	grader          *user.Grader
 )
 var identityKey = "id"
 
@@ -50,6 +56,10 @@ func init() {
 	flag.BoolVar(&runMigration, "migrate", true, "run db migration before starting the server")
 	flag.BoolVar(&runSeeder, "seeder", false, "run db seeder before starting the server")
 	flag.Parse()
+	//commands such as convert run without the server, see runCommand
+	if flag.NArg() > 0 {
+		return
+	}
 
 	cfg, err := config.New()
 	if err != nil {
@@ -64,8 +74,14 @@ func init() {
 		runDBMigration()
 	}
 
-	//inject dbFactory to admin controller
-	adminController, err = admin.NewController(dbFactory)
+	store, err := storage.New(configuration.Storage)
+	if err != nil {
+		glog.Fatalf("Failed to open storage: %s", err)
+		panic(fmt.Errorf("Fatal error opening storage: %s", err))
+	}
+
+	//inject dbFactory and storage to admin controller
+	adminController, err = admin.NewController(dbFactory, store, configuration.Storage.MaxSize)
 	if err != nil {
 		glog.Fatal(err.Error())
 		panic(fmt.Errorf("Fatal error: %s", err))
@@ -77,6 +93,13 @@ func init() {
 		glog.Fatal(err.Error())
 		panic(fmt.Errorf("Fatal error: %s", err))
 	}
+
+	//programming answers are graded by workers running the code in the sandbox
+	grader, err = user.NewGrader(dbFactory, sandbox.New(configuration.Sandbox), configuration.Sandbox)
+	if err != nil {
+		glog.Fatal(err.Error())
+		panic(fmt.Errorf("Fatal error: %s", err))
+	}
 }
 
 func setupRouter() *gin.Engine {
@@ -170,27 +193,82 @@ func setupRouter() *gin.Engine {
 	{
 		v1.GET("/hello", helloHandler)
 		v1.GET("/list-test", adminController.GetListTest)
-		v1.GET("/test/:id/detail", adminController.GetDetailTest)
+		v1.GET("/attachment/:id", adminController.GetAttachment)
 		//api user
 		user := v1.Group("/user")
 		user.Use(CheckUser)
 		{
 			user.POST("/answer", userController.AnswerTest)
 			user.POST("/attempt-test", userController.AttempTest)
+			user.GET("/attempt/:id/questions", userController.AttemptQuestions)
+			user.POST("/section/submit", userController.SubmitSection)
 			user.GET("/test/:id/result", userController.Result)
+			user.GET("/test/:id/leaderboard", userController.Leaderboard)
+			user.GET("/test/:id/review", userController.Review)
+			user.POST("/practice/answer", userController.PracticeAnswer)
+			user.GET("/practice/:id", userController.PracticeSummary)
+			user.GET("/deck/due", userController.DueReviews)
+			user.GET("/deck/stats", userController.DeckStats)
+			user.POST("/deck/:id/recall", userController.RecallReview)
+			user.GET("/adaptive/:id/next", userController.AdaptiveNext)
+			user.POST("/adaptive/answer", userController.AdaptiveAnswer)
 		}
 		//api admin
 		v1.Use(CheckAdmin)
 		{
+			//the detail of a test has the answers and explanations, candidates get the questions of their attempt only
+			v1.GET("/test/:id/detail", adminController.GetDetailTest)
+			v1.GET("/list-user", adminController.GetListUser)
+			v1.GET("/list-participant", adminController.GetParticipant)
+			v1.GET("/test/:id/participant", adminController.GetRoster)
+			v1.GET("/test/:id/item-analysis", adminController.GetItemAnalysis)
+			v1.POST("/calibrate-irt", adminController.CalibrateIRT)
+			v1.GET("/test/:id/statistics", adminController.GetTestStatistics)
+			v1.GET("/list-result", adminController.GetListResult)
+			v1.GET("/list-question", adminController.GetListQuestion)
+			v1.GET("/question/:id/test-cases", adminController.GetTestCases)
+			v1.GET("/test/:id/sections", adminController.GetSections)
+			v1.GET("/list-subject", adminController.GetListSubject)
+			v1.GET("/list-topic", adminController.GetListTopic)
+			v1.GET("/list-tag", adminController.GetListTag)
+			v1.GET("/export-question", adminController.ExportQuestion)
+			v1.GET("/export-qti", adminController.ExportQTI)
+			v1.GET("/export-moodle", adminController.ExportMoodle)
+			v1.GET("/export-result", adminController.ExportResult)
+
 			v1.POST("/create-test", adminController.CreateTest)
 			v1.POST("/create-question", adminController.CreateQuestion)
+			v1.POST("/bulk-question", adminController.BulkQuestion)
+			v1.POST("/import-question", adminController.ImportQuestion)
+			v1.POST("/import-qti", adminController.ImportQTI)
+			v1.POST("/import-moodle", adminController.ImportMoodle)
 			v1.POST("/update-test", adminController.UpdateTest)
 			v1.POST("/update-question", adminController.UpdateQuestion)
 			v1.POST("/update-choice", adminController.UpdateChoice)
+			v1.POST("/create-choice", adminController.CreateChoice)
+			v1.POST("/reorder-choice", adminController.ReorderChoice)
+			v1.POST("/create-section", adminController.CreateSection)
+			v1.POST("/update-section", adminController.UpdateSection)
+			v1.POST("/reorder-section", adminController.ReorderSection)
+			v1.POST("/create-subject", adminController.CreateSubject)
+			v1.POST("/update-subject", adminController.UpdateSubject)
+			v1.POST("/create-topic", adminController.CreateTopic)
+			v1.POST("/update-topic", adminController.UpdateTopic)
+			v1.POST("/create-tag", adminController.CreateTag)
+			v1.POST("/update-tag", adminController.UpdateTag)
+			v1.POST("/assign-test", adminController.AssignTest)
 
 			v1.DELETE("/delete", adminController.DeleteTest)
 			v1.DELETE("/delete-question", adminController.DeleteQuestion)
+			v1.POST("/attachment", adminController.UploadAttachment)
+			v1.POST("/template-preview", adminController.TemplatePreview)
+			v1.DELETE("/attachment/:id", adminController.DeleteAttachment)
 			v1.DELETE("/delete-choice", adminController.DeleteChoice)
+			v1.DELETE("/delete-section", adminController.DeleteSection)
+			v1.DELETE("/delete-subject", adminController.DeleteSubject)
+			v1.DELETE("/delete-topic", adminController.DeleteTopic)
+			v1.DELETE("/delete-tag", adminController.DeleteTag)
+			v1.DELETE("/unassign-test", adminController.UnassignTest)
 		}
 
 	}
@@ -203,6 +281,15 @@ func setupRouter() *gin.Engine {
 }
 
 func main() {
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(flag.Args()))
+	}
+
+	if err := grader.Start(); err != nil {
+		glog.Errorf("Failed to start grader: %s", err)
+	}
+	defer grader.Stop()
+
 	r := setupRouter()
 
 	srv := &http.Server{
@@ -235,16 +322,122 @@ func runDBMigration() {
 	}
 	defer db.Close()
 
-	db.AutoMigrate(
+	//a section could be started twice by an attempt before it had a unique index, the first row is kept and the
+	//other ones are deleted for good so the index can be added
+	if db.HasTable(&dataModel.UserAttemptSection{}) {
+		var duplicates []struct {
+			UserAttemptTestID string
+			SectionID         string
+		}
+		db.Table("user_attempt_sections").Select("user_attempt_test_id, section_id").
+			Group("user_attempt_test_id, section_id").Having("COUNT(*) > 1").Scan(&duplicates)
+		for _, v := range duplicates {
+			var rows []dataModel.UserAttemptSection
+			db.Unscoped().Where("user_attempt_test_id = ? AND section_id = ?", v.UserAttemptTestID, v.SectionID).Order("started_at").Find(&rows)
+			for k := 1; k < len(rows); k++ {
+				if err := db.Unscoped().Delete(&rows[k]).Error; err != nil {
+					glog.Fatalf("Failed to delete duplicated section %s of attempt %s: %s", v.SectionID, v.UserAttemptTestID, err)
+					panic(fmt.Errorf("Fatal error migrating database: %s", err))
+				}
+			}
+		}
+	}
+
+	err = db.AutoMigrate(
 		&dataModel.User{},
 		&dataModel.Role{},
 		&dataModel.Test{},
+		&dataModel.Section{},
 		&dataModel.Question{},
 		&dataModel.QuestionChoice{},
+		&dataModel.Attachment{},
+		&dataModel.TestCase{},
 		&dataModel.UserAttemptTest{},
+		&dataModel.UserAttemptSection{},
 		&dataModel.UserAnswer{},
+		&dataModel.TestCaseResult{},
+		&dataModel.GradingJob{},
 		&dataModel.UserScore{},
-	)
+		&dataModel.PracticeAnswer{},
+		&dataModel.ReviewItem{},
+		&dataModel.ReviewLog{},
+		&dataModel.TestAssignment{},
+		&dataModel.Subject{},
+		&dataModel.Topic{},
+		&dataModel.Tag{},
+	).Error
+	if err != nil {
+		glog.Fatalf("Failed to migrate database: %s", err)
+		panic(fmt.Errorf("Fatal error migrating database: %s", err))
+	}
+
+	//auto migration does not change the type of existing columns, question content used to be varchar(100) then
+	//text, which mysql limits to 64 KB. The columns still short get the type of their model, sqlite columns have no
+	//length so they are left as they are
+	if db.Dialect().GetName() != "sqlite3" {
+		for _, v := range []struct {
+			model   interface{}
+			columns []string
+		}{
+			{&dataModel.Question{}, []string{"question", "answer", "explanation", "variables", "answer_expression", "blanks"}},
+			{&dataModel.QuestionChoice{}, []string{"choice", "feedback", "match"}},
+			{&dataModel.TestCase{}, []string{"input", "expected_output"}},
+			{&dataModel.UserAnswer{}, []string{"response"}},
+			{&dataModel.PracticeAnswer{}, []string{"response"}},
+		} {
+			scope := db.NewScope(v.model)
+			for _, column := range v.columns {
+				short, err := shortTextColumn(db, scope.TableName(), column)
+				if err != nil {
+					glog.Fatalf("Failed to get type of column %s of %s: %s", column, scope.TableName(), err)
+					panic(fmt.Errorf("Fatal error migrating database: %s", err))
+				}
+				if !short {
+					continue
+				}
+				field, _ := scope.FieldByName(column)
+				dataType := db.Dialect().DataTypeOf(field.StructField)
+				if err := db.Model(v.model).ModifyColumn(column, dataType).Error; err != nil {
+					glog.Fatalf("Failed to change column %s of %s to %s: %s", column, scope.TableName(), dataType, err)
+					panic(fmt.Errorf("Fatal error migrating database: %s", err))
+				}
+			}
+		}
+	}
+	//existing questions were written as plain text
+	err = db.Model(&dataModel.Question{}).Where("format IS NULL OR format = ?", "").UpdateColumn("format", dataModel.QuestionFormatPlain).Error
+	if err != nil {
+		glog.Fatalf("Failed to migrate question format: %s", err)
+		panic(fmt.Errorf("Fatal error migrating database: %s", err))
+	}
+
+	//scores saved before they were linked to their attempt are linked to the last attempt of the user started before
+	//the score, the ones without attempt get the nil id
+	var legacyScores []struct {
+		ID        string
+		UserID    string
+		TestID    string
+		CreatedAt time.Time
+	}
+	db.Table("user_scores").Select("id, user_id, test_id, created_at").Where("user_attempt_test_id IS NULL").Scan(&legacyScores)
+	for _, v := range legacyScores {
+		var attempt dataModel.UserAttemptTest
+		db.Where("user_id = ? AND test_id = ? AND mode <> ? AND start_test <= ?", v.UserID, v.TestID, dataModel.AttemptModePractice, v.CreatedAt).
+			Order("start_test DESC").First(&attempt)
+		db.Model(&dataModel.UserScore{}).Where("id = ?", v.ID).UpdateColumn("user_attempt_test_id", attempt.ID)
+	}
+
+	//questions created before the answer key moved to QuestionChoice only have a free text answer,
+	//flag the choice having the same text as the correct one
+	var legacyQuestions []dataModel.Question
+	db.Where("answer <> ? AND type = ?", "", dataModel.QuestionTypeSingleChoice).Find(&legacyQuestions)
+	for _, q := range legacyQuestions {
+		var count int
+		db.Model(&dataModel.QuestionChoice{}).Where("question_id = ? AND is_correct = ?", q.ID, true).Count(&count)
+		if count == 0 {
+			db.Model(&dataModel.QuestionChoice{}).Where("question_id = ? AND choice = ?", q.ID, q.Answer).Update("is_correct", true)
+		}
+	}
 	glog.Info("Done running db migration")
 
 	if runSeeder {
@@ -298,6 +491,33 @@ func runDBMigration() {
 	}
 }
 
+// shortTextColumn check whether a text column of a mysql, postgres or mssql table is still a varchar, or a mysql text,
+// and has to become a long text column. Its type is read from information_schema.
+func shortTextColumn(db *gorm.DB, table string, column string) (bool, error) {
+	schema := map[string]string{"mysql": "DATABASE()", "postgres": "current_schema()", "mssql": "SCHEMA_NAME()"}[db.Dialect().GetName()]
+	if schema == "" {
+		return false, nil
+	}
+
+	var dataType string
+	var length sql.NullInt64
+	err := db.Raw("SELECT data_type, character_maximum_length FROM information_schema.columns WHERE table_schema = "+schema+" AND table_name = ? AND column_name = ?", table, column).
+		Row().Scan(&dataType, &length)
+	if err != nil {
+		return false, err
+	}
+
+	switch strings.ToLower(dataType) {
+	case "varchar", "character varying", "nvarchar", "char", "character", "nchar":
+		//mssql gives -1 as the length of nvarchar(max)
+		return !length.Valid || length.Int64 != -1, nil
+	case "text", "tinytext", "mediumtext":
+		return db.Dialect().GetName() == "mysql", nil
+	}
+
+	return false, nil
+}
+
 func CheckAdmin(c *gin.Context) {
 	db, err := dbFactory.DBConnection()
 	if err != nil {
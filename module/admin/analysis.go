@@ -0,0 +1,95 @@
+package admin
+
+import (
+	"net/http"
+	"okkybudiman/analytics"
+	dataModel "okkybudiman/data/model"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	uuid "github.com/satori/go.uuid"
+)
+
+// GetItemAnalysis report the difficulty, discrimination and distractor statistics of every question of a test
+// and the reliability of the test, computed from the answers of the participants who finished it
+func (ctrl *Controller) GetItemAnalysis(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var test dataModel.Test
+	var questions []dataModel.Question
+	var userIDs []uuid.UUID
+	var answers []dataModel.UserAnswer
+
+	testID, _ := uuid.FromString(c.Param("id"))
+	if err := db.Where("id = ?", testID).First(&test).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Test",
+		})
+		return
+	}
+
+	if err := db.Where("test_id = ?", testID).Preload("QuestionChoices").Preload("TestCases").Order("created_at").Find(&questions).Error; err != nil {
+		glog.Errorf("Failed to load questions: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if err := db.Model(&dataModel.UserScore{}).Where("test_id = ?", testID).Pluck("DISTINCT user_id", &userIDs).Error; err != nil {
+		glog.Errorf("Failed to load participants: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if err := db.Where("test_id = ?", testID).Find(&answers).Error; err != nil {
+		glog.Errorf("Failed to load answers: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]analytics.Item, len(questions))
+	itemIndex := make(map[uuid.UUID]int)
+	for k, q := range questions {
+		itemIndex[q.ID] = k
+		sortChoices(q.QuestionChoices)
+		items[k].ID = q.ID.String()
+		for _, v := range q.QuestionChoices {
+			items[k].Choices = append(items[k].Choices, analytics.Choice{ID: v.ID.String(), Key: v.Key, Correct: v.IsCorrect})
+		}
+	}
+
+	matrix := make([][]analytics.Answer, len(userIDs))
+	userIndex := make(map[uuid.UUID]int)
+	for k, v := range userIDs {
+		userIndex[v] = k
+		matrix[k] = make([]analytics.Answer, len(questions))
+	}
+
+	for _, v := range answers {
+		p, ok := userIndex[v.UserID]
+		i, found := itemIndex[v.QuestionID]
+		if !ok || !found {
+			continue
+		}
+
+		//answers of questions with partial credit are correct only with every part right
+		answer := analytics.Answer{
+			Correct:   v.Point > 0 && v.Point >= questions[i].MaxPoint(),
+			ChoiceIDs: answerChoiceIDs(questions[i], v),
+		}
+		answer.Answered = len(answer.ChoiceIDs) > 0 || strings.TrimSpace(answerText(questions[i], v)) != ""
+		matrix[p][i] = answer
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "success get item analysis",
+		"data":    analytics.Analyze(items, matrix),
+	})
+	return
+}
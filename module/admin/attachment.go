@@ -0,0 +1,270 @@
+package admin
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	dataModel "okkybudiman/data/model"
+	"okkybudiman/storage"
+	"path/filepath"
+	"strings"
+
+	jwt "github.com/appleboy/gin-jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+// uploads larger than defaultMaxUploadSize are refused when the configuration has no maximum size
+const defaultMaxUploadSize = 10 << 20
+
+// attachmentTypes the accepted types of attachment, detected from the content of the file, and their extension.
+// Svg images are not accepted since they can carry scripts.
+var attachmentTypes = map[string]string{
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"audio/mpeg":      ".mp3",
+	"audio/wave":      ".wav",
+	"application/ogg": ".ogg",
+}
+
+// UploadAttachment upload an image or audio file and attach it to the question_id or the choice_id form value.
+// The type is detected from the content of the file, the name and type sent by the client are not trusted.
+func (ctrl *Controller) UploadAttachment(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"file is required"}})
+		return
+	}
+	if file.Size > ctrl.maxUploadSize {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{fmt.Sprintf("file must not be larger than %d bytes", ctrl.maxUploadSize)}})
+		return
+	}
+
+	attachment := dataModel.Attachment{
+		FileName: filepath.Base(strings.Replace(file.Filename, "\\", "/", -1)),
+		Size:     file.Size,
+	}
+	if questionID := c.PostForm("question_id"); questionID != "" {
+		var question dataModel.Question
+		if err := db.Where("id = ?", questionID).First(&question).Error; err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"status":  http.StatusNotFound,
+				"message": "cannot find Question",
+			})
+			return
+		}
+		attachment.QuestionID = &question.ID
+	} else if choiceID := c.PostForm("choice_id"); choiceID != "" {
+		var choice dataModel.QuestionChoice
+		if err := db.Where("id = ?", choiceID).First(&choice).Error; err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"status":  http.StatusNotFound,
+				"message": "cannot find Choice",
+			})
+			return
+		}
+		attachment.QuestionChoiceID = &choice.ID
+	} else {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"question_id or choice_id is required"}})
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"file is empty"}})
+		return
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	extension, ok := attachmentTypes[contentType]
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"file must be a png, jpeg, gif or webp image, or a mp3, wav or ogg audio"}})
+		return
+	}
+	if contentType == "application/ogg" {
+		contentType = "audio/ogg"
+	}
+	attachment.ContentType = contentType
+
+	claims := jwt.ExtractClaims(c)
+	var user dataModel.User
+	db.Where("name = ?", claims["id"].(string)).Find(&user)
+	attachment.UploaderID = &user.ID
+
+	id := uuid.NewV4()
+	attachment.ID = id
+	attachment.StorageKey = "attachments/" + id.String() + extension
+
+	body := io.MultiReader(bytes.NewReader(head), f)
+	if err := ctrl.storage.Put(attachment.StorageKey, body, file.Size, contentType); err != nil {
+		glog.Errorf("Failed to store attachment: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if err := db.Create(&attachment).Error; err != nil {
+		glog.Errorf("Failed to save attachment: %s", err)
+		ctrl.storage.Delete(attachment.StorageKey)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  http.StatusCreated,
+		"message": "success upload attachment",
+		"data":    newAttachmentResponse(attachment),
+	})
+	return
+}
+
+// DeleteAttachment delete an attachment and its file
+func (ctrl *Controller) DeleteAttachment(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var attachment dataModel.Attachment
+	if err := db.Where("id = ?", c.Param("id")).First(&attachment).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Attachment",
+		})
+		return
+	}
+
+	if err := db.Delete(&attachment).Error; err != nil {
+		glog.Errorf("Failed to delete attachment: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if err := ctrl.storage.Delete(attachment.StorageKey); err != nil {
+		glog.Errorf("Failed to delete attachment file %s: %s", attachment.StorageKey, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "success delete attachment",
+	})
+	return
+}
+
+// GetAttachment serve the file of an attachment. Admins can get every attachment, users only the ones of the
+// questions of published tests.
+func (ctrl *Controller) GetAttachment(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var attachment dataModel.Attachment
+	if err := db.Where("id = ?", c.Param("id")).First(&attachment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Attachment",
+		})
+		return
+	}
+
+	claims := jwt.ExtractClaims(c)
+	var user dataModel.User
+	var role dataModel.Role
+	db.Where("name = ?", claims["id"].(string)).Find(&user)
+	db.First(&role, user.RoleID)
+	if role.Name != "Admin" && !attachmentPublished(db, attachment) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  http.StatusForbidden,
+			"message": "you cannot have access",
+		})
+		return
+	}
+
+	file, err := ctrl.storage.Get(attachment.StorageKey)
+	if err == storage.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Attachment",
+		})
+		return
+	}
+	if err != nil {
+		glog.Errorf("Failed to open attachment: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", attachment.FileName))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, file, nil)
+}
+
+// attachmentPublished check whether the question of an attachment, or of its choice, is in a published test
+func attachmentPublished(db *gorm.DB, attachment dataModel.Attachment) bool {
+	questionID := attachment.QuestionID
+	if attachment.QuestionChoiceID != nil {
+		var choice dataModel.QuestionChoice
+		if err := db.Where("id = ?", *attachment.QuestionChoiceID).First(&choice).Error; err != nil {
+			return false
+		}
+		questionID = &choice.QuestionID
+	}
+	if questionID == nil {
+		return false
+	}
+
+	var count int
+	db.Model(&dataModel.Question{}).
+		Joins("JOIN tests ON tests.id = questions.test_id AND tests.deleted_at IS NULL").
+		Where("questions.id = ? AND tests.status = ?", *questionID, dataModel.TestStatusPublished).
+		Count(&count)
+
+	return count > 0
+}
+
+func newAttachmentResponse(attachment dataModel.Attachment) attachmentResponse {
+	return attachmentResponse{
+		ID:          attachment.ID,
+		FileName:    attachment.FileName,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		URL:         "/api/v1/attachment/" + attachment.ID.String(),
+	}
+}
+
+func attachmentResponses(attachments []dataModel.Attachment) []attachmentResponse {
+	var responses []attachmentResponse
+	for _, v := range attachments {
+		responses = append(responses, newAttachmentResponse(v))
+	}
+
+	return responses
+}
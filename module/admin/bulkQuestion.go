@@ -0,0 +1,423 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	dataModel "okkybudiman/data/model"
+	u "okkybudiman/utility"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/golang/glog"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+	validator "gopkg.in/go-playground/validator.v8"
+)
+
+// BulkQuestion create or replace questions of a test in a single transaction.
+// The whole payload is validated first, nothing is saved when one of the items is invalid.
+func (ctrl *Controller) BulkQuestion(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var req bulkQuestionRequest
+	var test dataModel.Test
+
+	if err := c.ShouldBindWith(&req, binding.JSON); err != nil {
+		var errors []string
+		ve, ok := err.(validator.ValidationErrors)
+		if ok {
+			for _, v := range ve {
+				errors = append(errors, fmt.Sprintf("%s is %s", v.Field, v.Tag))
+			}
+		} else {
+			errors = append(errors, err.Error())
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": errors})
+		return
+	}
+
+	uid, _ := uuid.FromString(req.TestID)
+	if err := db.Where("id = ?", uid).First(&test).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Test",
+		})
+		return
+	}
+
+	available := test.TotalQuestion
+	if !req.Replace {
+		var count int
+		db.Model(&dataModel.Question{}).Where("test_id = ?", uid).Count(&count)
+		available = available - count
+	}
+
+	results, valid := validateQuestions(db, uid, req.Questions, available)
+	if !valid {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "invalid questions, nothing is saved",
+			"data":    results,
+		})
+		return
+	}
+
+	tx := db.Begin()
+	var storageKeys []string
+	if req.Replace {
+		//the test is locked until the questions are replaced so no attempt starts in between, see AttempTest
+		if err := u.ForUpdate(tx).Where("id = ?", uid).First(&test).Error; err != nil {
+			tx.Rollback()
+			glog.Errorf("Failed to lock test: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		//questions already attempted or answered by users cannot be replaced
+		var attempted, answered int
+		tx.Model(&dataModel.UserAttemptTest{}).Where("test_id = ?", uid).Count(&attempted)
+		tx.Model(&dataModel.UserAnswer{}).Where("test_id = ?", uid).Count(&answered)
+		if attempted > 0 || answered > 0 {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{
+				"status":  http.StatusConflict,
+				"message": "test already answered by users, questions cannot be replaced",
+			})
+			return
+		}
+
+		if storageKeys, err = deleteQuestions(tx, uid); err != nil {
+			tx.Rollback()
+			glog.Errorf("Failed to delete questions: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := saveQuestions(tx, uid, req.Questions, results); err != nil {
+		tx.Rollback()
+		glog.Errorf("Failed to save questions: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		glog.Errorf("Failed to commit questions: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	//the files of the deleted attachments are only removed once their rows are gone
+	for _, key := range storageKeys {
+		if err := ctrl.storage.Delete(key); err != nil {
+			glog.Errorf("Failed to delete attachment file %s: %s", key, err)
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  http.StatusCreated,
+		"message": "success save questions",
+		"data":    results,
+	})
+	return
+}
+
+// validateQuestions check every question of the payload and collect the errors by item index.
+// available is the number of questions the test of testID can still hold.
+func validateQuestions(db *gorm.DB, testID uuid.UUID, items []questions, available int) ([]bulkQuestionResult, bool) {
+	results := make([]bulkQuestionResult, len(items))
+	valid := true
+
+	for i, q := range items {
+		var errors []string
+		results[i].Index = i
+
+		if strings.TrimSpace(q.Question) == "" {
+			errors = append(errors, "question is required")
+		}
+
+		format := q.Format
+		if format == "" {
+			format = dataModel.QuestionFormatPlain
+		}
+		if !dataModel.IsValidQuestionFormat(format) {
+			errors = append(errors, "format must be plain, markdown or markdown_math")
+		} else {
+			explanationFormat := dataModel.Question{Format: format}.ExplanationFormat()
+			errors = append(errors, validateContent("question", q.Question, format)...)
+			errors = append(errors, validateContent("answer", q.Answer, dataModel.QuestionFormatPlain)...)
+			errors = append(errors, validateContent("explanation", q.Explanation, explanationFormat)...)
+			for k, v := range q.Choices {
+				errors = append(errors, validateContent(fmt.Sprintf("choice %d", k+1), v.Choice, format)...)
+				errors = append(errors, validateContent(fmt.Sprintf("feedback of choice %d", k+1), v.Feedback, explanationFormat)...)
+				errors = append(errors, validateContent(fmt.Sprintf("match of choice %d", k+1), v.Match, format)...)
+			}
+			errors = append(errors, validateBlankContent(q.Blanks)...)
+		}
+
+		switch q.Type {
+		case "", dataModel.QuestionTypeSingleChoice, dataModel.QuestionTypeMultipleChoice:
+			errors = append(errors, validateChoices(q)...)
+		case dataModel.QuestionTypeTextEntry:
+			if strings.TrimSpace(q.Answer) == "" {
+				errors = append(errors, "answer is required")
+			}
+			if len(q.Choices) > 0 {
+				errors = append(errors, "text entry question has no choices")
+			}
+		case dataModel.QuestionTypeCalculated:
+			errors = append(errors, validateTemplate(q.Question, q.Explanation, q.Variables, q.AnswerExpression)...)
+			if q.AnswerTolerance < 0 {
+				errors = append(errors, "answer_tolerance must not be negative")
+			}
+			if len(q.Choices) > 0 {
+				errors = append(errors, "calculated question has no choices")
+			}
+		case dataModel.QuestionTypeProgramming:
+			errors = append(errors, validateProgramming(q.Language, q.TimeLimit, q.MemoryLimit, q.TestCases)...)
+			if len(q.Choices) > 0 {
+				errors = append(errors, "programming question has no choices")
+			}
+		case dataModel.QuestionTypeMatching, dataModel.QuestionTypeOrdering:
+			errors = append(errors, validateArrangement(q)...)
+		case dataModel.QuestionTypeCloze:
+			errors = append(errors, validateCloze(q.Question, q.Blanks)...)
+			if len(q.Choices) > 0 {
+				errors = append(errors, "cloze question has no choices")
+			}
+		default:
+			errors = append(errors, "type must be single_choice, multiple_choice, text_entry, calculated, programming, matching, ordering or cloze")
+		}
+
+		if q.Difficulty != "" && !dataModel.IsValidQuestionDifficulty(q.Difficulty) {
+			errors = append(errors, "difficulty must be easy, medium or hard")
+		}
+		if _, ok := resolveTopic(db, q.TopicID); !ok {
+			errors = append(errors, "cannot find Topic")
+		}
+		if _, ok := resolveSection(db, testID, q.SectionID); !ok {
+			errors = append(errors, "cannot find Section of the test")
+		}
+
+		if i >= available {
+			errors = append(errors, "question exceeds total question of the test")
+		}
+
+		if len(errors) > 0 {
+			results[i].Errors = errors
+			valid = false
+		}
+	}
+
+	return results, valid
+}
+
+// validateContent check the length of a text of a question and, when it is written with math, its math.
+// name is the part of the question reported in the errors.
+func validateContent(name string, text string, format string) []string {
+	var errors []string
+
+	if len(text) > maxContentLength {
+		errors = append(errors, fmt.Sprintf("%s must not be longer than %d bytes", name, maxContentLength))
+	}
+	if format == dataModel.QuestionFormatMarkdownMath {
+		if err := u.ValidateMath(text); err != nil {
+			errors = append(errors, fmt.Sprintf("%s: %s", name, err))
+		}
+	}
+
+	return errors
+}
+
+// validateChoices check the choices and the answer keys of a choice question
+func validateChoices(q questions) []string {
+	var errors []string
+
+	if len(q.Choices) < minChoice {
+		errors = append(errors, fmt.Sprintf("minimum total choices is %d", minChoice))
+	}
+	if len(q.Choices) > maxChoice {
+		errors = append(errors, fmt.Sprintf("maximum total choices is %d", maxChoice))
+	}
+
+	seen := make(map[string]bool)
+	for k, v := range q.Choices {
+		choice := strings.TrimSpace(v.Choice)
+		if choice == "" {
+			errors = append(errors, fmt.Sprintf("choice %d is required", k+1))
+			continue
+		}
+		if seen[choice] {
+			errors = append(errors, fmt.Sprintf("choice %d is duplicated", k+1))
+		}
+		seen[choice] = true
+	}
+
+	keys := answerKeys(q.AnswerKey, q.AnswerKeys)
+	if q.Type == dataModel.QuestionTypeMultipleChoice {
+		if len(keys) == 0 {
+			errors = append(errors, "answer_keys is required")
+		}
+	} else if len(keys) != 1 {
+		errors = append(errors, "single choice question has exactly one answer_key")
+	}
+
+	seenKey := make(map[int]bool)
+	for _, key := range keys {
+		if key < 1 || key > len(q.Choices) {
+			errors = append(errors, fmt.Sprintf("answer_key %d does not match any choice", key))
+		}
+		if seenKey[key] {
+			errors = append(errors, fmt.Sprintf("answer_key %d is duplicated", key))
+		}
+		seenKey[key] = true
+	}
+
+	return errors
+}
+
+// saveQuestions save questions and their choices using tx and fill the created ids into results.
+func saveQuestions(tx *gorm.DB, testID uuid.UUID, items []questions, results []bulkQuestionResult) error {
+	for i, q := range items {
+		difficulty := q.Difficulty
+		if difficulty == "" {
+			difficulty = dataModel.QuestionDifficultyMedium
+		}
+		questionType := q.Type
+		if questionType == "" {
+			questionType = dataModel.QuestionTypeSingleChoice
+		}
+		format := q.Format
+		if format == "" {
+			format = dataModel.QuestionFormatPlain
+		}
+		topicID, _ := resolveTopic(tx, q.TopicID)
+		sectionID, _ := resolveSection(tx, testID, q.SectionID)
+
+		question := dataModel.Question{
+			Question:    q.Question,
+			Type:        questionType,
+			Format:      format,
+			Difficulty:  difficulty,
+			Explanation: q.Explanation,
+			TestID:      testID,
+			TopicID:     topicID,
+			SectionID:   sectionID,
+		}
+		//the answer of programming questions is a reference solution shown in review
+		if questionType == dataModel.QuestionTypeTextEntry || questionType == dataModel.QuestionTypeProgramming {
+			question.Answer = q.Answer
+		}
+		if questionType == dataModel.QuestionTypeProgramming {
+			question.Language = q.Language
+			question.TimeLimit = q.TimeLimit
+			question.MemoryLimit = q.MemoryLimit
+		}
+		if questionType == dataModel.QuestionTypeCalculated {
+			question.Variables = encodeVariables(q.Variables)
+			question.AnswerExpression = q.AnswerExpression
+			question.AnswerTolerance = q.AnswerTolerance
+		}
+		if questionType == dataModel.QuestionTypeMatching || questionType == dataModel.QuestionTypeOrdering {
+			question.PartialCredit = q.PartialCredit
+			question.ItemPoint = itemPoint(q.ItemPoint)
+		}
+		if questionType == dataModel.QuestionTypeCloze {
+			question.Blanks = encodeBlanks(q.Blanks)
+		}
+		if err := tx.Create(&question).Error; err != nil {
+			return err
+		}
+		if questionType == dataModel.QuestionTypeProgramming {
+			if err := saveTestCases(tx, question.ID, q.TestCases); err != nil {
+				return err
+			}
+		}
+
+		if len(q.Tags) > 0 {
+			tags, err := findOrCreateTags(tx, q.Tags)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&question).Association("Tags").Replace(tags).Error; err != nil {
+				return err
+			}
+		}
+
+		correct := make(map[int]bool)
+		for _, key := range answerKeys(q.AnswerKey, q.AnswerKeys) {
+			correct[key] = true
+		}
+
+		id := question.ID
+		results[i].ID = &id
+		for key, v := range q.Choices {
+			choice := dataModel.QuestionChoice{
+				Choice:     v.Choice,
+				Key:        key + 1,
+				IsCorrect:  correct[key+1],
+				Feedback:   v.Feedback,
+				QuestionID: question.ID,
+			}
+			if questionType == dataModel.QuestionTypeMatching {
+				choice.Match = v.Match
+			}
+			if err := tx.Create(&choice).Error; err != nil {
+				return err
+			}
+
+			results[i].ChoiceIDs = append(results[i].ChoiceIDs, choice.ID)
+		}
+	}
+
+	return nil
+}
+
+// deleteQuestions delete every question of a test together with their choices, tags, test cases and attachments.
+// It returns the storage keys of the files of the deleted attachments.
+func deleteQuestions(tx *gorm.DB, testID uuid.UUID) ([]string, error) {
+	var ids, choiceIDs []uuid.UUID
+	if err := tx.Model(&dataModel.Question{}).Where("test_id = ?", testID).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if err := tx.Model(&dataModel.QuestionChoice{}).Where("question_id IN (?)", ids).Pluck("id", &choiceIDs).Error; err != nil {
+		return nil, err
+	}
+
+	var attachments []dataModel.Attachment
+	attachmentQuery := tx.Where("question_id IN (?)", ids)
+	if len(choiceIDs) > 0 {
+		attachmentQuery = attachmentQuery.Or("question_choice_id IN (?)", choiceIDs)
+	}
+	if err := attachmentQuery.Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	var storageKeys []string
+	for _, v := range attachments {
+		if err := tx.Delete(&v).Error; err != nil {
+			return nil, err
+		}
+		storageKeys = append(storageKeys, v.StorageKey)
+	}
+
+	if err := tx.Exec("DELETE FROM question_tags WHERE question_id IN (?)", ids).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("question_id IN (?)", ids).Delete(&dataModel.QuestionChoice{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("question_id IN (?)", ids).Delete(&dataModel.TestCase{}).Error; err != nil {
+		return nil, err
+	}
+
+	return storageKeys, tx.Where("test_id = ?", testID).Delete(&dataModel.Question{}).Error
+}
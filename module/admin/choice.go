@@ -0,0 +1,297 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	dataModel "okkybudiman/data/model"
+	u "okkybudiman/utility"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/golang/glog"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+	validator "gopkg.in/go-playground/validator.v8"
+)
+
+// CreateChoice add a choice to an existing question.
+// The choice is appended unless key is set, the keys of the following choices are shifted.
+func (ctrl *Controller) CreateChoice(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var question dataModel.Question
+	var choices []dataModel.QuestionChoice
+	var req createChoiceRequest
+
+	if err := c.ShouldBindWith(&req, binding.JSON); err != nil {
+		var errors []string
+		ve, ok := err.(validator.ValidationErrors)
+		if ok {
+			for _, v := range ve {
+				errors = append(errors, fmt.Sprintf("%s is %s", v.Field, v.Tag))
+			}
+		} else {
+			errors = append(errors, err.Error())
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": errors})
+		return
+	}
+
+	uid, _ := uuid.FromString(req.QuestionID)
+	if err := db.Where("id = ?", uid).First(&question).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Question",
+		})
+		return
+	}
+
+	if !question.HasChoices() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "text entry question has no choices",
+		})
+		return
+	}
+
+	db.Where("question_id = ?", uid).Find(&choices)
+	sortChoices(choices)
+
+	if len(choices) >= maxChoice {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": fmt.Sprintf("maximum total choices is %d. delete choice first and then add one", maxChoice),
+		})
+		return
+	}
+
+	key := req.Key
+	if key == 0 {
+		key = len(choices) + 1
+	}
+	if key < 1 || key > len(choices)+1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": fmt.Sprintf("key must be between 1 and %d", len(choices)+1),
+		})
+		return
+	}
+
+	errors := validateChoiceItem(question.Type, req.Choice, req.Match)
+	errors = append(errors, validateContent("choice", req.Choice, question.Format)...)
+	errors = append(errors, validateContent("feedback", req.Feedback, question.ExplanationFormat())...)
+	errors = append(errors, validateContent("match", req.Match, question.Format)...)
+	if len(errors) > 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": errors})
+		return
+	}
+
+	for _, v := range choices {
+		//distractors of matching questions have no text
+		if strings.TrimSpace(req.Choice) != "" && strings.TrimSpace(v.Choice) == strings.TrimSpace(req.Choice) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "choice already exist",
+			})
+			return
+		}
+	}
+
+	//the choices of matching and ordering questions are never marked as correct, their match or key is the answer
+	choice := dataModel.QuestionChoice{
+		Choice:     req.Choice,
+		Key:        key,
+		IsCorrect:  req.IsCorrect && question.HasAnswerKeys(),
+		Feedback:   req.Feedback,
+		Match:      req.Match,
+		QuestionID: uid,
+	}
+
+	tx := db.Begin()
+	if err := tx.Create(&choice).Error; err != nil {
+		tx.Rollback()
+		glog.Errorf("Failed to create choice: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	//a single choice question has one answer key, the new correct choice replaces the old one
+	if req.IsCorrect && question.Type == dataModel.QuestionTypeSingleChoice {
+		if err := tx.Model(&dataModel.QuestionChoice{}).Where("question_id = ? AND id <> ?", uid, choice.ID).Update("is_correct", false).Error; err != nil {
+			tx.Rollback()
+			glog.Errorf("Failed to update answer key: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		for k := range choices {
+			choices[k].IsCorrect = false
+		}
+	}
+
+	ordered := append([]dataModel.QuestionChoice{}, choices[:key-1]...)
+	ordered = append(ordered, choice)
+	ordered = append(ordered, choices[key-1:]...)
+	if err := renumberChoices(tx, ordered); err != nil {
+		tx.Rollback()
+		glog.Errorf("Failed to renumber choices: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		glog.Errorf("Failed to commit choice: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  http.StatusCreated,
+		"message": "success create choice",
+		"data":    choiceResponses(question, ordered),
+	})
+	return
+}
+
+// ReorderChoice set the order of the choices of a question.
+// choice_ids must list every choice of the question once, keys are renumbered following it.
+func (ctrl *Controller) ReorderChoice(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var choices []dataModel.QuestionChoice
+	var req reorderChoiceRequest
+
+	if err := c.ShouldBindWith(&req, binding.JSON); err != nil {
+		var errors []string
+		ve, ok := err.(validator.ValidationErrors)
+		if ok {
+			for _, v := range ve {
+				errors = append(errors, fmt.Sprintf("%s is %s", v.Field, v.Tag))
+			}
+		} else {
+			errors = append(errors, err.Error())
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": errors})
+		return
+	}
+
+	uid, _ := uuid.FromString(req.QuestionID)
+	db.Where("question_id = ?", uid).Find(&choices)
+	if len(choices) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Question",
+		})
+		return
+	}
+
+	if len(req.ChoiceIDs) != len(choices) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": fmt.Sprintf("choice_ids must contain all %d choices of the question", len(choices)),
+		})
+		return
+	}
+
+	byID := make(map[uuid.UUID]dataModel.QuestionChoice)
+	for _, v := range choices {
+		byID[v.ID] = v
+	}
+
+	var ordered []dataModel.QuestionChoice
+	for _, v := range req.ChoiceIDs {
+		id, _ := uuid.FromString(v)
+		choice, ok := byID[id]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": fmt.Sprintf("choice %s is not a choice of the question or is duplicated", v),
+			})
+			return
+		}
+
+		delete(byID, id)
+		ordered = append(ordered, choice)
+	}
+
+	tx := db.Begin()
+	if err := renumberChoices(tx, ordered); err != nil {
+		tx.Rollback()
+		glog.Errorf("Failed to renumber choices: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		glog.Errorf("Failed to commit choices: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var question dataModel.Question
+	db.Where("id = ?", uid).First(&question)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "success reorder choice",
+		"data":    choiceResponses(question, ordered),
+	})
+	return
+}
+
+// sortChoices sort choices by their key.
+func sortChoices(choices []dataModel.QuestionChoice) {
+	sort.SliceStable(choices, func(i, j int) bool {
+		return choices[i].Key < choices[j].Key
+	})
+}
+
+// renumberChoices set the key of every choice to its position in choices, starting from 1.
+func renumberChoices(tx *gorm.DB, choices []dataModel.QuestionChoice) error {
+	for k := range choices {
+		if choices[k].Key == k+1 {
+			continue
+		}
+
+		choices[k].Key = k + 1
+		if err := tx.Model(&choices[k]).Update("key", k+1).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// choiceResponses build the responses of the choices of question, their text is rendered in the format of the question
+func choiceResponses(question dataModel.Question, choices []dataModel.QuestionChoice) []questionChoiceResponse {
+	var responses []questionChoiceResponse
+	for _, v := range choices {
+		responses = append(responses, questionChoiceResponse{
+			ID:           v.ID,
+			Key:          v.Key,
+			Choice:       v.Choice,
+			ChoiceHTML:   u.RenderContent(v.Choice, question.Format),
+			IsCorrect:    v.IsCorrect,
+			Feedback:     v.Feedback,
+			FeedbackHTML: u.RenderContent(v.Feedback, question.ExplanationFormat()),
+			Match:        v.Match,
+			MatchHTML:    u.RenderContent(v.Match, question.Format),
+
+			Attachments: attachmentResponses(v.Attachments),
+		})
+	}
+
+	return responses
+}
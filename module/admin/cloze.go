@@ -0,0 +1,141 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	dataModel "okkybudiman/data/model"
+	"strings"
+)
+
+// bounds of the blanks of a cloze question
+const (
+	maxBlank  = 50
+	maxOption = 10
+)
+
+// validateCloze check that the blank markers of a cloze question and its blanks agree: the markers [[1]] to [[n]] are
+// each written once for the n blanks. Every blank needs an answer, the answers of a dropdown blank are some of its
+// options.
+func validateCloze(question string, blanks []dataModel.ClozeBlank) []string {
+	var errors []string
+
+	if len(blanks) == 0 {
+		errors = append(errors, "blanks is required")
+	}
+	if len(blanks) > maxBlank {
+		errors = append(errors, fmt.Sprintf("maximum total blanks is %d", maxBlank))
+	}
+
+	seen := make(map[int]bool)
+	for _, number := range dataModel.BlankNumbers(question) {
+		if number < 1 || number > len(blanks) {
+			errors = append(errors, fmt.Sprintf("blank [[%d]] does not match any blank", number))
+			continue
+		}
+		if seen[number] {
+			errors = append(errors, fmt.Sprintf("blank [[%d]] is written more than once", number))
+		}
+		seen[number] = true
+	}
+	for k := range blanks {
+		if !seen[k+1] {
+			errors = append(errors, fmt.Sprintf("blank %d has no [[%d]] in the question", k+1, k+1))
+		}
+	}
+
+	for k, v := range blanks {
+		for _, err := range validateBlank(v) {
+			errors = append(errors, fmt.Sprintf("blank %d: %s", k+1, err))
+		}
+	}
+
+	return errors
+}
+
+// validateBlank check the options, the answers and the point of a blank
+func validateBlank(blank dataModel.ClozeBlank) []string {
+	var errors []string
+
+	if blank.Point < 0 {
+		errors = append(errors, "point must not be negative")
+	}
+
+	answers := 0
+	for _, v := range blank.Answers {
+		if strings.TrimSpace(v) != "" {
+			answers++
+		}
+	}
+	if answers == 0 {
+		errors = append(errors, "answers is required")
+	}
+
+	switch blank.Type {
+	case dataModel.BlankTypeText:
+		if len(blank.Options) > 0 {
+			errors = append(errors, "text blank has no options")
+		}
+	case dataModel.BlankTypeDropdown:
+		if len(blank.Options) < minChoice {
+			errors = append(errors, fmt.Sprintf("minimum total options is %d", minChoice))
+		}
+		if len(blank.Options) > maxOption {
+			errors = append(errors, fmt.Sprintf("maximum total options is %d", maxOption))
+		}
+
+		options := make(map[string]bool)
+		for k, v := range blank.Options {
+			option := strings.TrimSpace(v)
+			if option == "" {
+				errors = append(errors, fmt.Sprintf("option %d is required", k+1))
+				continue
+			}
+			if options[option] {
+				errors = append(errors, fmt.Sprintf("option %d is duplicated", k+1))
+			}
+			options[option] = true
+		}
+		for _, v := range blank.Answers {
+			if strings.TrimSpace(v) != "" && !options[strings.TrimSpace(v)] {
+				errors = append(errors, fmt.Sprintf("answer %s is not an option", v))
+			}
+		}
+	default:
+		errors = append(errors, "type must be dropdown or text")
+	}
+
+	return errors
+}
+
+// encodeBlanks encode the blanks of a cloze question to be saved, a blank is worth 1 point when its point is not set
+func encodeBlanks(blanks []dataModel.ClozeBlank) string {
+	if len(blanks) == 0 {
+		return ""
+	}
+
+	saved := make([]dataModel.ClozeBlank, len(blanks))
+	for k, v := range blanks {
+		if v.Point == 0 {
+			v.Point = 1
+		}
+		saved[k] = v
+	}
+	data, _ := json.Marshal(saved)
+
+	return string(data)
+}
+
+// validateBlankContent check the length of the options and the answers of the blanks, they are plain text
+func validateBlankContent(blanks []dataModel.ClozeBlank) []string {
+	var errors []string
+	for k, v := range blanks {
+		for i, option := range v.Options {
+			errors = append(errors, validateContent(fmt.Sprintf("option %d of blank %d", i+1, k+1), option, dataModel.QuestionFormatPlain)...)
+		}
+		for i, answer := range v.Answers {
+			errors = append(errors, validateContent(fmt.Sprintf("answer %d of blank %d", i+1, k+1), answer, dataModel.QuestionFormatPlain)...)
+		}
+	}
+
+	return errors
+}
@@ -4,9 +4,15 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"okkybudiman/analytics"
 	"okkybudiman/data"
 	dataModel "okkybudiman/data/model"
+	"okkybudiman/storage"
+	u "okkybudiman/utility"
+	"strings"
+	"time"
 
+	jwt "github.com/appleboy/gin-jwt"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/golang/glog"
@@ -16,19 +22,37 @@ import (
 
 type Controller struct {
 	dbFactory *data.DBFactory
+	// score distributions of the tests, updated as new submissions arrive
+	statistics *analytics.Cache
+	// files attached to the questions and choices
+	storage       storage.Storage
+	maxUploadSize int64
 }
 
 const (
 	// See http://golang.org/pkg/time/#Parse
 	timeFormat = "2006-01-02 15:04 MST"
+
+	// minimum and maximum number of choices a question can have
+	minChoice = 2
+	maxChoice = 6
+
+	// maximum size in bytes of the text of a question, a choice, an explanation or a feedback, as a MySQL text column
+	maxContentLength = 65535
 )
 
-func NewController(dbFactory *data.DBFactory) (*Controller, error) {
+func NewController(dbFactory *data.DBFactory, store storage.Storage, maxUploadSize int64) (*Controller, error) {
 	if dbFactory == nil {
 		return nil, errors.New("failed to instantiate rate controller")
 	}
+	if store == nil {
+		return nil, errors.New("failed to instantiate storage")
+	}
+	if maxUploadSize <= 0 {
+		maxUploadSize = defaultMaxUploadSize
+	}
 
-	return &Controller{dbFactory: dbFactory}, nil
+	return &Controller{dbFactory: dbFactory, statistics: analytics.NewCache(), storage: store, maxUploadSize: maxUploadSize}, nil
 }
 
 func (ctrl *Controller) CreateTest(c *gin.Context) {
@@ -64,14 +88,86 @@ func (ctrl *Controller) CreateTest(c *gin.Context) {
 		})
 		return
 	}
+	status := req.Status
+	if status == "" {
+		status = dataModel.TestStatusPublished
+	}
+	if !dataModel.IsValidTestStatus(status) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"status must be draft, published or archived"}})
+		return
+	}
+	leaderboard := req.Leaderboard
+	if leaderboard == "" {
+		leaderboard = dataModel.LeaderboardDisabled
+	}
+	if !dataModel.IsValidLeaderboard(leaderboard) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"leaderboard must be disabled, names, initials or anonymous"}})
+		return
+	}
+	reviewPolicy := req.ReviewPolicy
+	if reviewPolicy == "" {
+		reviewPolicy = dataModel.ReviewNever
+	}
+	if !dataModel.IsValidReviewPolicy(reviewPolicy) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"review_policy must be immediately, after_close or never"}})
+		return
+	}
+	availableUntil, err := parseAvailableUntil(req.AvailableUntil)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+	if req.MaxAttempts < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"max_attempts must not be negative"}})
+		return
+	}
+	if req.TargetStandardError < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"target_standard_error must not be negative"}})
+		return
+	}
+
+	subjectID, ok := resolveSubject(db, req.SubjectID)
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Subject",
+		})
+		return
+	}
+
+	claims := jwt.ExtractClaims(c)
+	name := claims["id"].(string)
+	var user dataModel.User
+	db.Where("name = ?", name).Find(&user)
+	creatorID := user.ID
+
 	test = dataModel.Test{
-		Name:          req.Name,
-		Description:   req.Description,
-		TotalQuestion: req.TotalQuestion,
+		Name:                req.Name,
+		Description:         req.Description,
+		TotalQuestion:       req.TotalQuestion,
+		Status:              status,
+		Leaderboard:         leaderboard,
+		ReviewPolicy:        reviewPolicy,
+		AvailableUntil:      availableUntil,
+		MaxAttempts:         req.MaxAttempts,
+		AllowPractice:       req.AllowPractice,
+		TargetStandardError: req.TargetStandardError,
+		CreatorID:           &creatorID,
+		SubjectID:           subjectID,
 	}
 
 	db.Save(&test)
 
+	if len(req.Tags) > 0 {
+		tags, err := findOrCreateTags(db, req.Tags)
+		if err != nil {
+			glog.Errorf("Failed to save tags: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		db.Model(&test).Association("Tags").Replace(tags)
+	}
+
 	c.JSON(http.StatusCreated, gin.H{
 		"status":  http.StatusCreated,
 		"message": "success create test",
@@ -91,11 +187,7 @@ func (ctrl *Controller) CreateQuestion(c *gin.Context) {
 
 	var req questionRequest
 	var question dataModel.Question
-	var choices dataModel.QuestionChoice
 	var test dataModel.Test
-	if err != nil {
-		fmt.Printf("Something went wrong: %s", err)
-	}
 
 	if err := c.ShouldBindWith(&req, binding.JSON); err != nil {
 		var errors []string
@@ -125,31 +217,35 @@ func (ctrl *Controller) CreateQuestion(c *gin.Context) {
 			})
 			return
 		}
-		totalLeft := test.TotalQuestion - count
-		for k, q := range req.Questions {
-			question = dataModel.Question{
-				Question: q.Question,
-				Answer:   q.Answer,
-				TestID:   uid,
-			}
-
-			//handle total question
-			if k < totalLeft {
-				db.Save(&question)
-				for key, v := range q.Choices {
-					choices = dataModel.QuestionChoice{
-						Choice:     v.Choice,
-						Key:        key + 1,
-						QuestionID: question.ID,
-					}
 
-					db.Save(&choices)
-				}
-			}
+		//reject the whole payload instead of dropping questions over the total
+		results, valid := validateQuestions(db, uid, req.Questions, test.TotalQuestion-count)
+		if !valid {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "invalid questions, nothing is saved",
+				"data":    results,
+			})
+			return
 		}
+
+		tx := db.Begin()
+		if err := saveQuestions(tx, uid, req.Questions, results); err != nil {
+			tx.Rollback()
+			glog.Errorf("Failed to save questions: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit().Error; err != nil {
+			glog.Errorf("Failed to commit questions: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
 		c.JSON(http.StatusCreated, gin.H{
 			"status":  http.StatusCreated,
 			"message": "success create question",
+			"data":    results,
 		})
 		return
 	}
@@ -183,26 +279,24 @@ func (ctrl *Controller) GetDetailTest(c *gin.Context) {
 		response.Name = test.Name
 		response.Description = test.Description
 		response.TotalQuestion = test.TotalQuestion
-
-		if err := db.Where("test_id =?", test.ID).Find(&questions).Error; err == nil {
-			for k, v := range questions {
-				question := questionResponse{
-					ID:       v.ID,
-					Question: v.Question,
-					Answer:   v.Answer,
-				}
-				response.Questions = append(response.Questions, question)
-				if err := db.Order("key").Where("question_id =?", v.ID).Find(&choices).Error; err == nil {
-					for _, q := range choices {
-						choice := questionChoiceResponse{
-							ID:     q.ID,
-							Key:    q.Key,
-							Choice: q.Choice,
-						}
-
-						response.Questions[k].Choices = append(response.Questions[k].Choices, choice)
-					}
-				}
+		response.SubjectID = test.SubjectID
+		response.Leaderboard = test.Leaderboard
+		response.ReviewPolicy = test.ReviewPolicy
+		response.AvailableUntil = test.AvailableUntil
+		response.MaxAttempts = test.MaxAttempts
+		response.AllowPractice = test.AllowPractice
+		response.TargetStandardError = test.TargetStandardError
+		db.Model(&test).Related(&test.Tags, "Tags")
+		response.Tags = tagNames(test.Tags)
+		db.Where("test_id = ?", test.ID).Find(&test.Sections)
+		sortSections(test.Sections)
+		response.Sections = sectionResponses(db, test.Sections)
+
+		if err := db.Preload("Tags").Preload("Attachments").Preload("TestCases").Where("test_id =?", test.ID).Find(&questions).Error; err == nil {
+			for _, v := range questions {
+				db.Preload("Attachments").Where("question_id =?", v.ID).Find(&choices)
+				v.QuestionChoices = choices
+				response.Questions = append(response.Questions, newQuestionResponse(v))
 			}
 
 			c.JSON(http.StatusOK, gin.H{
@@ -228,28 +322,68 @@ func (ctrl *Controller) GetListTest(c *gin.Context) {
 
 	var tests []dataModel.Test
 	var responses []testResponse
+	var total int
+
+	pagination, err := u.NewPagination(c, testSortColumns, "created_at")
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
 
-	if err := db.Find(&tests).Error; err == nil {
+	query := db.Model(&dataModel.Test{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if creatorID := c.Query("creator_id"); creatorID != "" {
+		query = query.Where("creator_id = ?", creatorID)
+	}
+	if subjectID := c.Query("subject_id"); subjectID != "" {
+		query = query.Where("subject_id = ?", subjectID)
+	}
+	if tag := c.Query("tag"); tag != "" {
+		query = query.Where("id IN (?)", db.Table("test_tags").Select("test_tags.test_id").
+			Joins("JOIN tags ON tags.id = test_tags.tag_id").Where("tags.name = ?", normalizeTag(tag)).QueryExpr())
+	}
+	if pagination.Search != "" {
+		query = pagination.SearchWhere(query, "name", "description")
+	}
+	query.Count(&total)
+
+	if err := pagination.Apply(query).Preload("Tags").Find(&tests).Error; err == nil {
 
 		for _, v := range tests {
 			res := testResponse{
-				ID:            v.ID,
-				Name:          v.Name,
-				Description:   v.Description,
-				TotalQuestion: v.TotalQuestion,
+				ID:                  v.ID,
+				Name:                v.Name,
+				Description:         v.Description,
+				TotalQuestion:       v.TotalQuestion,
+				Status:              v.Status,
+				CreatorID:           v.CreatorID,
+				SubjectID:           v.SubjectID,
+				Tags:                tagNames(v.Tags),
+				Leaderboard:         v.Leaderboard,
+				ReviewPolicy:        v.ReviewPolicy,
+				AvailableUntil:      v.AvailableUntil,
+				MaxAttempts:         v.MaxAttempts,
+				AllowPractice:       v.AllowPractice,
+				TargetStandardError: v.TargetStandardError,
 			}
 			responses = append(responses, res)
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"status":  http.StatusOK,
-			"message": "success get list test",
-			"data":    responses,
-			"total":   len(tests),
+			"status":     http.StatusOK,
+			"message":    "success get list test",
+			"data":       responses,
+			"total":      total,
+			"pagination": pagination.Meta(total),
 		})
 
 		return
 	}
+
+	glog.Errorf("Failed to get list test: %s", err)
+	c.AbortWithStatus(http.StatusInternalServerError)
 }
 
 func (ctrl *Controller) GetParticipant(c *gin.Context) {
@@ -263,15 +397,41 @@ func (ctrl *Controller) GetParticipant(c *gin.Context) {
 	defer db.Close()
 
 	var userParticipant []dataModel.UserAttemptTest
+	var total int
 
-	db.Find(&userParticipant)
+	pagination, err := u.NewPagination(c, participantSortColumns, "start_test")
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	query := db.Model(&dataModel.UserAttemptTest{}).Where("mode <> ?", dataModel.AttemptModePractice)
+	if mode := c.Query("mode"); mode != "" {
+		query = db.Model(&dataModel.UserAttemptTest{}).Where("mode = ?", mode)
+	}
+	if testID := c.Query("test_id"); testID != "" {
+		query = query.Where("test_id = ?", testID)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	switch c.Query("status") {
+	case "finished":
+		query = query.Where("is_finished = ?", true)
+	case "started":
+		query = query.Where("is_finished = ?", false)
+	}
+	query.Count(&total)
+
+	pagination.Apply(query).Find(&userParticipant)
 
 	if len(userParticipant) == 0 {
 		c.JSON(http.StatusOK, gin.H{
 			"status":            http.StatusOK,
 			"message":           "no users are taking this test",
 			"data":              userParticipant,
-			"total_participant": len(userParticipant),
+			"total_participant": total,
+			"pagination":        pagination.Meta(total),
 		})
 
 		return
@@ -281,7 +441,8 @@ func (ctrl *Controller) GetParticipant(c *gin.Context) {
 		"status":            http.StatusOK,
 		"message":           "success get data",
 		"data":              userParticipant,
-		"total_participant": len(userParticipant),
+		"total_participant": total,
+		"pagination":        pagination.Meta(total),
 	})
 
 	return
@@ -316,12 +477,81 @@ func (ctrl *Controller) UpdateTest(c *gin.Context) {
 
 	uid, err := uuid.FromString(req.TestID)
 	if err := db.Where("id = ?", uid).First(&test).Error; err == nil {
+		if req.Status != "" && !dataModel.IsValidTestStatus(req.Status) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"status must be draft, published or archived"}})
+			return
+		}
+		if req.Leaderboard != "" && !dataModel.IsValidLeaderboard(req.Leaderboard) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"leaderboard must be disabled, names, initials or anonymous"}})
+			return
+		}
+		if req.ReviewPolicy != "" && !dataModel.IsValidReviewPolicy(req.ReviewPolicy) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"review_policy must be immediately, after_close or never"}})
+			return
+		}
+		//available_until is changed only when it is sent, an empty value removes it
+		if req.AvailableUntil != nil {
+			availableUntil, err := parseAvailableUntil(*req.AvailableUntil)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+				return
+			}
+			test.AvailableUntil = availableUntil
+		}
+		if req.MaxAttempts != nil && *req.MaxAttempts < 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"max_attempts must not be negative"}})
+			return
+		}
+		if req.TargetStandardError != nil && *req.TargetStandardError < 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"target_standard_error must not be negative"}})
+			return
+		}
+
+		subjectID, ok := resolveSubject(db, req.SubjectID)
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{
+				"status":  http.StatusNotFound,
+				"message": "cannot find Subject",
+			})
+			return
+		}
+
 		test.Name = req.Name
 		test.Description = req.Description
 		test.TotalQuestion = req.TotalQuestion
+		test.SubjectID = subjectID
+		if req.Status != "" {
+			test.Status = req.Status
+		}
+		if req.Leaderboard != "" {
+			test.Leaderboard = req.Leaderboard
+		}
+		if req.ReviewPolicy != "" {
+			test.ReviewPolicy = req.ReviewPolicy
+		}
+		if req.MaxAttempts != nil {
+			test.MaxAttempts = *req.MaxAttempts
+		}
+		if req.AllowPractice != nil {
+			test.AllowPractice = *req.AllowPractice
+		}
+		if req.TargetStandardError != nil {
+			test.TargetStandardError = *req.TargetStandardError
+		}
 
 		db.Save(&test)
 
+		//tags are replaced only when they are sent
+		if req.Tags != nil {
+			tags, err := findOrCreateTags(db, req.Tags)
+			if err != nil {
+				glog.Errorf("Failed to save tags: %s", err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			db.Model(&test).Association("Tags").Replace(tags)
+		}
+
 		c.JSON(http.StatusCreated, gin.H{
 			"status":  http.StatusCreated,
 			"message": "success update test",
@@ -366,10 +596,209 @@ func (ctrl *Controller) UpdateQuestion(c *gin.Context) {
 
 	uid, err := uuid.FromString(req.QuestionID)
 	if err := db.Where("id = ?", uid).First(&question).Error; err == nil {
+		var choices []dataModel.QuestionChoice
+		db.Where("question_id = ?", uid).Find(&choices)
+
+		//answer keys must point to the question choices, text entry questions keep their accepted answer
+		correct := make(map[int]bool)
+		if question.HasAnswerKeys() {
+			keys := answerKeys(req.AnswerKey, req.AnswerKeys)
+			if question.Type == dataModel.QuestionTypeSingleChoice && len(keys) != 1 {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"status":  http.StatusBadRequest,
+					"message": "single choice question has exactly one answer_key",
+				})
+				return
+			}
+			if len(keys) == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"status":  http.StatusBadRequest,
+					"message": "answer_keys is required",
+				})
+				return
+			}
+
+			for _, key := range keys {
+				found := false
+				for _, v := range choices {
+					if v.Key == key {
+						found = true
+					}
+				}
+				if !found {
+					c.JSON(http.StatusBadRequest, gin.H{
+						"status":  http.StatusBadRequest,
+						"message": fmt.Sprintf("answer_key %d does not match any choice", key),
+					})
+					return
+				}
+				correct[key] = true
+			}
+		} else if question.Type == dataModel.QuestionTypeCalculated {
+			//the template is checked with the text below, variables, expression and tolerance are kept when not sent
+			if req.Variables != nil {
+				question.Variables = encodeVariables(req.Variables)
+			}
+			if req.AnswerExpression != nil {
+				question.AnswerExpression = *req.AnswerExpression
+			}
+			if req.AnswerTolerance != nil {
+				if *req.AnswerTolerance < 0 {
+					c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"answer_tolerance must not be negative"}})
+					return
+				}
+				question.AnswerTolerance = *req.AnswerTolerance
+			}
+		} else if question.HasChoices() {
+			//the choices of matching and ordering questions are their answer, partial credit is kept when not sent
+			if req.PartialCredit != nil {
+				question.PartialCredit = *req.PartialCredit
+			}
+			if req.ItemPoint != nil {
+				if *req.ItemPoint < 0 {
+					c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"item_point must not be negative"}})
+					return
+				}
+				question.ItemPoint = itemPoint(*req.ItemPoint)
+			}
+		} else if question.Type == dataModel.QuestionTypeProgramming {
+			//language and limits are kept when not sent, test cases are replaced when they are sent
+			if req.Language != nil {
+				question.Language = *req.Language
+			}
+			if req.TimeLimit != nil {
+				question.TimeLimit = *req.TimeLimit
+			}
+			if req.MemoryLimit != nil {
+				question.MemoryLimit = *req.MemoryLimit
+			}
+			testCases := req.TestCases
+			if testCases == nil {
+				//only the count of the saved test cases matters to the validation
+				var count int
+				db.Model(&dataModel.TestCase{}).Where("question_id = ?", question.ID).Count(&count)
+				testCases = make([]testCaseRequest, count)
+			}
+			if errors := validateProgramming(question.Language, question.TimeLimit, question.MemoryLimit, testCases); len(errors) > 0 {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": errors})
+				return
+			}
+		} else if question.Type == dataModel.QuestionTypeCloze {
+			//blanks are replaced when they are sent, they are checked against the markers of the text below
+			if req.Blanks != nil {
+				question.Blanks = encodeBlanks(req.Blanks)
+			}
+		} else if strings.TrimSpace(req.Answer) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "answer is required",
+			})
+			return
+		}
+
+		if req.Difficulty != "" && !dataModel.IsValidQuestionDifficulty(req.Difficulty) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"difficulty must be easy, medium or hard"}})
+			return
+		}
+		if req.Format != "" && !dataModel.IsValidQuestionFormat(req.Format) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"format must be plain, markdown or markdown_math"}})
+			return
+		}
+
+		//the choices are validated again since they are written in the format of the question
+		updated := dataModel.Question{Format: question.Format}
+		if req.Format != "" {
+			updated.Format = req.Format
+		}
+		explanation := question.Explanation
+		if req.Explanation != nil {
+			explanation = *req.Explanation
+		}
+		var errors []string
+		errors = append(errors, validateContent("question", req.Question, updated.Format)...)
+		errors = append(errors, validateContent("answer", req.Answer, dataModel.QuestionFormatPlain)...)
+		errors = append(errors, validateContent("explanation", explanation, updated.ExplanationFormat())...)
+		for _, v := range choices {
+			errors = append(errors, validateContent(fmt.Sprintf("choice %d", v.Key), v.Choice, updated.Format)...)
+			errors = append(errors, validateContent(fmt.Sprintf("feedback of choice %d", v.Key), v.Feedback, updated.ExplanationFormat())...)
+			errors = append(errors, validateContent(fmt.Sprintf("match of choice %d", v.Key), v.Match, updated.Format)...)
+		}
+		if question.Type == dataModel.QuestionTypeCalculated {
+			errors = append(errors, validateTemplate(req.Question, explanation, questionVariables(question), question.AnswerExpression)...)
+		}
+		if question.Type == dataModel.QuestionTypeCloze {
+			errors = append(errors, validateCloze(req.Question, question.ClozeBlanks())...)
+			errors = append(errors, validateBlankContent(req.Blanks)...)
+		}
+		if len(errors) > 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": errors})
+			return
+		}
+
+		topicID, ok := resolveTopic(db, req.TopicID)
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{
+				"status":  http.StatusNotFound,
+				"message": "cannot find Topic",
+			})
+			return
+		}
+
 		question.Question = req.Question
-		question.Answer = req.Answer
+		question.Format = updated.Format
+		question.Answer = ""
+		if question.Type == dataModel.QuestionTypeTextEntry || question.Type == dataModel.QuestionTypeProgramming {
+			question.Answer = req.Answer
+		}
+		question.TopicID = topicID
+		//the section is kept when it is not sent, an empty section_id takes the question out of its section
+		if req.SectionID != nil {
+			sectionID, ok := resolveSection(db, question.TestID, *req.SectionID)
+			if !ok {
+				c.JSON(http.StatusOK, gin.H{
+					"status":  http.StatusNotFound,
+					"message": "cannot find Section",
+				})
+				return
+			}
+			question.SectionID = sectionID
+		}
+		if req.Difficulty != "" {
+			question.Difficulty = req.Difficulty
+		}
+		if req.Explanation != nil {
+			question.Explanation = *req.Explanation
+		}
 
-		db.Save(&question)
+		tx := db.Begin()
+		tx.Save(&question)
+		for _, v := range choices {
+			tx.Model(&v).Update("is_correct", correct[v.Key])
+		}
+		//tags are replaced only when they are sent
+		if req.Tags != nil {
+			tags, err := findOrCreateTags(tx, req.Tags)
+			if err != nil {
+				tx.Rollback()
+				glog.Errorf("Failed to save tags: %s", err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			tx.Model(&question).Association("Tags").Replace(tags)
+		}
+		if question.Type == dataModel.QuestionTypeProgramming && req.TestCases != nil {
+			if err := saveTestCases(tx, question.ID, req.TestCases); err != nil {
+				tx.Rollback()
+				glog.Errorf("Failed to save test cases: %s", err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := tx.Commit().Error; err != nil {
+			glog.Errorf("Failed to update question: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
 
 		c.JSON(http.StatusCreated, gin.H{
 			"status":  http.StatusCreated,
@@ -416,6 +845,23 @@ func (ctrl *Controller) UpdateChoice(c *gin.Context) {
 	uid, err := uuid.FromString(req.ChoiceID)
 	if err := db.Where("id = ?", uid).First(&questionChoice).Error; err == nil {
 		questionChoice.Choice = req.Choice
+		if req.Feedback != nil {
+			questionChoice.Feedback = *req.Feedback
+		}
+		if req.Match != nil {
+			questionChoice.Match = *req.Match
+		}
+
+		var question dataModel.Question
+		db.Where("id = ?", questionChoice.QuestionID).First(&question)
+		errors := validateChoiceItem(question.Type, questionChoice.Choice, questionChoice.Match)
+		errors = append(errors, validateContent("match", questionChoice.Match, question.Format)...)
+		errors = append(errors, validateContent("choice", questionChoice.Choice, question.Format)...)
+		errors = append(errors, validateContent("feedback", questionChoice.Feedback, question.ExplanationFormat())...)
+		if len(errors) > 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": errors})
+			return
+		}
 
 		db.Save(&questionChoice)
 
@@ -474,6 +920,7 @@ func (ctrl *Controller) DeleteTest(c *gin.Context) {
 
 			db.Delete(&questions).Where("test_id =?", uid)
 		}
+		db.Where("test_id = ?", uid).Delete(&dataModel.Section{})
 
 		c.JSON(http.StatusOK, gin.H{
 			"status":  http.StatusOK,
@@ -522,6 +969,7 @@ func (ctrl *Controller) DeleteQuestion(c *gin.Context) {
 	if err := db.Where("id =?", uid).Find(&question).Error; err == nil {
 		db.Delete(&question).Where("id =?", uid)
 		db.Where("question_id =?", uid).Delete(&questionChoice)
+		db.Where("question_id =?", uid).Delete(&dataModel.TestCase{})
 
 		c.JSON(http.StatusOK, gin.H{
 			"status":  http.StatusOK,
@@ -569,10 +1017,59 @@ func (ctrl *Controller) DeleteChoice(c *gin.Context) {
 
 	if err := db.Where("question_id =?", uid).Find(&questionChoices).Error; err == nil {
 
+		var choice dataModel.QuestionChoice
+		for _, v := range questionChoices {
+			if v.ID == uid2 {
+				choice = v
+			}
+		}
+		if choice.ID == uuid.Nil {
+			c.JSON(http.StatusOK, gin.H{
+				"status":  http.StatusNotFound,
+				"message": "cannot find Choice",
+			})
+			return
+		}
+
+		//the correct choice is the answer key, it cannot be removed
+		if choice.IsCorrect {
+			c.JSON(http.StatusOK, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "cannot delete the correct choice. change the answer key first and then delete it",
+			})
+			return
+		}
+
 		totalChoice := len(questionChoices)
 
-		if totalChoice > 2 {
-			db.Where("id =?", uid2).Delete(&questionChoices)
+		if totalChoice > minChoice {
+			var remaining []dataModel.QuestionChoice
+			for _, v := range questionChoices {
+				if v.ID != choice.ID {
+					remaining = append(remaining, v)
+				}
+			}
+			sortChoices(remaining)
+
+			//keep the keys of the remaining choices continuous
+			tx := db.Begin()
+			if err := tx.Delete(&choice).Error; err != nil {
+				tx.Rollback()
+				glog.Errorf("Failed to delete choice: %s", err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			if err := renumberChoices(tx, remaining); err != nil {
+				tx.Rollback()
+				glog.Errorf("Failed to renumber choices: %s", err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			if err := tx.Commit().Error; err != nil {
+				glog.Errorf("Failed to commit choices: %s", err)
+				c.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
 
 			c.JSON(http.StatusOK, gin.H{
 				"status":  http.StatusOK,
@@ -583,7 +1080,7 @@ func (ctrl *Controller) DeleteChoice(c *gin.Context) {
 
 		c.JSON(http.StatusOK, gin.H{
 			"status":  http.StatusOK,
-			"message": "minimum total choices is 2. add choice first and then delete one",
+			"message": fmt.Sprintf("minimum total choices is %d. add choice first and then delete one", minChoice),
 		})
 
 		return
@@ -595,3 +1092,17 @@ func (ctrl *Controller) DeleteChoice(c *gin.Context) {
 	})
 	return
 }
+
+// parseAvailableUntil parse the available_until of a test request, empty means the test is always available
+func parseAvailableUntil(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.New("available_until must be formatted as RFC 3339, e.g. 2019-01-31T17:00:00+07:00")
+	}
+
+	return &t, nil
+}
@@ -0,0 +1,142 @@
+package admin
+
+import (
+	"net/http"
+	"okkybudiman/analytics"
+	dataModel "okkybudiman/data/model"
+	u "okkybudiman/utility"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	uuid "github.com/satori/go.uuid"
+)
+
+// questions with less responses than defaultMinResponses are not calibrated unless min_responses is set
+const defaultMinResponses = 30
+
+// CalibrateIRT estimate the item response theory parameters of the questions of a test, or of a topic, from the
+// answers of the users with the 1PL or 2PL model. Right answers are correct, wrong answers are incorrect and
+// questions which were not answered are left out. The parameters of the questions without enough responses are removed.
+func (ctrl *Controller) CalibrateIRT(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var req calibrateRequest
+	if !u.BindJSON(c, &req) {
+		return
+	}
+
+	model := req.Model
+	if model == "" {
+		model = analytics.Model2PL
+	}
+	if model != analytics.Model1PL && model != analytics.Model2PL {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"model must be 1pl or 2pl"}})
+		return
+	}
+	minResponses := req.MinResponses
+	if minResponses <= 0 {
+		minResponses = defaultMinResponses
+	}
+
+	var questions []dataModel.Question
+	query := db.Order("created_at")
+	if req.TestID != "" {
+		query = query.Where("test_id = ?", req.TestID)
+	} else if req.TopicID != "" {
+		query = query.Where("topic_id = ?", req.TopicID)
+	} else {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"test_id or topic_id is required"}})
+		return
+	}
+	if err := query.Find(&questions).Error; err != nil {
+		glog.Errorf("Failed to load questions: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if len(questions) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Question",
+		})
+		return
+	}
+
+	itemIndex := make(map[uuid.UUID]int)
+	var questionIDs []uuid.UUID
+	for k, v := range questions {
+		itemIndex[v.ID] = k
+		questionIDs = append(questionIDs, v.ID)
+	}
+
+	var answers []dataModel.UserAnswer
+	if err := db.Where("question_id IN (?) AND point <> 0", questionIDs).Order("created_at").Find(&answers).Error; err != nil {
+		glog.Errorf("Failed to load answers: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	//one row per user, the last answer of a question counts
+	var responses [][]int
+	rows := make(map[uuid.UUID]int)
+	for _, v := range answers {
+		row, ok := rows[v.UserID]
+		if !ok {
+			row = len(responses)
+			rows[v.UserID] = row
+			responses = append(responses, make([]int, len(questions)))
+			for k := range responses[row] {
+				responses[row][k] = analytics.ResponseMissing
+			}
+		}
+		responses[row][itemIndex[v.QuestionID]] = analytics.ResponseIncorrect
+		if v.Point > 0 {
+			responses[row][itemIndex[v.QuestionID]] = analytics.ResponseCorrect
+		}
+	}
+
+	params := analytics.Calibrate(responses, len(questions), model)
+
+	var results []calibrationResponse
+	tx := db.Begin()
+	for k, v := range questions {
+		res := calibrationResponse{ID: v.ID, Question: v.Question, Responses: params[k].Responses}
+		if params[k].Calibrated && params[k].Responses >= minResponses {
+			discrimination, difficulty := params[k].Discrimination, params[k].Difficulty
+			res.Calibrated = true
+			res.Discrimination = &discrimination
+			res.Difficulty = &difficulty
+		}
+
+		err := tx.Model(&v).Updates(map[string]interface{}{
+			"irt_discrimination": res.Discrimination,
+			"irt_difficulty":     res.Difficulty,
+		}).Error
+		if err != nil {
+			tx.Rollback()
+			glog.Errorf("Failed to save item parameters: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		results = append(results, res)
+	}
+	if err := tx.Commit().Error; err != nil {
+		glog.Errorf("Failed to commit item parameters: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":          http.StatusOK,
+		"message":         "success calibrate questions",
+		"model":           model,
+		"total_responder": len(responses),
+		"data":            results,
+	})
+	return
+}
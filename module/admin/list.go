@@ -0,0 +1,274 @@
+package admin
+
+import (
+	"net/http"
+	dataModel "okkybudiman/data/model"
+	u "okkybudiman/utility"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+)
+
+// sort values accepted by the list endpoints and their column
+var (
+	testSortColumns = map[string]string{
+		"name":           "name",
+		"total_question": "total_question",
+		"status":         "status",
+		"created_at":     "created_at",
+	}
+	participantSortColumns = map[string]string{
+		"start_test": "start_test",
+		"end_test":   "end_test",
+		"created_at": "created_at",
+	}
+	userSortColumns = map[string]string{
+		"name":       "name",
+		"email":      "email",
+		"created_at": "created_at",
+	}
+	questionSortColumns = map[string]string{
+		"difficulty": "difficulty",
+		"created_at": "created_at",
+	}
+	resultSortColumns = map[string]string{
+		"name":       "users.name",
+		"score":      "user_scores.score",
+		"created_at": "user_scores.created_at",
+	}
+)
+
+// GetListUser list users, filtered by role and searched by name or email
+func (ctrl *Controller) GetListUser(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var users []dataModel.User
+	var responses []userResponse
+	var total int
+
+	pagination, err := u.NewPagination(c, userSortColumns, "name")
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	query := db.Model(&dataModel.User{})
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role_id IN (?)", db.Table("roles").Select("id").Where("name = ?", role).QueryExpr())
+	}
+	if pagination.Search != "" {
+		query = pagination.SearchWhere(query, "name", "email")
+	}
+	query.Count(&total)
+
+	if err := pagination.Apply(query).Preload("Role").Find(&users).Error; err != nil {
+		glog.Errorf("Failed to get list user: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	for _, v := range users {
+		responses = append(responses, userResponse{
+			ID:    v.ID,
+			Name:  v.Name,
+			Email: v.Email,
+			Role:  v.Role.Name,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     http.StatusOK,
+		"message":    "success get list user",
+		"data":       responses,
+		"total":      total,
+		"pagination": pagination.Meta(total),
+	})
+	return
+}
+
+// GetListResult list scores of the users, filtered by test or user and searched by user name
+func (ctrl *Controller) GetListResult(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var scores []dataModel.UserScore
+	var responses []resultResponse
+	var total int
+
+	pagination, err := u.NewPagination(c, resultSortColumns, "created_at")
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	query := db.Model(&dataModel.UserScore{}).Joins("JOIN users ON users.id = user_scores.user_id")
+	if testID := c.Query("test_id"); testID != "" {
+		query = query.Where("user_scores.test_id = ?", testID)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_scores.user_id = ?", userID)
+	}
+	if pagination.Search != "" {
+		query = pagination.SearchWhere(query, "users.name")
+	}
+	query.Count(&total)
+
+	if err := pagination.Apply(query).Select("user_scores.*").Preload("User").Find(&scores).Error; err != nil {
+		glog.Errorf("Failed to get list result: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	for _, v := range scores {
+		responses = append(responses, resultResponse{
+			ID:                 v.ID,
+			TestID:             v.TestID,
+			UserID:             v.UserID,
+			Name:               v.User.Name,
+			TotalRightAnswered: v.TotalRightAnswered,
+			TotalWrongAnswered: v.TotalWrongAnswered,
+			TotalNotAnswered:   v.TotalNotAnswered,
+			Score:              v.Score,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     http.StatusOK,
+		"message":    "success get list result",
+		"data":       responses,
+		"total":      total,
+		"pagination": pagination.Meta(total),
+	})
+	return
+}
+
+// GetListQuestion list questions of the bank, filtered by test, subject, topic, difficulty and tag
+func (ctrl *Controller) GetListQuestion(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var questions []dataModel.Question
+	var responses []questionResponse
+	var total int
+
+	pagination, err := u.NewPagination(c, questionSortColumns, "created_at")
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	query := db.Model(&dataModel.Question{})
+	if testID := c.Query("test_id"); testID != "" {
+		query = query.Where("test_id = ?", testID)
+	}
+	if topicID := c.Query("topic_id"); topicID != "" {
+		query = query.Where("topic_id = ?", topicID)
+	}
+	if subjectID := c.Query("subject_id"); subjectID != "" {
+		query = query.Where("topic_id IN (?)", db.Table("topics").Select("id").Where("subject_id = ?", subjectID).QueryExpr())
+	}
+	if difficulty := c.Query("difficulty"); difficulty != "" {
+		query = query.Where("difficulty = ?", difficulty)
+	}
+	if tag := c.Query("tag"); tag != "" {
+		query = query.Where("id IN (?)", db.Table("question_tags").Select("question_tags.question_id").
+			Joins("JOIN tags ON tags.id = question_tags.tag_id").Where("tags.name = ?", normalizeTag(tag)).QueryExpr())
+	}
+	if pagination.Search != "" {
+		query = pagination.SearchWhere(query, "question")
+	}
+	query.Count(&total)
+
+	if err := pagination.Apply(query).Preload("Tags").Preload("Attachments").Preload("QuestionChoices.Attachments").Preload("TestCases").Find(&questions).Error; err != nil {
+		glog.Errorf("Failed to get list question: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	for _, v := range questions {
+		testID := v.TestID
+		res := newQuestionResponse(v)
+		res.TestID = &testID
+		responses = append(responses, res)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     http.StatusOK,
+		"message":    "success get list question",
+		"data":       responses,
+		"total":      total,
+		"pagination": pagination.Meta(total),
+	})
+	return
+}
+
+// newQuestionResponse build the response of a question with its tags and choices loaded
+func newQuestionResponse(q dataModel.Question) questionResponse {
+	res := questionResponse{
+		ID:              q.ID,
+		Question:        q.Question,
+		QuestionHTML:    u.RenderContent(q.Question, q.Format),
+		Type:            q.Type,
+		Format:          q.Format,
+		TopicID:         q.TopicID,
+		SectionID:       q.SectionID,
+		Difficulty:      q.Difficulty,
+		Explanation:     q.Explanation,
+		ExplanationHTML: u.RenderContent(q.Explanation, q.ExplanationFormat()),
+		Tags:            tagNames(q.Tags),
+
+		IrtDiscrimination: q.IrtDiscrimination,
+		IrtDifficulty:     q.IrtDifficulty,
+	}
+	if !q.HasChoices() {
+		res.Answer = q.Answer
+	}
+	if q.Type == dataModel.QuestionTypeCalculated {
+		res.Variables = questionVariables(q)
+		res.AnswerExpression = q.AnswerExpression
+		res.AnswerTolerance = q.AnswerTolerance
+	}
+	if q.Type == dataModel.QuestionTypeProgramming {
+		res.Language = q.Language
+		res.TimeLimit = q.TimeLimit
+		res.MemoryLimit = q.MemoryLimit
+		res.TestCases = testCaseResponses(q.TestCases, false)
+	}
+	if q.Type == dataModel.QuestionTypeMatching || q.Type == dataModel.QuestionTypeOrdering {
+		res.PartialCredit = q.PartialCredit
+		res.ItemPoint = q.ItemPoint
+	}
+	if q.Type == dataModel.QuestionTypeCloze {
+		res.Blanks = q.ClozeBlanks()
+	}
+
+	sortChoices(q.QuestionChoices)
+	res.Choices = choiceResponses(q, q.QuestionChoices)
+	res.Attachments = attachmentResponses(q.Attachments)
+	for _, choice := range q.QuestionChoices {
+		if choice.IsCorrect {
+			res.AnswerKeys = append(res.AnswerKeys, choice.Key)
+		}
+	}
+	if len(res.AnswerKeys) > 0 {
+		res.AnswerKey = res.AnswerKeys[0]
+	}
+
+	return res
+}
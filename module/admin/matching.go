@@ -0,0 +1,75 @@
+package admin
+
+import (
+	"fmt"
+	dataModel "okkybudiman/data/model"
+	"strings"
+)
+
+// validateArrangement check the choices of a matching or ordering question. The choices of an ordering question are
+// written in the right order, every choice of a matching question has the item it is paired with and the choices
+// with a match but no text are distractors.
+func validateArrangement(q questions) []string {
+	var errors []string
+
+	if len(answerKeys(q.AnswerKey, q.AnswerKeys)) > 0 {
+		errors = append(errors, fmt.Sprintf("%s question has no answer_keys", q.Type))
+	}
+	if q.ItemPoint < 0 {
+		errors = append(errors, "item_point must not be negative")
+	}
+	if len(q.Choices) > maxChoice {
+		errors = append(errors, fmt.Sprintf("maximum total choices is %d", maxChoice))
+	}
+
+	total := 0
+	seen := make(map[string]bool)
+	for k, v := range q.Choices {
+		for _, err := range validateChoiceItem(q.Type, v.Choice, v.Match) {
+			errors = append(errors, fmt.Sprintf("choice %d: %s", k+1, err))
+		}
+
+		choice := strings.TrimSpace(v.Choice)
+		if choice == "" {
+			continue
+		}
+		if seen[choice] {
+			errors = append(errors, fmt.Sprintf("choice %d is duplicated", k+1))
+		}
+		seen[choice] = true
+		total++
+	}
+	if total < minChoice {
+		errors = append(errors, fmt.Sprintf("minimum total choices with a text is %d", minChoice))
+	}
+
+	return errors
+}
+
+// validateChoiceItem check the text and the match of a choice of a question of questionType: only matching questions
+// have matches, and only their distractors have no text
+func validateChoiceItem(questionType string, choice string, match string) []string {
+	var errors []string
+
+	hasChoice := strings.TrimSpace(choice) != ""
+	hasMatch := strings.TrimSpace(match) != ""
+	switch {
+	case questionType == dataModel.QuestionTypeMatching && !hasMatch:
+		errors = append(errors, "match is required")
+	case questionType != dataModel.QuestionTypeMatching && hasMatch:
+		errors = append(errors, "only choices of matching questions have a match")
+	case questionType != dataModel.QuestionTypeMatching && !hasChoice:
+		errors = append(errors, "choice is required")
+	}
+
+	return errors
+}
+
+// itemPoint the point of every right pair or position, 1 when it is not set
+func itemPoint(point int) int {
+	if point == 0 {
+		return 1
+	}
+
+	return point
+}
@@ -0,0 +1,170 @@
+package admin
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"okkybudiman/exchange"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	uuid "github.com/satori/go.uuid"
+)
+
+// ImportMoodle import the questions of a Moodle XML or GIFT file into a test, or into the question bank.
+// Without topic_id the moodle categories are mapped to subjects and topics, missing ones are created.
+// Questions which cannot be converted are reported and skipped, with dry_run nothing is saved.
+func (ctrl *Controller) ImportMoodle(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"file is required"}})
+		return
+	}
+
+	format := strings.ToLower(c.PostForm("format"))
+	if format == "" {
+		format = exchange.FormatFromName(file.Filename)
+	}
+	if format != exchange.FormatMoodle && format != exchange.FormatGIFT {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"format must be moodle or gift"}})
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	items, skipped, err := exchange.ReadItems(format, data)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	testID, topicID, available := uuid.Nil, "", len(items)
+	if c.PostForm("test_id") != "" || c.PostForm("topic_id") != "" {
+		var ok bool
+		testID, topicID, available, ok = importTarget(c, db, len(items))
+		if !ok {
+			return
+		}
+	}
+
+	reqs := itemsToQuestions(items, topicID)
+	results, valid := validateQuestions(db, testID, reqs, available)
+	for i := range results {
+		results[i].Row = items[i].Row
+		if testID == uuid.Nil && reqs[i].TopicID == "" && items[i].Category == "" {
+			results[i].Errors = append(results[i].Errors, "category or topic_id is required")
+			valid = false
+		}
+	}
+
+	if c.PostForm("dry_run") == "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusOK,
+			"message": "dry run, nothing is saved",
+			"valid":   valid,
+			"data":    results,
+			"skipped": skipped,
+		})
+		return
+	}
+
+	if !valid || len(reqs) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "invalid questions, nothing is saved",
+			"data":    results,
+			"skipped": skipped,
+		})
+		return
+	}
+
+	tx := db.Begin()
+	if err := applyCategories(tx, items, reqs); err != nil {
+		tx.Rollback()
+		glog.Errorf("Failed to save categories: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if err := saveQuestions(tx, testID, reqs, results); err != nil {
+		tx.Rollback()
+		glog.Errorf("Failed to import questions: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		glog.Errorf("Failed to commit questions: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  http.StatusCreated,
+		"message": "success import question",
+		"data":    results,
+		"skipped": skipped,
+	})
+	return
+}
+
+// ExportMoodle export the questions of a test, or of a topic, as a Moodle XML or GIFT file with their categories
+func (ctrl *Controller) ExportMoodle(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	format := strings.ToLower(c.DefaultQuery("format", exchange.FormatMoodle))
+	if format != exchange.FormatMoodle && format != exchange.FormatGIFT {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"format must be moodle or gift"}})
+		return
+	}
+
+	items, ok := exportItems(c, db)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	skipped, err := exchange.WriteItems(&buf, format, "", items)
+	if err != nil {
+		glog.Errorf("Failed to export questions: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	for _, v := range skipped {
+		glog.Warningf("Question %d cannot be exported to %s: %s", v.Index, format, strings.Join(v.Errors, ", "))
+	}
+
+	c.Header("X-Skipped-Items", fmt.Sprintf("%d", len(skipped)))
+	if format == exchange.FormatGIFT {
+		c.Header("Content-Disposition", "attachment; filename=questions.gift")
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", buf.Bytes())
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename=questions.xml")
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", buf.Bytes())
+}
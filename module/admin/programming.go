@@ -0,0 +1,129 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	dataModel "okkybudiman/data/model"
+	"okkybudiman/sandbox"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+// bounds of the test cases and of the limits of a programming question, limits are in milliseconds and megabytes
+const (
+	maxTestCase    = 50
+	maxTimeLimit   = 10000
+	maxMemoryLimit = 1024
+)
+
+// GetTestCases list every test case of a programming question, with the hidden ones
+func (ctrl *Controller) GetTestCases(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var question dataModel.Question
+	questionID, _ := uuid.FromString(c.Param("id"))
+	if err := db.Where("id = ? AND type = ?", questionID, dataModel.QuestionTypeProgramming).Preload("TestCases").First(&question).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Question",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "success get test cases",
+		"data":    testCaseResponses(question.TestCases, true),
+	})
+	return
+}
+
+// validateProgramming check the language, the limits and the test cases of a programming question
+func validateProgramming(language string, timeLimit int, memoryLimit int, testCases []testCaseRequest) []string {
+	var errors []string
+
+	if language != "" && !sandbox.IsSupportedLanguage(language) {
+		errors = append(errors, "language must be go or python")
+	}
+	if timeLimit < 0 || timeLimit > maxTimeLimit {
+		errors = append(errors, fmt.Sprintf("time_limit must be between 0 and %d milliseconds", maxTimeLimit))
+	}
+	if memoryLimit < 0 || memoryLimit > maxMemoryLimit {
+		errors = append(errors, fmt.Sprintf("memory_limit must be between 0 and %d megabytes", maxMemoryLimit))
+	}
+
+	if len(testCases) == 0 {
+		errors = append(errors, "test_cases is required")
+	}
+	if len(testCases) > maxTestCase {
+		errors = append(errors, fmt.Sprintf("maximum total test cases is %d", maxTestCase))
+	}
+	for k, v := range testCases {
+		if v.Point < 0 {
+			errors = append(errors, fmt.Sprintf("point of test case %d must not be negative", k+1))
+		}
+		errors = append(errors, validateContent(fmt.Sprintf("input of test case %d", k+1), v.Input, dataModel.QuestionFormatPlain)...)
+		errors = append(errors, validateContent(fmt.Sprintf("expected_output of test case %d", k+1), v.ExpectedOutput, dataModel.QuestionFormatPlain)...)
+	}
+
+	return errors
+}
+
+// saveTestCases replace the test cases of a question, a test case is worth 1 point when its point is not set
+func saveTestCases(tx *gorm.DB, questionID uuid.UUID, testCases []testCaseRequest) error {
+	if err := tx.Where("question_id = ?", questionID).Delete(&dataModel.TestCase{}).Error; err != nil {
+		return err
+	}
+
+	for k, v := range testCases {
+		point := v.Point
+		if point == 0 {
+			point = 1
+		}
+		testCase := dataModel.TestCase{
+			QuestionID:     questionID,
+			Key:            k + 1,
+			Input:          v.Input,
+			ExpectedOutput: v.ExpectedOutput,
+			Point:          point,
+			Hidden:         v.Hidden,
+		}
+		if err := tx.Create(&testCase).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// testCaseResponses the test cases in the order they are run, hidden ones are only included when hidden is set
+func testCaseResponses(testCases []dataModel.TestCase, hidden bool) []testCaseResponse {
+	sort.Slice(testCases, func(i, j int) bool { return testCases[i].Key < testCases[j].Key })
+
+	var responses []testCaseResponse
+	for _, v := range testCases {
+		if v.Hidden && !hidden {
+			continue
+		}
+		responses = append(responses, testCaseResponse{
+			ID:             v.ID,
+			Key:            v.Key,
+			Input:          v.Input,
+			ExpectedOutput: v.ExpectedOutput,
+			Point:          v.Point,
+			Hidden:         v.Hidden,
+		})
+	}
+
+	return responses
+}
@@ -0,0 +1,194 @@
+package admin
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	dataModel "okkybudiman/data/model"
+	"okkybudiman/exchange"
+	"strings"
+
+	jwt "github.com/appleboy/gin-jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	uuid "github.com/satori/go.uuid"
+)
+
+// ImportQTI import the items of an IMS QTI 2.1 content package into a test, or into the question bank of a topic.
+// Without test_id and topic_id a draft test named after the assessment test of the package is created.
+// Items which cannot be converted are reported and skipped, with dry_run nothing is saved.
+func (ctrl *Controller) ImportQTI(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"file is required"}})
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	title, items, skipped, err := exchange.ReadQTI(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	var test *dataModel.Test
+	testID, topicID, available := uuid.Nil, "", len(items)
+	if c.PostForm("test_id") != "" || c.PostForm("topic_id") != "" {
+		var ok bool
+		testID, topicID, available, ok = importTarget(c, db, len(items))
+		if !ok {
+			return
+		}
+	} else {
+		if title == "" {
+			title = strings.TrimSuffix(file.Filename, ".zip")
+		}
+		var count int
+		db.Model(&dataModel.Test{}).Where("name = ?", title).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusOK, gin.H{
+				"status":  http.StatusOK,
+				"message": "test name already exist",
+			})
+			return
+		}
+		test = &dataModel.Test{
+			Name:          title,
+			TotalQuestion: len(items),
+			Status:        dataModel.TestStatusDraft,
+		}
+	}
+
+	reqs := itemsToQuestions(items, topicID)
+	results, valid := validateQuestions(db, testID, reqs, available)
+	for i := range results {
+		results[i].Row = items[i].Row
+	}
+
+	if c.PostForm("dry_run") == "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusOK,
+			"message": "dry run, nothing is saved",
+			"valid":   valid,
+			"data":    results,
+			"skipped": skipped,
+		})
+		return
+	}
+
+	if !valid || len(reqs) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "invalid questions, nothing is saved",
+			"data":    results,
+			"skipped": skipped,
+		})
+		return
+	}
+
+	tx := db.Begin()
+	if test != nil {
+		claims := jwt.ExtractClaims(c)
+		var user dataModel.User
+		tx.Where("name = ?", claims["id"]).Find(&user)
+		creatorID := user.ID
+		test.CreatorID = &creatorID
+
+		if err := tx.Create(test).Error; err != nil {
+			tx.Rollback()
+			glog.Errorf("Failed to create test: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		testID = test.ID
+	}
+	if err := saveQuestions(tx, testID, reqs, results); err != nil {
+		tx.Rollback()
+		glog.Errorf("Failed to import questions: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		glog.Errorf("Failed to commit questions: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	res := gin.H{
+		"status":  http.StatusCreated,
+		"message": "success import qti",
+		"data":    results,
+		"skipped": skipped,
+	}
+	if test != nil {
+		res["test_id"] = test.ID
+	}
+	c.JSON(http.StatusCreated, res)
+	return
+}
+
+// ExportQTI export the questions of a test, or of a topic, as an IMS QTI 2.1 content package.
+// The number of questions which cannot be converted is sent in the X-Skipped-Items header.
+func (ctrl *Controller) ExportQTI(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	items, ok := exportItems(c, db)
+	if !ok {
+		return
+	}
+
+	title := "questions"
+	if testID := c.Query("test_id"); testID != "" {
+		var test dataModel.Test
+		if err := db.Where("id = ?", testID).First(&test).Error; err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"status":  http.StatusNotFound,
+				"message": "cannot find Test",
+			})
+			return
+		}
+		title = test.Name
+	}
+
+	var buf bytes.Buffer
+	skipped, err := exchange.WriteQTI(&buf, title, items)
+	if err != nil {
+		glog.Errorf("Failed to export qti: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	for _, v := range skipped {
+		glog.Warningf("Question %d cannot be exported to qti: %s", v.Index, strings.Join(v.Errors, ", "))
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=qti.zip")
+	c.Header("X-Skipped-Items", fmt.Sprintf("%d", len(skipped)))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
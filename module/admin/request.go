@@ -1,9 +1,23 @@
 package admin
 
+import (
+	dataModel "okkybudiman/data/model"
+	"okkybudiman/formula"
+)
+
 type testRequest struct {
-	Name          string `json:"name" binding:"required"`
-	Description   string `json:"description" binding:"required"`
-	TotalQuestion int    `json:"total_question" binding:"required"`
+	Name                string   `json:"name" binding:"required"`
+	Description         string   `json:"description" binding:"required"`
+	TotalQuestion       int      `json:"total_question" binding:"required"`
+	Status              string   `json:"status"`
+	SubjectID           string   `json:"subject_id"`
+	Tags                []string `json:"tags"`
+	Leaderboard         string   `json:"leaderboard"`
+	ReviewPolicy        string   `json:"review_policy"`
+	AvailableUntil      string   `json:"available_until"`
+	MaxAttempts         int      `json:"max_attempts"`
+	AllowPractice       bool     `json:"allow_practice"`
+	TargetStandardError float64  `json:"target_standard_error"`
 }
 
 type questionRequest struct {
@@ -11,32 +25,158 @@ type questionRequest struct {
 	Questions []questions
 }
 
+type bulkQuestionRequest struct {
+	TestID    string      `json:"test_id" binding:"required"`
+	Replace   bool        `json:"replace"`
+	Questions []questions `json:"questions" binding:"required"`
+}
+
 type questions struct {
-	Question string `json:"question" binding:"required"`
-	Answer   string `json:"answer" binding:"required"`
-	Choices  []choices
+	Question    string   `json:"question" binding:"required"`
+	Type        string   `json:"type"`
+	Format      string   `json:"format"`
+	AnswerKey   int      `json:"answer_key"`
+	AnswerKeys  []int    `json:"answer_keys"`
+	Answer      string   `json:"answer"`
+	TopicID     string   `json:"topic_id"`
+	SectionID   string   `json:"section_id"`
+	Difficulty  string   `json:"difficulty"`
+	Explanation string   `json:"explanation"`
+	Tags        []string `json:"tags"`
+	Choices     []choices
+
+	Variables        []formula.Variable `json:"variables"`
+	AnswerExpression string             `json:"answer_expression"`
+	AnswerTolerance  float64            `json:"answer_tolerance"`
+
+	Language    string            `json:"language"`
+	TimeLimit   int               `json:"time_limit"`
+	MemoryLimit int               `json:"memory_limit"`
+	TestCases   []testCaseRequest `json:"test_cases"`
+
+	PartialCredit bool `json:"partial_credit"`
+	ItemPoint     int  `json:"item_point"`
+
+	Blanks []dataModel.ClozeBlank `json:"blanks"`
+}
+
+type testCaseRequest struct {
+	Input          string `json:"input"`
+	ExpectedOutput string `json:"expected_output"`
+	Point          int    `json:"point"`
+	Hidden         bool   `json:"hidden"`
 }
 
 type choices struct {
-	Choice string `json:"choice" binding:"required"`
+	Choice   string `json:"choice"`
+	Feedback string `json:"feedback"`
+	//item the choice is paired with in a matching question
+	Match string `json:"match"`
 }
 
 type updateTestRequest struct {
-	TestID        string `json:"test_id" binding:"required"`
-	Name          string `json:"name" binding:"required"`
-	Description   string `json:"description" binding:"required"`
-	TotalQuestion int    `json:"total_question" binding:"required"`
+	TestID              string   `json:"test_id" binding:"required"`
+	Name                string   `json:"name" binding:"required"`
+	Description         string   `json:"description" binding:"required"`
+	TotalQuestion       int      `json:"total_question" binding:"required"`
+	Status              string   `json:"status"`
+	SubjectID           string   `json:"subject_id"`
+	Tags                []string `json:"tags"`
+	Leaderboard         string   `json:"leaderboard"`
+	ReviewPolicy        string   `json:"review_policy"`
+	AvailableUntil      *string  `json:"available_until"`
+	MaxAttempts         *int     `json:"max_attempts"`
+	AllowPractice       *bool    `json:"allow_practice"`
+	TargetStandardError *float64 `json:"target_standard_error"`
+}
+
+type sectionRequest struct {
+	TestID    string `json:"test_id" binding:"required"`
+	Name      string `json:"name" binding:"required"`
+	Key       int    `json:"key"`
+	TimeLimit int    `json:"time_limit"`
+}
+
+type updateSectionRequest struct {
+	SectionID string `json:"section_id" binding:"required"`
+	Name      string `json:"name" binding:"required"`
+	TimeLimit *int   `json:"time_limit"`
+}
+
+type reorderSectionRequest struct {
+	TestID     string   `json:"test_id" binding:"required"`
+	SectionIDs []string `json:"section_ids" binding:"required"`
+}
+
+type deleteSectionRequest struct {
+	SectionID string `json:"section_id" binding:"required"`
+}
+
+type calibrateRequest struct {
+	TestID       string `json:"test_id"`
+	TopicID      string `json:"topic_id"`
+	Model        string `json:"model"`
+	MinResponses int    `json:"min_responses"`
 }
 
 type updateQuestionRequest struct {
-	QuestionID string `json:"question_id" binding:"required"`
-	Question   string `json:"question" binding:"required"`
-	Answer     string `json:"answer" binding:"required"`
+	QuestionID  string   `json:"question_id" binding:"required"`
+	Question    string   `json:"question" binding:"required"`
+	Format      string   `json:"format"`
+	AnswerKey   int      `json:"answer_key"`
+	AnswerKeys  []int    `json:"answer_keys"`
+	Answer      string   `json:"answer"`
+	TopicID     string   `json:"topic_id"`
+	SectionID   *string  `json:"section_id"`
+	Difficulty  string   `json:"difficulty"`
+	Explanation *string  `json:"explanation"`
+	Tags        []string `json:"tags"`
+
+	Variables        []formula.Variable `json:"variables"`
+	AnswerExpression *string            `json:"answer_expression"`
+	AnswerTolerance  *float64           `json:"answer_tolerance"`
+
+	Language    *string           `json:"language"`
+	TimeLimit   *int              `json:"time_limit"`
+	MemoryLimit *int              `json:"memory_limit"`
+	TestCases   []testCaseRequest `json:"test_cases"`
+
+	PartialCredit *bool `json:"partial_credit"`
+	ItemPoint     *int  `json:"item_point"`
+
+	Blanks []dataModel.ClozeBlank `json:"blanks"`
 }
 
+type templatePreviewRequest struct {
+	Question         string             `json:"question" binding:"required"`
+	Format           string             `json:"format"`
+	Explanation      string             `json:"explanation"`
+	Variables        []formula.Variable `json:"variables"`
+	AnswerExpression string             `json:"answer_expression" binding:"required"`
+	Samples          int                `json:"samples"`
+	Seed             int64              `json:"seed"`
+}
+
+// the text of a choice is required, except for the distractors of matching questions which only have a match
 type updateQuestionChoiceRequest struct {
-	ChoiceID string `json:"choice_id" binding:"required"`
-	Choice   string `json:"choice" binding:"required"`
+	ChoiceID string  `json:"choice_id" binding:"required"`
+	Choice   string  `json:"choice"`
+	Feedback *string `json:"feedback"`
+	Match    *string `json:"match"`
+}
+
+type createChoiceRequest struct {
+	QuestionID string `json:"question_id" binding:"required"`
+	Choice     string `json:"choice"`
+	Key        int    `json:"key"`
+	IsCorrect  bool   `json:"is_correct"`
+	Feedback   string `json:"feedback"`
+	Match      string `json:"match"`
+}
+
+type reorderChoiceRequest struct {
+	QuestionID string   `json:"question_id" binding:"required"`
+	ChoiceIDs  []string `json:"choice_ids" binding:"required"`
 }
 
 type deleteTestRequest struct {
@@ -51,3 +191,71 @@ type deleteChoiceRequest struct {
 	QuestionID string `json:"question_id" binding:"required"`
 	ChoiceID   string `json:"choice_id" binding:"required"`
 }
+
+type subjectRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+type updateSubjectRequest struct {
+	SubjectID   string `json:"subject_id" binding:"required"`
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+type deleteSubjectRequest struct {
+	SubjectID string `json:"subject_id" binding:"required"`
+}
+
+type topicRequest struct {
+	SubjectID   string `json:"subject_id" binding:"required"`
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+type updateTopicRequest struct {
+	TopicID     string `json:"topic_id" binding:"required"`
+	SubjectID   string `json:"subject_id" binding:"required"`
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+type deleteTopicRequest struct {
+	TopicID string `json:"topic_id" binding:"required"`
+}
+
+type tagRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type updateTagRequest struct {
+	TagID string `json:"tag_id" binding:"required"`
+	Name  string `json:"name" binding:"required"`
+}
+
+type deleteTagRequest struct {
+	TagID string `json:"tag_id" binding:"required"`
+}
+
+type assignTestRequest struct {
+	TestID  string   `json:"test_id" binding:"required"`
+	UserIDs []string `json:"user_ids" binding:"required"`
+	Cohort  string   `json:"cohort"`
+}
+
+type unassignTestRequest struct {
+	TestID  string   `json:"test_id" binding:"required"`
+	UserIDs []string `json:"user_ids" binding:"required"`
+}
+
+// answerKeys get the keys of the correct choices, answer_keys or the single answer_key
+func answerKeys(answerKey int, keys []int) []int {
+	if len(keys) > 0 {
+		return keys
+	}
+	if answerKey != 0 {
+		return []int{answerKey}
+	}
+
+	return nil
+}
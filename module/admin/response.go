@@ -1,33 +1,233 @@
 package admin
 
 import (
+	dataModel "okkybudiman/data/model"
+	"okkybudiman/formula"
+	"time"
+
 	"github.com/satori/go.uuid"
 )
 
 type testResponse struct {
-	ID            uuid.UUID `json:"id" binding:"required"`
-	Name          string    `json:"name" binding:"required"`
-	Description   string    `json:"description" binding:"required"`
-	TotalQuestion int       `json:"total_question" binding:"required"`
+	ID                  uuid.UUID  `json:"id" binding:"required"`
+	Name                string     `json:"name" binding:"required"`
+	Description         string     `json:"description" binding:"required"`
+	TotalQuestion       int        `json:"total_question" binding:"required"`
+	Status              string     `json:"status"`
+	CreatorID           *uuid.UUID `json:"creator_id"`
+	SubjectID           *uuid.UUID `json:"subject_id"`
+	Tags                []string   `json:"tags"`
+	Leaderboard         string     `json:"leaderboard"`
+	ReviewPolicy        string     `json:"review_policy"`
+	AvailableUntil      *time.Time `json:"available_until"`
+	MaxAttempts         int        `json:"max_attempts"`
+	AllowPractice       bool       `json:"allow_practice"`
+	TargetStandardError float64    `json:"target_standard_error"`
 }
 
 type testDetailResponse struct {
-	ID            uuid.UUID          `json:"id" binding:"required"`
-	Name          string             `json:"name" binding:"required"`
-	Description   string             `json:"description" binding:"required"`
-	TotalQuestion int                `json:"total_question" binding:"required"`
-	Questions     []questionResponse `json:"question" binding:"required"`
+	ID                  uuid.UUID          `json:"id" binding:"required"`
+	Name                string             `json:"name" binding:"required"`
+	Description         string             `json:"description" binding:"required"`
+	TotalQuestion       int                `json:"total_question" binding:"required"`
+	SubjectID           *uuid.UUID         `json:"subject_id"`
+	Tags                []string           `json:"tags"`
+	Leaderboard         string             `json:"leaderboard"`
+	ReviewPolicy        string             `json:"review_policy"`
+	AvailableUntil      *time.Time         `json:"available_until"`
+	MaxAttempts         int                `json:"max_attempts"`
+	AllowPractice       bool               `json:"allow_practice"`
+	TargetStandardError float64            `json:"target_standard_error"`
+	Sections            []sectionResponse  `json:"sections"`
+	Questions           []questionResponse `json:"question" binding:"required"`
 }
 
 type questionResponse struct {
-	ID       uuid.UUID                `json:"id" binding:"required"`
-	Question string                   `json:"question" binding:"required"`
-	Answer   string                   `json:"answer" binding:"required"`
-	Choices  []questionChoiceResponse `json:"choice" binding:"required"`
+	ID              uuid.UUID                `json:"id" binding:"required"`
+	TestID          *uuid.UUID               `json:"test_id,omitempty"`
+	Question        string                   `json:"question" binding:"required"`
+	QuestionHTML    string                   `json:"question_html"`
+	Type            string                   `json:"type"`
+	Format          string                   `json:"format"`
+	AnswerKey       int                      `json:"answer_key" binding:"required"`
+	AnswerKeys      []int                    `json:"answer_keys"`
+	Answer          string                   `json:"answer,omitempty"`
+	TopicID         *uuid.UUID               `json:"topic_id"`
+	SectionID       *uuid.UUID               `json:"section_id"`
+	Difficulty      string                   `json:"difficulty"`
+	Explanation     string                   `json:"explanation"`
+	ExplanationHTML string                   `json:"explanation_html"`
+	Tags            []string                 `json:"tags"`
+	Choices         []questionChoiceResponse `json:"choice" binding:"required"`
+	Attachments     []attachmentResponse     `json:"attachments"`
+
+	Variables        []formula.Variable `json:"variables,omitempty"`
+	AnswerExpression string             `json:"answer_expression,omitempty"`
+	AnswerTolerance  float64            `json:"answer_tolerance,omitempty"`
+
+	//test cases shown to the candidates as examples, the hidden ones are listed by GetTestCases
+	Language    string             `json:"language,omitempty"`
+	TimeLimit   int                `json:"time_limit,omitempty"`
+	MemoryLimit int                `json:"memory_limit,omitempty"`
+	TestCases   []testCaseResponse `json:"test_cases,omitempty"`
+
+	//grading of matching and ordering questions
+	PartialCredit bool `json:"partial_credit,omitempty"`
+	ItemPoint     int  `json:"item_point,omitempty"`
+
+	Blanks []dataModel.ClozeBlank `json:"blanks,omitempty"`
+
+	IrtDiscrimination *float64 `json:"irt_discrimination"`
+	IrtDifficulty     *float64 `json:"irt_difficulty"`
+}
+
+type testCaseResponse struct {
+	ID             uuid.UUID `json:"id"`
+	Key            int       `json:"key"`
+	Input          string    `json:"input"`
+	ExpectedOutput string    `json:"expected_output"`
+	Point          int       `json:"point"`
+	Hidden         bool      `json:"hidden"`
+}
+
+type templateSample struct {
+	Seed            int64              `json:"seed"`
+	Values          map[string]float64 `json:"values"`
+	Question        string             `json:"question"`
+	QuestionHTML    string             `json:"question_html"`
+	Explanation     string             `json:"explanation"`
+	ExplanationHTML string             `json:"explanation_html"`
+	Answer          string             `json:"answer"`
+}
+
+type calibrationResponse struct {
+	ID             uuid.UUID `json:"id"`
+	Question       string    `json:"question"`
+	Calibrated     bool      `json:"calibrated"`
+	Discrimination *float64  `json:"discrimination"`
+	Difficulty     *float64  `json:"difficulty"`
+	Responses      int       `json:"responses"`
 }
 
 type questionChoiceResponse struct {
-	ID     uuid.UUID `json:"id" binding:"required"`
-	Key    int       `json:"key" binding:"required"`
-	Choice string    `json:"choice" binding:"required"`
+	ID           uuid.UUID            `json:"id" binding:"required"`
+	Key          int                  `json:"key" binding:"required"`
+	Choice       string               `json:"choice" binding:"required"`
+	ChoiceHTML   string               `json:"choice_html"`
+	IsCorrect    bool                 `json:"is_correct"`
+	Feedback     string               `json:"feedback"`
+	FeedbackHTML string               `json:"feedback_html"`
+	Match        string               `json:"match,omitempty"`
+	MatchHTML    string               `json:"match_html,omitempty"`
+	Attachments  []attachmentResponse `json:"attachments"`
+}
+
+type attachmentResponse struct {
+	ID          uuid.UUID `json:"id"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	URL         string    `json:"url"`
+}
+
+type bulkQuestionResult struct {
+	Index     int         `json:"index"`
+	Row       int         `json:"row,omitempty"`
+	ID        *uuid.UUID  `json:"id,omitempty"`
+	ChoiceIDs []uuid.UUID `json:"choice_ids,omitempty"`
+	Errors    []string    `json:"errors,omitempty"`
+}
+
+type userResponse struct {
+	ID    uuid.UUID `json:"id"`
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Role  string    `json:"role"`
+}
+
+type resultResponse struct {
+	ID                 uuid.UUID `json:"id"`
+	TestID             uuid.UUID `json:"test_id"`
+	UserID             uuid.UUID `json:"user_id"`
+	Name               string    `json:"name"`
+	TotalRightAnswered int       `json:"total_right_answered"`
+	TotalWrongAnswered int       `json:"total_wrong_answered"`
+	TotalNotAnswered   int       `json:"total_not_answered"`
+	Score              int       `json:"score"`
+}
+
+type subjectResponse struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	TotalTopic  int       `json:"total_topic"`
+}
+
+type sectionResponse struct {
+	ID            uuid.UUID `json:"id"`
+	TestID        uuid.UUID `json:"test_id"`
+	Name          string    `json:"name"`
+	Key           int       `json:"key"`
+	TimeLimit     int       `json:"time_limit"`
+	TotalQuestion int       `json:"total_question"`
+}
+
+type topicResponse struct {
+	ID            uuid.UUID `json:"id"`
+	SubjectID     uuid.UUID `json:"subject_id"`
+	Subject       string    `json:"subject"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	TotalQuestion int       `json:"total_question"`
+}
+
+type tagResponse struct {
+	ID            uuid.UUID `json:"id"`
+	Name          string    `json:"name"`
+	TotalTest     int       `json:"total_test"`
+	TotalQuestion int       `json:"total_question"`
+}
+
+type resultExportResponse struct {
+	UserID             uuid.UUID              `json:"user_id"`
+	Name               string                 `json:"name"`
+	Email              string                 `json:"email"`
+	StartTest          *time.Time             `json:"start_test"`
+	EndTest            *time.Time             `json:"end_test"`
+	FinishTime         string                 `json:"finish_time"`
+	TotalRightAnswered int                    `json:"total_right_answered"`
+	TotalWrongAnswered int                    `json:"total_wrong_answered"`
+	TotalNotAnswered   int                    `json:"total_not_answered"`
+	Score              int                    `json:"score"`
+	Answers            []resultAnswerResponse `json:"answers,omitempty"`
+}
+
+type resultAnswerResponse struct {
+	QuestionID uuid.UUID `json:"question_id"`
+	Number     int       `json:"number"`
+	Answer     string    `json:"answer"`
+	Point      int       `json:"point"`
+}
+
+type rosterResponse struct {
+	UserID             uuid.UUID  `json:"user_id"`
+	Name               string     `json:"name"`
+	Email              string     `json:"email"`
+	Assigned           bool       `json:"assigned"`
+	Cohort             string     `json:"cohort"`
+	Status             string     `json:"status"`
+	StartTest          *time.Time `json:"start_test"`
+	EndTest            *time.Time `json:"end_test"`
+	FinishTime         string     `json:"finish_time"`
+	TotalRightAnswered *int       `json:"total_right_answered"`
+	TotalWrongAnswered *int       `json:"total_wrong_answered"`
+	TotalNotAnswered   *int       `json:"total_not_answered"`
+	Score              *int       `json:"score"`
+}
+
+type rosterSummaryResponse struct {
+	Assigned   int `json:"assigned"`
+	NotStarted int `json:"not_started"`
+	Started    int `json:"started"`
+	Finished   int `json:"finished"`
 }
@@ -0,0 +1,436 @@
+package admin
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	dataModel "okkybudiman/data/model"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+// number of exported results written before the response is flushed
+const exportFlushSize = 100
+
+// resultRow a row of the results export query
+type resultRow struct {
+	UserID             uuid.UUID
+	AttemptID          uuid.UUID
+	Name               string
+	Email              string
+	StartTest          *time.Time
+	EndTest            *time.Time
+	FinishTime         *string
+	TotalRightAnswered int
+	TotalWrongAnswered int
+	TotalNotAnswered   int
+	Score              int
+}
+
+// resultWriter write exported results in a format
+type resultWriter interface {
+	Write(res resultExportResponse) error
+	Close() error
+}
+
+// ExportResult export the results of every participant of a test as csv or json, a participant has a row for every
+// finished attempt. With answers=true the answer of every question is added. Results are streamed from the database
+// so large tests are not held in memory.
+func (ctrl *Controller) ExportResult(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	if format != "csv" && format != "json" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"format must be csv or json"}})
+		return
+	}
+	withAnswers := c.Query("answers") == "true"
+
+	var test dataModel.Test
+	testID, _ := uuid.FromString(c.Query("test_id"))
+	if err := db.Where("id = ?", testID).First(&test).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Test",
+		})
+		return
+	}
+
+	var questions []dataModel.Question
+	if withAnswers {
+		if err := db.Where("test_id = ?", testID).Preload("QuestionChoices").Order("created_at").Find(&questions).Error; err != nil {
+			glog.Errorf("Failed to load questions: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	rows, err := db.Table("user_scores").
+		Select("user_scores.user_id, user_scores.user_attempt_test_id AS attempt_id, users.name, users.email, "+
+			"user_attempt_tests.start_test, user_attempt_tests.end_test, user_attempt_tests.finish_time, "+
+			"user_scores.total_right_answered, user_scores.total_wrong_answered, user_scores.total_not_answered, user_scores.score").
+		Joins("JOIN users ON users.id = user_scores.user_id").
+		Joins("LEFT JOIN user_attempt_tests ON user_attempt_tests.id = user_scores.user_attempt_test_id "+
+			"AND user_attempt_tests.deleted_at IS NULL").
+		Where("user_scores.test_id = ? AND user_scores.deleted_at IS NULL", testID).
+		Order("users.name, user_attempt_tests.end_test").
+		Rows()
+	if err != nil {
+		glog.Errorf("Failed to export result: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var writer resultWriter
+	if format == "json" {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		c.Header("Content-Disposition", "attachment; filename=results.json")
+		writer = newJSONResultWriter(c, test)
+	} else {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", "attachment; filename=results.csv")
+		writer = newCSVResultWriter(c, len(questions), withAnswers)
+	}
+
+	//once the status is sent a failure cannot be reported, the response is aborted so the client does not take
+	//a partial export for a complete one
+	count := 0
+	failed := false
+	for rows.Next() {
+		var row resultRow
+		if err := db.ScanRows(rows, &row); err != nil {
+			glog.Errorf("Failed to read result: %s", err)
+			failed = true
+			break
+		}
+
+		res := resultExportResponse{
+			UserID:             row.UserID,
+			Name:               row.Name,
+			Email:              row.Email,
+			StartTest:          row.StartTest,
+			EndTest:            row.EndTest,
+			TotalRightAnswered: row.TotalRightAnswered,
+			TotalWrongAnswered: row.TotalWrongAnswered,
+			TotalNotAnswered:   row.TotalNotAnswered,
+			Score:              row.Score,
+		}
+		if row.FinishTime != nil {
+			res.FinishTime = *row.FinishTime
+		}
+		if withAnswers {
+			if res.Answers, err = resultAnswers(db, row.AttemptID, questions); err != nil {
+				glog.Errorf("Failed to load answers: %s", err)
+				failed = true
+				break
+			}
+		}
+
+		if err := writer.Write(res); err != nil {
+			glog.Errorf("Failed to write result: %s", err)
+			return
+		}
+		count++
+		if count%exportFlushSize == 0 {
+			c.Writer.Flush()
+		}
+	}
+	if err := rows.Err(); err != nil && !failed {
+		glog.Errorf("Failed to read result: %s", err)
+		failed = true
+	}
+	if failed {
+		abortResponse(c)
+		return
+	}
+
+	if err := writer.Close(); err != nil {
+		glog.Errorf("Failed to write result: %s", err)
+	}
+}
+
+// abortResponse end a response which cannot be completed. The status is an error when nothing is sent yet, otherwise
+// the connection is closed before the end of the response so the client sees the download fail.
+func abortResponse(c *gin.Context) {
+	if !c.Writer.Written() {
+		c.Writer.Header().Del("Content-Disposition")
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.Abort()
+	conn, _, err := c.Writer.Hijack()
+	if err != nil {
+		glog.Errorf("Failed to abort response: %s", err)
+		return
+	}
+	conn.Close()
+}
+
+// resultAnswers the answer to every question of a test in an attempt, in the order of the questions
+func resultAnswers(db *gorm.DB, attemptID uuid.UUID, questions []dataModel.Question) ([]resultAnswerResponse, error) {
+	var answers []dataModel.UserAnswer
+	if err := db.Where("user_attempt_test_id = ?", attemptID).Find(&answers).Error; err != nil {
+		return nil, err
+	}
+
+	answered := make(map[uuid.UUID]dataModel.UserAnswer)
+	for _, v := range answers {
+		answered[v.QuestionID] = v
+	}
+
+	responses := make([]resultAnswerResponse, len(questions))
+	for k, q := range questions {
+		responses[k] = resultAnswerResponse{QuestionID: q.ID, Number: k + 1}
+		if v, ok := answered[q.ID]; ok {
+			responses[k].Answer = answerText(q, v)
+			responses[k].Point = v.Point
+		}
+	}
+
+	return responses, nil
+}
+
+// answerText the answer of a user as the keys of the picked choices separated by |, the text of a text entry
+// or calculated answer, or the code of a programming answer. Ordering answers are the keys in the order of the user
+// and matching answers the key and the match of every pair, as 1=match. Cloze answers are the answers of the blanks
+// separated by |.
+func answerText(question dataModel.Question, answer dataModel.UserAnswer) string {
+	if question.Type == dataModel.QuestionTypeProgramming {
+		var response dataModel.ProgrammingResponse
+		json.Unmarshal([]byte(answer.Response), &response)
+		return response.Code
+	}
+	if question.Type == dataModel.QuestionTypeCloze {
+		var blanks []string
+		json.Unmarshal([]byte(answer.Response), &blanks)
+		return strings.Join(blanks, "|")
+	}
+	if !question.HasChoices() {
+		var text string
+		json.Unmarshal([]byte(answer.Response), &text)
+		return text
+	}
+
+	keys := make(map[string]int)
+	for _, v := range question.QuestionChoices {
+		keys[v.ID.String()] = v.Key
+	}
+
+	switch question.Type {
+	case dataModel.QuestionTypeOrdering:
+		var ids []string
+		var values []string
+		json.Unmarshal([]byte(answer.Response), &ids)
+		for _, id := range ids {
+			if key, ok := keys[id]; ok {
+				values = append(values, strconv.Itoa(key))
+			}
+		}
+		return strings.Join(values, "|")
+
+	case dataModel.QuestionTypeMatching:
+		matches := make(map[string]string)
+		for _, v := range question.QuestionChoices {
+			matches[dataModel.MatchID(question.ID, v.Match).String()] = v.Match
+		}
+
+		var pairs map[string]string
+		json.Unmarshal([]byte(answer.Response), &pairs)
+		picked := make(map[int]string)
+		var order []int
+		for id, matchID := range pairs {
+			key, ok := keys[id]
+			match, found := matches[matchID]
+			if ok && found {
+				picked[key] = match
+				order = append(order, key)
+			}
+		}
+		sort.Ints(order)
+
+		var values []string
+		for _, key := range order {
+			values = append(values, fmt.Sprintf("%d=%s", key, picked[key]))
+		}
+		return strings.Join(values, "|")
+	}
+
+	var picked []int
+	for _, id := range answerChoiceIDs(question, answer) {
+		if key, ok := keys[id]; ok {
+			picked = append(picked, key)
+		}
+	}
+	sort.Ints(picked)
+
+	var values []string
+	for _, key := range picked {
+		values = append(values, strconv.Itoa(key))
+	}
+	return strings.Join(values, "|")
+}
+
+// answerChoiceIDs the ids of the choices picked in the answer of a choice question
+func answerChoiceIDs(question dataModel.Question, answer dataModel.UserAnswer) []string {
+	switch question.Type {
+	case dataModel.QuestionTypeMultipleChoice:
+		var ids []string
+		json.Unmarshal([]byte(answer.Response), &ids)
+		return ids
+	case dataModel.QuestionTypeTextEntry, dataModel.QuestionTypeCalculated, dataModel.QuestionTypeProgramming,
+		dataModel.QuestionTypeMatching, dataModel.QuestionTypeOrdering, dataModel.QuestionTypeCloze:
+		return nil
+	}
+
+	if answer.QuestionChoiceID == uuid.Nil {
+		return nil
+	}
+	return []string{answer.QuestionChoiceID.String()}
+}
+
+type csvResultWriter struct {
+	writer      *csv.Writer
+	answers     bool
+	wroteHeader bool
+	header      []string
+}
+
+func newCSVResultWriter(c *gin.Context, totalQuestion int, answers bool) *csvResultWriter {
+	header := []string{"user_id", "name", "email", "start_test", "end_test", "finish_time",
+		"total_right_answered", "total_wrong_answered", "total_not_answered", "score"}
+	if answers {
+		for i := 1; i <= totalQuestion; i++ {
+			header = append(header, fmt.Sprintf("q%d_answer", i), fmt.Sprintf("q%d_point", i))
+		}
+	}
+
+	return &csvResultWriter{writer: csv.NewWriter(c.Writer), answers: answers, header: header}
+}
+
+func (w *csvResultWriter) Write(res resultExportResponse) error {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if err := w.writer.Write(w.header); err != nil {
+			return err
+		}
+	}
+
+	row := []string{
+		res.UserID.String(),
+		csvText(res.Name),
+		csvText(res.Email),
+		exportTime(res.StartTest),
+		exportTime(res.EndTest),
+		res.FinishTime,
+		strconv.Itoa(res.TotalRightAnswered),
+		strconv.Itoa(res.TotalWrongAnswered),
+		strconv.Itoa(res.TotalNotAnswered),
+		strconv.Itoa(res.Score),
+	}
+	for _, v := range res.Answers {
+		row = append(row, csvText(v.Answer), strconv.Itoa(v.Point))
+	}
+	if err := w.writer.Write(row); err != nil {
+		return err
+	}
+
+	//rows are flushed as they are written so the csv writer holds at most one row
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func (w *csvResultWriter) Close() error {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.writer.Write(w.header)
+	}
+	w.writer.Flush()
+
+	return w.writer.Error()
+}
+
+// csvText quote a text typed by a user so a spreadsheet does not run it as a formula, the numbers of the export are
+// written as they are
+func csvText(text string) string {
+	if text != "" && strings.ContainsRune("=+-@", rune(text[0])) {
+		return "'" + text
+	}
+
+	return text
+}
+
+// jsonResultWriter write the results as a json object with the results array written one item at a time
+type jsonResultWriter struct {
+	c       *gin.Context
+	test    dataModel.Test
+	started bool
+	count   int
+}
+
+func newJSONResultWriter(c *gin.Context, test dataModel.Test) *jsonResultWriter {
+	return &jsonResultWriter{c: c, test: test}
+}
+
+func (w *jsonResultWriter) start() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+
+	name, _ := json.Marshal(w.test.Name)
+	_, err := fmt.Fprintf(w.c.Writer, `{"test_id":"%s","name":%s,"results":[`, w.test.ID, name)
+	return err
+}
+
+func (w *jsonResultWriter) Write(res resultExportResponse) error {
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	if w.count > 0 {
+		if _, err := w.c.Writer.WriteString(","); err != nil {
+			return err
+		}
+	}
+	w.count++
+
+	_, err = w.c.Writer.Write(data)
+	return err
+}
+
+func (w *jsonResultWriter) Close() error {
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w.c.Writer, `],"total":%d}`, w.count)
+	return err
+}
+
+func exportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,293 @@
+package admin
+
+import (
+	"net/http"
+	dataModel "okkybudiman/data/model"
+	u "okkybudiman/utility"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+// status of a user in the roster of a test
+const (
+	rosterNotStarted = "not_started"
+	rosterStarted    = "started"
+	rosterFinished   = "finished"
+)
+
+var rosterSortColumns = map[string]string{
+	"name":       "users.name",
+	"email":      "users.email",
+	"cohort":     "test_assignments.cohort",
+	"start_test": "user_attempt_tests.start_test",
+	"end_test":   "user_attempt_tests.end_test",
+	"score":      "user_scores.score",
+}
+
+// rosterRow a row of the roster query
+type rosterRow struct {
+	UserID             uuid.UUID
+	Name               string
+	Email              string
+	AssignmentID       *string
+	Cohort             *string
+	AttemptID          *string
+	IsFinished         *bool
+	StartTest          *time.Time
+	EndTest            *time.Time
+	FinishTime         *string
+	TotalRightAnswered *int
+	TotalWrongAnswered *int
+	TotalNotAnswered   *int
+	Score              *int
+}
+
+// AssignTest assign users to a test, optionally in a cohort. Users already assigned are moved to the cohort.
+func (ctrl *Controller) AssignTest(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var req assignTestRequest
+	var test dataModel.Test
+	if !u.BindJSON(c, &req) {
+		return
+	}
+
+	testID, _ := uuid.FromString(req.TestID)
+	if err := db.Where("id = ?", testID).First(&test).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Test",
+		})
+		return
+	}
+
+	var users []dataModel.User
+	db.Where("id IN (?)", req.UserIDs).Find(&users)
+	if len(users) != len(uniqueStrings(req.UserIDs)) {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find User",
+		})
+		return
+	}
+
+	tx := db.Begin()
+	for _, v := range users {
+		var assignment dataModel.TestAssignment
+		if err := tx.Where(dataModel.TestAssignment{TestID: testID, UserID: v.ID}).
+			Assign(dataModel.TestAssignment{Cohort: req.Cohort}).FirstOrCreate(&assignment).Error; err != nil {
+			tx.Rollback()
+			glog.Errorf("Failed to assign test: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		glog.Errorf("Failed to assign test: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  http.StatusCreated,
+		"message": "success assign test",
+		"total":   len(users),
+	})
+	return
+}
+
+// UnassignTest remove users from the assigned users of a test, their attempts are kept
+func (ctrl *Controller) UnassignTest(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var req unassignTestRequest
+	if !u.BindJSON(c, &req) {
+		return
+	}
+
+	testID, _ := uuid.FromString(req.TestID)
+	if err := db.Where("test_id = ? AND user_id IN (?)", testID, req.UserIDs).Delete(&dataModel.TestAssignment{}).Error; err != nil {
+		glog.Errorf("Failed to unassign test: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "success unassign test",
+	})
+	return
+}
+
+// GetRoster list the assigned users of a test and the users who attempted it, with their attempt and score.
+// It is filtered by status (not_started, started, finished) and cohort, searched by name or email with q.
+// The summary counts the assigned users by status.
+func (ctrl *Controller) GetRoster(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var test dataModel.Test
+	testID, _ := uuid.FromString(c.Param("id"))
+	if err := db.Where("id = ?", testID).First(&test).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Test",
+		})
+		return
+	}
+
+	pagination, err := u.NewPagination(c, rosterSortColumns, "name")
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	status := c.Query("status")
+	if status != "" && status != rosterNotStarted && status != rosterStarted && status != rosterFinished {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"status must be not_started, started or finished"}})
+		return
+	}
+
+	base := rosterQuery(db, testID)
+	if cohort := c.Query("cohort"); cohort != "" {
+		base = base.Where("test_assignments.cohort = ?", cohort)
+	}
+	if pagination.Search != "" {
+		base = pagination.SearchWhere(base, "users.name", "users.email")
+	}
+
+	var summary rosterSummaryResponse
+	assigned := base.Where("test_assignments.id IS NOT NULL")
+	assigned.Count(&summary.Assigned)
+	rosterStatus(assigned, rosterNotStarted).Count(&summary.NotStarted)
+	rosterStatus(assigned, rosterStarted).Count(&summary.Started)
+	rosterStatus(assigned, rosterFinished).Count(&summary.Finished)
+
+	var total int
+	query := rosterStatus(base, status)
+	query.Count(&total)
+
+	rows, err := pagination.Apply(query).Select("users.id AS user_id, users.name, users.email, " +
+		"test_assignments.id AS assignment_id, test_assignments.cohort, user_attempt_tests.id AS attempt_id, " +
+		"user_attempt_tests.is_finished, user_attempt_tests.start_test, user_attempt_tests.end_test, user_attempt_tests.finish_time, " +
+		"user_scores.total_right_answered, user_scores.total_wrong_answered, user_scores.total_not_answered, user_scores.score").Rows()
+	if err != nil {
+		glog.Errorf("Failed to get roster: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var responses []rosterResponse
+	for rows.Next() {
+		var row rosterRow
+		if err := db.ScanRows(rows, &row); err != nil {
+			glog.Errorf("Failed to read roster: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		res := rosterResponse{
+			UserID:             row.UserID,
+			Name:               row.Name,
+			Email:              row.Email,
+			Assigned:           row.AssignmentID != nil,
+			Status:             rosterNotStarted,
+			TotalRightAnswered: row.TotalRightAnswered,
+			TotalWrongAnswered: row.TotalWrongAnswered,
+			TotalNotAnswered:   row.TotalNotAnswered,
+			Score:              row.Score,
+		}
+		if row.Cohort != nil {
+			res.Cohort = *row.Cohort
+		}
+		if row.AttemptID != nil {
+			res.Status = rosterStarted
+			if row.IsFinished != nil && *row.IsFinished {
+				res.Status = rosterFinished
+				res.EndTest = row.EndTest
+				if row.FinishTime != nil {
+					res.FinishTime = *row.FinishTime
+				}
+			}
+			res.StartTest = row.StartTest
+		}
+		responses = append(responses, res)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     http.StatusOK,
+		"message":    "success get roster",
+		"data":       responses,
+		"summary":    summary,
+		"total":      total,
+		"pagination": pagination.Meta(total),
+	})
+	return
+}
+
+// rosterQuery the users assigned to a test or who attempted it, joined with their assignment, their last attempt
+// and its score
+func rosterQuery(db *gorm.DB, testID uuid.UUID) *gorm.DB {
+	return db.Table("users").
+		Joins("LEFT JOIN test_assignments ON test_assignments.user_id = users.id "+
+			"AND test_assignments.test_id = ? AND test_assignments.deleted_at IS NULL", testID).
+		Joins("LEFT JOIN user_attempt_tests ON user_attempt_tests.user_id = users.id "+
+			"AND user_attempt_tests.test_id = ? AND user_attempt_tests.deleted_at IS NULL "+
+			"AND user_attempt_tests.mode <> ? AND user_attempt_tests.start_test = ("+
+			"SELECT MAX(last.start_test) FROM user_attempt_tests last WHERE last.user_id = users.id "+
+			"AND last.test_id = ? AND last.deleted_at IS NULL AND last.mode <> ?)",
+			testID, dataModel.AttemptModePractice, testID, dataModel.AttemptModePractice).
+		Joins("LEFT JOIN user_scores ON user_scores.user_attempt_test_id = user_attempt_tests.id " +
+			"AND user_scores.deleted_at IS NULL").
+		Where("users.deleted_at IS NULL").
+		Where("test_assignments.id IS NOT NULL OR user_attempt_tests.id IS NOT NULL")
+}
+
+// rosterStatus filter a roster query by status, an empty status does not filter
+func rosterStatus(query *gorm.DB, status string) *gorm.DB {
+	switch status {
+	case rosterNotStarted:
+		return query.Where("user_attempt_tests.id IS NULL")
+	case rosterStarted:
+		return query.Where("user_attempt_tests.id IS NOT NULL AND user_attempt_tests.is_finished = ?", false)
+	case rosterFinished:
+		return query.Where("user_attempt_tests.is_finished = ?", true)
+	}
+
+	return query
+}
+
+func uniqueStrings(values []string) []string {
+	var unique []string
+	seen := make(map[string]bool)
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+
+	return unique
+}
@@ -0,0 +1,361 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	dataModel "okkybudiman/data/model"
+	u "okkybudiman/utility"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+// maximum time limit of a section in seconds
+const maxSectionTimeLimit = 24 * 60 * 60
+
+// GetSections list the sections of a test in the order they are answered, with their total questions
+func (ctrl *Controller) GetSections(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var test dataModel.Test
+	var sections []dataModel.Section
+	testID, _ := uuid.FromString(c.Param("id"))
+	if err := db.Where("id = ?", testID).First(&test).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Test",
+		})
+		return
+	}
+	db.Where("test_id = ?", testID).Find(&sections)
+	sortSections(sections)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "success get sections",
+		"data":    sectionResponses(db, sections),
+	})
+	return
+}
+
+// CreateSection add a section to a test.
+// The section is appended unless key is set, the keys of the following sections are shifted.
+func (ctrl *Controller) CreateSection(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var req sectionRequest
+	var test dataModel.Test
+	var sections []dataModel.Section
+	if !u.BindJSON(c, &req) {
+		return
+	}
+
+	testID, _ := uuid.FromString(req.TestID)
+	if err := db.Where("id = ?", testID).First(&test).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Test",
+		})
+		return
+	}
+
+	db.Where("test_id = ?", testID).Find(&sections)
+	sortSections(sections)
+
+	key := req.Key
+	if key == 0 {
+		key = len(sections) + 1
+	}
+	if key < 1 || key > len(sections)+1 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{fmt.Sprintf("key must be between 1 and %d", len(sections)+1)}})
+		return
+	}
+	if errors := validateSection(req.TimeLimit); len(errors) > 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": errors})
+		return
+	}
+
+	section := dataModel.Section{
+		TestID:    testID,
+		Name:      req.Name,
+		Key:       key,
+		TimeLimit: req.TimeLimit,
+	}
+
+	tx := db.Begin()
+	if err := tx.Create(&section).Error; err != nil {
+		tx.Rollback()
+		glog.Errorf("Failed to create section: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ordered := append([]dataModel.Section{}, sections[:key-1]...)
+	ordered = append(ordered, section)
+	ordered = append(ordered, sections[key-1:]...)
+	if err := renumberSections(tx, ordered); err != nil {
+		tx.Rollback()
+		glog.Errorf("Failed to renumber sections: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		glog.Errorf("Failed to commit section: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  http.StatusCreated,
+		"message": "success create section",
+		"data":    sectionResponses(db, ordered),
+	})
+	return
+}
+
+// UpdateSection rename a section, its time limit is kept when it is not sent
+func (ctrl *Controller) UpdateSection(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var req updateSectionRequest
+	var section dataModel.Section
+	if !u.BindJSON(c, &req) {
+		return
+	}
+
+	uid, _ := uuid.FromString(req.SectionID)
+	if err := db.Where("id = ?", uid).First(&section).Error; err == nil {
+		if req.TimeLimit != nil {
+			if errors := validateSection(*req.TimeLimit); len(errors) > 0 {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": errors})
+				return
+			}
+			section.TimeLimit = *req.TimeLimit
+		}
+		section.Name = req.Name
+		db.Save(&section)
+
+		c.JSON(http.StatusCreated, gin.H{
+			"status":  http.StatusCreated,
+			"message": "success update section",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusNotFound,
+		"message": "cannot find Section",
+	})
+	return
+}
+
+// ReorderSection set the order of the sections of a test.
+// section_ids must list every section of the test once, keys are renumbered following it.
+func (ctrl *Controller) ReorderSection(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var req reorderSectionRequest
+	var sections []dataModel.Section
+	if !u.BindJSON(c, &req) {
+		return
+	}
+
+	testID, _ := uuid.FromString(req.TestID)
+	db.Where("test_id = ?", testID).Find(&sections)
+	if len(sections) == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Section",
+		})
+		return
+	}
+
+	if len(req.SectionIDs) != len(sections) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{fmt.Sprintf("section_ids must contain all %d sections of the test", len(sections))}})
+		return
+	}
+
+	byID := make(map[uuid.UUID]dataModel.Section)
+	for _, v := range sections {
+		byID[v.ID] = v
+	}
+
+	var ordered []dataModel.Section
+	for _, v := range req.SectionIDs {
+		id, _ := uuid.FromString(v)
+		section, ok := byID[id]
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{fmt.Sprintf("section %s is not a section of the test or is duplicated", v)}})
+			return
+		}
+
+		delete(byID, id)
+		ordered = append(ordered, section)
+	}
+
+	tx := db.Begin()
+	if err := renumberSections(tx, ordered); err != nil {
+		tx.Rollback()
+		glog.Errorf("Failed to renumber sections: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		glog.Errorf("Failed to commit sections: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "success reorder section",
+		"data":    sectionResponses(db, ordered),
+	})
+	return
+}
+
+// DeleteSection delete a section, its questions are left without section and the following sections are renumbered
+func (ctrl *Controller) DeleteSection(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var req deleteSectionRequest
+	var section dataModel.Section
+	var sections []dataModel.Section
+	if !u.BindJSON(c, &req) {
+		return
+	}
+
+	uid, _ := uuid.FromString(req.SectionID)
+	if err := db.Where("id = ?", uid).First(&section).Error; err == nil {
+		db.Where("test_id = ? AND id <> ?", section.TestID, section.ID).Find(&sections)
+		sortSections(sections)
+
+		tx := db.Begin()
+		tx.Model(&dataModel.Question{}).Where("section_id = ?", uid).Update("section_id", gorm.Expr("NULL"))
+		tx.Delete(&section)
+		if err := renumberSections(tx, sections); err != nil {
+			tx.Rollback()
+			glog.Errorf("Failed to renumber sections: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if err := tx.Commit().Error; err != nil {
+			glog.Errorf("Failed to delete section: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusOK,
+			"message": "success delete section",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusNotFound,
+		"message": "cannot find Section",
+	})
+	return
+}
+
+// validateSection check the time limit of a section
+func validateSection(timeLimit int) []string {
+	var errors []string
+	if timeLimit < 0 || timeLimit > maxSectionTimeLimit {
+		errors = append(errors, fmt.Sprintf("time_limit must be between 0 and %d seconds", maxSectionTimeLimit))
+	}
+
+	return errors
+}
+
+// resolveSection get the section of a question from its id, the section must belong to the test of the question.
+// An empty id is no section.
+func resolveSection(db *gorm.DB, testID uuid.UUID, id string) (*uuid.UUID, bool) {
+	if id == "" {
+		return nil, true
+	}
+
+	var section dataModel.Section
+	uid, _ := uuid.FromString(id)
+	if err := db.Where("id = ? AND test_id = ?", uid, testID).First(&section).Error; err != nil {
+		return nil, false
+	}
+
+	return &section.ID, true
+}
+
+// sortSections sort sections by their key.
+func sortSections(sections []dataModel.Section) {
+	sort.SliceStable(sections, func(i, j int) bool {
+		return sections[i].Key < sections[j].Key
+	})
+}
+
+// renumberSections set the key of every section to its position in sections, starting from 1.
+func renumberSections(tx *gorm.DB, sections []dataModel.Section) error {
+	for k := range sections {
+		if sections[k].Key == k+1 {
+			continue
+		}
+
+		sections[k].Key = k + 1
+		if err := tx.Model(&sections[k]).Update("key", k+1).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sectionResponses build the responses of sections with their total questions
+func sectionResponses(db *gorm.DB, sections []dataModel.Section) []sectionResponse {
+	var responses []sectionResponse
+	for _, v := range sections {
+		res := sectionResponse{
+			ID:        v.ID,
+			TestID:    v.TestID,
+			Name:      v.Name,
+			Key:       v.Key,
+			TimeLimit: v.TimeLimit,
+		}
+		db.Model(&dataModel.Question{}).Where("section_id = ?", v.ID).Count(&res.TotalQuestion)
+		responses = append(responses, res)
+	}
+
+	return responses
+}
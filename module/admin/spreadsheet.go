@@ -0,0 +1,328 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	dataModel "okkybudiman/data/model"
+	"okkybudiman/exchange"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+// ImportQuestion import the questions of a csv or xlsx file into a test, or into the question bank of a topic
+// when only topic_id is set. Every row is validated first, with dry_run nothing is saved.
+func (ctrl *Controller) ImportQuestion(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"file is required"}})
+		return
+	}
+
+	format := strings.ToLower(c.PostForm("format"))
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(file.Filename)), ".")
+	}
+
+	var mapping map[string]string
+	if v := c.PostForm("mapping"); v != "" {
+		if err := json.Unmarshal([]byte(v), &mapping); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"mapping must be a json object of column names"}})
+			return
+		}
+	}
+
+	rows, err := readRows(file, format)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	items, itemErrors, err := exchange.RowsToItems(rows, mapping)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	testID, topicID, available, ok := importTarget(c, db, len(items))
+	if !ok {
+		return
+	}
+
+	reqs := itemsToQuestions(items, topicID)
+	results, valid := validateQuestions(db, testID, reqs, available)
+	for i := range results {
+		results[i].Row = items[i].Row
+	}
+	for _, v := range itemErrors {
+		results[v.Index].Errors = append(v.Errors, results[v.Index].Errors...)
+		valid = false
+	}
+
+	if c.PostForm("dry_run") == "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusOK,
+			"message": "dry run, nothing is saved",
+			"valid":   valid,
+			"data":    results,
+		})
+		return
+	}
+
+	if !valid {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "invalid questions, nothing is saved",
+			"data":    results,
+		})
+		return
+	}
+
+	tx := db.Begin()
+	if err := applyCategories(tx, items, reqs); err != nil {
+		tx.Rollback()
+		glog.Errorf("Failed to save categories: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if err := saveQuestions(tx, testID, reqs, results); err != nil {
+		tx.Rollback()
+		glog.Errorf("Failed to import questions: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		glog.Errorf("Failed to commit questions: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  http.StatusCreated,
+		"message": "success import question",
+		"data":    results,
+	})
+	return
+}
+
+// ExportQuestion export the questions of a test, or of a topic, as a csv or xlsx file which can be imported back
+func (ctrl *Controller) ExportQuestion(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	if format != "csv" && format != "xlsx" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"format must be csv or xlsx"}})
+		return
+	}
+
+	items, ok := exportItems(c, db)
+	if !ok {
+		return
+	}
+
+	rows := exchange.ItemsToRows(items)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=questions.%s", format))
+	if format == "xlsx" {
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		err = exchange.WriteXLSX(c.Writer, rows)
+	} else {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		err = exchange.WriteCSV(c.Writer, rows)
+	}
+	if err != nil {
+		glog.Errorf("Failed to export questions: %s", err)
+	}
+}
+
+func readRows(file *multipart.FileHeader, format string) ([][]string, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch format {
+	case "csv":
+		return exchange.ReadCSV(f)
+	case "xlsx":
+		return exchange.ReadXLSX(f, file.Size)
+	}
+
+	return nil, fmt.Errorf("format must be csv or xlsx")
+}
+
+// importTarget resolve the test_id or topic_id form values of an import and the number of questions it can still hold.
+// Imports without test go to the question bank, the request is aborted when the target does not exist.
+func importTarget(c *gin.Context, db *gorm.DB, totalItem int) (uuid.UUID, string, int, bool) {
+	topicID := c.PostForm("topic_id")
+	if _, ok := resolveTopic(db, topicID); !ok {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Topic",
+		})
+		return uuid.Nil, "", 0, false
+	}
+
+	if c.PostForm("test_id") == "" {
+		if topicID == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"test_id or topic_id is required"}})
+			return uuid.Nil, "", 0, false
+		}
+		return uuid.Nil, topicID, totalItem, true
+	}
+
+	var test dataModel.Test
+	var count int
+	testID, _ := uuid.FromString(c.PostForm("test_id"))
+	if err := db.Where("id = ?", testID).First(&test).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Test",
+		})
+		return uuid.Nil, "", 0, false
+	}
+	db.Model(&dataModel.Question{}).Where("test_id = ?", testID).Count(&count)
+
+	return testID, topicID, test.TotalQuestion - count, true
+}
+
+// exportItems load the questions of the test_id or topic_id query parameter, the request is aborted when none is set
+func exportItems(c *gin.Context, db *gorm.DB) ([]exchange.Item, bool) {
+	var questions []dataModel.Question
+
+	//files have no place for the variables of calculated questions, the test cases of programming questions nor the
+	//blanks of cloze questions
+	query := db.Preload("QuestionChoices").Preload("Tags").
+		Where("type NOT IN (?)", []string{dataModel.QuestionTypeCalculated, dataModel.QuestionTypeProgramming, dataModel.QuestionTypeCloze}).
+		Order("created_at")
+	if testID := c.Query("test_id"); testID != "" {
+		query = query.Where("test_id = ?", testID)
+	} else if topicID := c.Query("topic_id"); topicID != "" {
+		query = query.Where("topic_id = ?", topicID)
+	} else {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"test_id or topic_id is required"}})
+		return nil, false
+	}
+
+	if err := query.Find(&questions).Error; err != nil {
+		glog.Errorf("Failed to load questions: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return nil, false
+	}
+
+	categories, err := topicCategories(db, questions)
+	if err != nil {
+		glog.Errorf("Failed to load topics: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return questionsToItems(questions, categories), true
+}
+
+// questionsToItems convert questions into items, categories are the category of the topics by topic id
+func questionsToItems(questions []dataModel.Question, categories map[uuid.UUID]string) []exchange.Item {
+	var items []exchange.Item
+	for _, v := range questions {
+		item := exchange.Item{
+			Question:   v.Question,
+			Type:       v.Type,
+			Format:     v.Format,
+			Difficulty: v.Difficulty,
+			Tags:       tagNames(v.Tags),
+		}
+		if !v.HasChoices() {
+			item.Answer = v.Answer
+		}
+		if v.Type == dataModel.QuestionTypeMatching || v.Type == dataModel.QuestionTypeOrdering {
+			item.PartialCredit = v.PartialCredit
+		}
+		if v.TopicID != nil {
+			item.TopicID = v.TopicID.String()
+			item.Category = categories[*v.TopicID]
+		}
+
+		sortChoices(v.QuestionChoices)
+		for _, choice := range v.QuestionChoices {
+			item.Choices = append(item.Choices, choice.Choice)
+			if v.Type == dataModel.QuestionTypeMatching {
+				item.Matches = append(item.Matches, choice.Match)
+			}
+			if choice.IsCorrect {
+				item.AnswerKeys = append(item.AnswerKeys, choice.Key)
+			}
+		}
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// itemsToQuestions convert imported items into question requests, topicID is used for items without topic
+func itemsToQuestions(items []exchange.Item, topicID string) []questions {
+	var reqs []questions
+	for _, v := range items {
+		req := questions{
+			Question:   v.Question,
+			Type:       v.Type,
+			Format:     v.Format,
+			AnswerKeys: v.AnswerKeys,
+			Answer:     v.Answer,
+			TopicID:    v.TopicID,
+			Difficulty: v.Difficulty,
+			Tags:       v.Tags,
+
+			PartialCredit: v.PartialCredit,
+		}
+		if req.TopicID == "" {
+			req.TopicID = topicID
+		}
+		for k, choice := range v.Choices {
+			req.Choices = append(req.Choices, choices{Choice: choice})
+			if k < len(v.Matches) {
+				req.Choices[k].Match = v.Matches[k]
+			}
+		}
+		reqs = append(reqs, req)
+	}
+
+	return reqs
+}
+
+// applyCategories set the topic of the questions which have none from the category of their item,
+// missing subjects and topics are created
+func applyCategories(tx *gorm.DB, items []exchange.Item, reqs []questions) error {
+	for i := range reqs {
+		if reqs[i].TopicID != "" || items[i].Category == "" {
+			continue
+		}
+
+		topicID, err := findOrCreateCategory(tx, items[i].Category)
+		if err != nil {
+			return err
+		}
+		reqs[i].TopicID = topicID.String()
+	}
+
+	return nil
+}
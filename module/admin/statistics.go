@@ -0,0 +1,239 @@
+package admin
+
+import (
+	"net/http"
+	"okkybudiman/analytics"
+	dataModel "okkybudiman/data/model"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// layout of the from and to query parameters
+	dateFormat = "2006-01-02"
+
+	defaultBins = 10
+	maxBins     = 100
+)
+
+// submissionRow a score of the statistics query with the timing of its attempt
+type submissionRow struct {
+	ID         uuid.UUID
+	Score      int
+	CreatedAt  time.Time
+	StartTest  *time.Time
+	EndTest    *time.Time
+	IsFinished *bool
+}
+
+// statisticsFilter filter of the statistics of a test, by cohort of the assigned users and by submission date
+type statisticsFilter struct {
+	testID uuid.UUID
+	cohort string
+	from   *time.Time
+	to     *time.Time
+}
+
+// GetTestStatistics report the score distribution, the completion rate and the time to complete of a test,
+// filtered by cohort and by date range (from and to, inclusive). Distributions are cached and only the
+// submissions made since the last request are read.
+func (ctrl *Controller) GetTestStatistics(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var test dataModel.Test
+	testID, _ := uuid.FromString(c.Param("id"))
+	if err := db.Where("id = ?", testID).First(&test).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Test",
+		})
+		return
+	}
+
+	filter := statisticsFilter{testID: testID, cohort: c.Query("cohort")}
+	var errors []string
+	for _, v := range []struct {
+		name  string
+		value **time.Time
+		days  int
+	}{{"from", &filter.from, 0}, {"to", &filter.to, 1}} {
+		if c.Query(v.name) == "" {
+			continue
+		}
+		t, err := time.Parse(dateFormat, c.Query(v.name))
+		if err != nil {
+			errors = append(errors, v.name+" must be a date formatted as YYYY-MM-DD")
+			continue
+		}
+		t = t.AddDate(0, 0, v.days)
+		*v.value = &t
+	}
+	bins, err := strconv.Atoi(c.DefaultQuery("bins", strconv.Itoa(defaultBins)))
+	if err != nil || bins < 1 || bins > maxBins {
+		errors = append(errors, "bins must be a number between 1 and 100")
+	}
+	if len(errors) > 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": errors})
+		return
+	}
+
+	key := strings.Join([]string{testID.String(), filter.cohort, c.Query("from"), c.Query("to")}, "|")
+	distribution := ctrl.statistics.Get(key)
+	distribution.Lock()
+	//the distribution may be replaced below, the one locked last is unlocked
+	defer func() { distribution.Unlock() }()
+
+	if err := updateDistribution(db, filter, distribution); err != nil {
+		glog.Errorf("Failed to load submissions: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	//submissions deleted since the last request cannot be removed one by one, the distribution is rebuilt
+	var total int
+	submissionQuery(db, filter).Count(&total)
+	if total != distribution.Len() {
+		distribution.Unlock()
+		distribution = ctrl.statistics.Reset(key)
+		distribution.Lock()
+		if err := updateDistribution(db, filter, distribution); err != nil {
+			glog.Errorf("Failed to load submissions: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	var started, finished, assigned int
+	attempts := db.Model(&dataModel.UserAttemptTest{}).Where("user_attempt_tests.test_id = ? AND user_attempt_tests.mode <> ?", testID, dataModel.AttemptModePractice)
+	if filter.cohort != "" {
+		attempts = attempts.Where("user_id IN (?)", cohortUsers(db, filter).QueryExpr())
+	}
+	if filter.from != nil {
+		attempts = attempts.Where("start_test >= ?", *filter.from)
+	}
+	if filter.to != nil {
+		attempts = attempts.Where("start_test < ?", *filter.to)
+	}
+	attempts.Count(&started)
+	attempts.Where("is_finished = ?", true).Count(&finished)
+
+	assignments := db.Model(&dataModel.TestAssignment{}).Where("test_id = ?", testID)
+	if filter.cohort != "" {
+		assignments = assignments.Where("cohort = ?", filter.cohort)
+	}
+	assignments.Count(&assigned)
+
+	completion := gin.H{
+		"assigned":        assigned,
+		"started":         started,
+		"finished":        finished,
+		"completion_rate": ratio(finished, started),
+	}
+	if assigned > 0 {
+		completion["assigned_completion_rate"] = ratio(finished, assigned)
+	}
+
+	//the possible scores go from every question wrong to every question right with all its points
+	var questions []dataModel.Question
+	if err := db.Where("test_id = ?", testID).Preload("QuestionChoices").Preload("TestCases").Find(&questions).Error; err != nil {
+		glog.Errorf("Failed to load questions: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	low, high := float64(len(questions)*dataModel.PointWrong), 0.0
+	for _, v := range questions {
+		high += float64(v.MaxPoint())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "success get test statistics",
+		"data": gin.H{
+			"test_id":          testID,
+			"cohort":           filter.cohort,
+			"score":            distribution.Scores(low, high, bins),
+			"completion":       completion,
+			"time_to_complete": distribution.Durations(bins),
+		},
+	})
+	return
+}
+
+// updateDistribution add the submissions made since the watermark of the distribution
+func updateDistribution(db *gorm.DB, filter statisticsFilter, distribution *analytics.Distribution) error {
+	query := submissionQuery(db, filter).
+		Select("user_scores.id, user_scores.score, user_scores.created_at, " +
+			"user_attempt_tests.start_test, user_attempt_tests.end_test, user_attempt_tests.is_finished")
+	if !distribution.Watermark.IsZero() {
+		//submissions created at the watermark may not be added yet, the ones already added are ignored
+		query = query.Where("user_scores.created_at >= ?", distribution.Watermark)
+	}
+
+	rows, err := query.Order("user_scores.created_at").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row submissionRow
+		if err := db.ScanRows(rows, &row); err != nil {
+			return err
+		}
+
+		var duration *float64
+		if row.IsFinished != nil && *row.IsFinished && row.StartTest != nil && row.EndTest != nil {
+			seconds := row.EndTest.Sub(*row.StartTest).Seconds()
+			duration = &seconds
+		}
+		distribution.Add(row.ID.String(), float64(row.Score), duration, row.CreatedAt)
+	}
+
+	return rows.Err()
+}
+
+// submissionQuery the scores of a test matching the filter joined with their attempt
+func submissionQuery(db *gorm.DB, filter statisticsFilter) *gorm.DB {
+	query := db.Table("user_scores").
+		Joins("LEFT JOIN user_attempt_tests ON user_attempt_tests.id = user_scores.user_attempt_test_id "+
+			"AND user_attempt_tests.deleted_at IS NULL").
+		Where("user_scores.test_id = ? AND user_scores.deleted_at IS NULL", filter.testID)
+
+	if filter.cohort != "" {
+		query = query.Where("user_scores.user_id IN (?)", cohortUsers(db, filter).QueryExpr())
+	}
+	if filter.from != nil {
+		query = query.Where("user_scores.created_at >= ?", *filter.from)
+	}
+	if filter.to != nil {
+		query = query.Where("user_scores.created_at < ?", *filter.to)
+	}
+
+	return query
+}
+
+// cohortUsers the users assigned to the test in the cohort of the filter
+func cohortUsers(db *gorm.DB, filter statisticsFilter) *gorm.DB {
+	return db.Table("test_assignments").Select("user_id").
+		Where("test_id = ? AND cohort = ? AND deleted_at IS NULL", filter.testID, filter.cohort)
+}
+
+func ratio(part int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return float64(part) / float64(total)
+}
@@ -0,0 +1,643 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	dataModel "okkybudiman/data/model"
+	"okkybudiman/exchange"
+	u "okkybudiman/utility"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+var taxonomySortColumns = map[string]string{
+	"name":       "name",
+	"created_at": "created_at",
+}
+
+func (ctrl *Controller) CreateSubject(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var req subjectRequest
+	var subject dataModel.Subject
+	if !u.BindJSON(c, &req) {
+		return
+	}
+
+	if err := db.Where("name = ?", req.Name).First(&subject).Error; err == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusOK,
+			"message": "subject name already exist",
+		})
+		return
+	}
+
+	subject = dataModel.Subject{
+		Name:        req.Name,
+		Description: req.Description,
+	}
+	db.Save(&subject)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  http.StatusCreated,
+		"message": "success create subject",
+		"data":    subjectResponse{ID: subject.ID, Name: subject.Name, Description: subject.Description},
+	})
+	return
+}
+
+func (ctrl *Controller) UpdateSubject(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var req updateSubjectRequest
+	var subject dataModel.Subject
+	if !u.BindJSON(c, &req) {
+		return
+	}
+
+	uid, _ := uuid.FromString(req.SubjectID)
+	if err := db.Where("id = ?", uid).First(&subject).Error; err == nil {
+		subject.Name = req.Name
+		subject.Description = req.Description
+		db.Save(&subject)
+
+		c.JSON(http.StatusCreated, gin.H{
+			"status":  http.StatusCreated,
+			"message": "success update subject",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusNotFound,
+		"message": "cannot find Subject",
+	})
+	return
+}
+
+// DeleteSubject delete a subject, only when none of its topics and tests is left
+func (ctrl *Controller) DeleteSubject(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var req deleteSubjectRequest
+	var subject dataModel.Subject
+	if !u.BindJSON(c, &req) {
+		return
+	}
+
+	uid, _ := uuid.FromString(req.SubjectID)
+	if err := db.Where("id = ?", uid).First(&subject).Error; err == nil {
+		var totalTopic, totalTest int
+		db.Model(&dataModel.Topic{}).Where("subject_id = ?", uid).Count(&totalTopic)
+		db.Model(&dataModel.Test{}).Where("subject_id = ?", uid).Count(&totalTest)
+		if totalTopic > 0 || totalTest > 0 {
+			c.JSON(http.StatusOK, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "subject still has topics or tests. move or delete them first",
+			})
+			return
+		}
+
+		db.Delete(&subject)
+
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusOK,
+			"message": "success delete subject",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusNotFound,
+		"message": "cannot find Subject",
+	})
+	return
+}
+
+func (ctrl *Controller) GetListSubject(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var subjects []dataModel.Subject
+	var responses []subjectResponse
+	var total int
+
+	pagination, err := u.NewPagination(c, taxonomySortColumns, "name")
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	query := db.Model(&dataModel.Subject{})
+	if pagination.Search != "" {
+		query = pagination.SearchWhere(query, "name")
+	}
+	query.Count(&total)
+	pagination.Apply(query).Find(&subjects)
+
+	for _, v := range subjects {
+		res := subjectResponse{
+			ID:          v.ID,
+			Name:        v.Name,
+			Description: v.Description,
+		}
+		db.Model(&dataModel.Topic{}).Where("subject_id = ?", v.ID).Count(&res.TotalTopic)
+		responses = append(responses, res)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     http.StatusOK,
+		"message":    "success get list subject",
+		"data":       responses,
+		"total":      total,
+		"pagination": pagination.Meta(total),
+	})
+	return
+}
+
+func (ctrl *Controller) CreateTopic(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var req topicRequest
+	var subject dataModel.Subject
+	if !u.BindJSON(c, &req) {
+		return
+	}
+
+	subjectID, _ := uuid.FromString(req.SubjectID)
+	if err := db.Where("id = ?", subjectID).First(&subject).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Subject",
+		})
+		return
+	}
+
+	topic := dataModel.Topic{
+		Name:        req.Name,
+		Description: req.Description,
+		SubjectID:   subjectID,
+	}
+	db.Save(&topic)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  http.StatusCreated,
+		"message": "success create topic",
+		"data": topicResponse{
+			ID:          topic.ID,
+			SubjectID:   subject.ID,
+			Subject:     subject.Name,
+			Name:        topic.Name,
+			Description: topic.Description,
+		},
+	})
+	return
+}
+
+func (ctrl *Controller) UpdateTopic(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var req updateTopicRequest
+	var topic dataModel.Topic
+	var subject dataModel.Subject
+	if !u.BindJSON(c, &req) {
+		return
+	}
+
+	subjectID, _ := uuid.FromString(req.SubjectID)
+	if err := db.Where("id = ?", subjectID).First(&subject).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Subject",
+		})
+		return
+	}
+
+	uid, _ := uuid.FromString(req.TopicID)
+	if err := db.Where("id = ?", uid).First(&topic).Error; err == nil {
+		topic.Name = req.Name
+		topic.Description = req.Description
+		topic.SubjectID = subjectID
+		db.Save(&topic)
+
+		c.JSON(http.StatusCreated, gin.H{
+			"status":  http.StatusCreated,
+			"message": "success update topic",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusNotFound,
+		"message": "cannot find Topic",
+	})
+	return
+}
+
+// DeleteTopic delete a topic, its questions are kept without topic
+func (ctrl *Controller) DeleteTopic(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var req deleteTopicRequest
+	var topic dataModel.Topic
+	if !u.BindJSON(c, &req) {
+		return
+	}
+
+	uid, _ := uuid.FromString(req.TopicID)
+	if err := db.Where("id = ?", uid).First(&topic).Error; err == nil {
+		tx := db.Begin()
+		tx.Model(&dataModel.Question{}).Where("topic_id = ?", uid).Update("topic_id", gorm.Expr("NULL"))
+		tx.Delete(&topic)
+		if err := tx.Commit().Error; err != nil {
+			glog.Errorf("Failed to delete topic: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusOK,
+			"message": "success delete topic",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusNotFound,
+		"message": "cannot find Topic",
+	})
+	return
+}
+
+// GetListTopic list topics with their total questions, so question banks can be grouped by topic
+func (ctrl *Controller) GetListTopic(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var topics []dataModel.Topic
+	var responses []topicResponse
+	var total int
+
+	pagination, err := u.NewPagination(c, taxonomySortColumns, "name")
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	query := db.Model(&dataModel.Topic{})
+	if subjectID := c.Query("subject_id"); subjectID != "" {
+		query = query.Where("subject_id = ?", subjectID)
+	}
+	if pagination.Search != "" {
+		query = pagination.SearchWhere(query, "name")
+	}
+	query.Count(&total)
+	pagination.Apply(query).Preload("Subject").Find(&topics)
+
+	for _, v := range topics {
+		res := topicResponse{
+			ID:          v.ID,
+			SubjectID:   v.SubjectID,
+			Subject:     v.Subject.Name,
+			Name:        v.Name,
+			Description: v.Description,
+		}
+		db.Model(&dataModel.Question{}).Where("topic_id = ?", v.ID).Count(&res.TotalQuestion)
+		responses = append(responses, res)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     http.StatusOK,
+		"message":    "success get list topic",
+		"data":       responses,
+		"total":      total,
+		"pagination": pagination.Meta(total),
+	})
+	return
+}
+
+func (ctrl *Controller) CreateTag(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var req tagRequest
+	if !u.BindJSON(c, &req) {
+		return
+	}
+
+	tags, err := findOrCreateTags(db, []string{req.Name})
+	if err != nil || len(tags) == 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"name is required"}})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  http.StatusCreated,
+		"message": "success create tag",
+		"data":    tagResponse{ID: tags[0].ID, Name: tags[0].Name},
+	})
+	return
+}
+
+func (ctrl *Controller) UpdateTag(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var req updateTagRequest
+	var tag dataModel.Tag
+	var existing dataModel.Tag
+	if !u.BindJSON(c, &req) {
+		return
+	}
+
+	name := normalizeTag(req.Name)
+	uid, _ := uuid.FromString(req.TagID)
+	if err := db.Where("id = ?", uid).First(&tag).Error; err == nil {
+		if err := db.Where("name = ? AND id <> ?", name, uid).First(&existing).Error; err == nil {
+			c.JSON(http.StatusOK, gin.H{
+				"status":  http.StatusOK,
+				"message": "tag name already exist",
+			})
+			return
+		}
+
+		tag.Name = name
+		db.Save(&tag)
+
+		c.JSON(http.StatusCreated, gin.H{
+			"status":  http.StatusCreated,
+			"message": "success update tag",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusNotFound,
+		"message": "cannot find Tag",
+	})
+	return
+}
+
+// DeleteTag delete a tag and remove it from the tests and questions using it
+func (ctrl *Controller) DeleteTag(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var req deleteTagRequest
+	var tag dataModel.Tag
+	if !u.BindJSON(c, &req) {
+		return
+	}
+
+	uid, _ := uuid.FromString(req.TagID)
+	if err := db.Where("id = ?", uid).First(&tag).Error; err == nil {
+		tx := db.Begin()
+		tx.Exec("DELETE FROM test_tags WHERE tag_id = ?", uid)
+		tx.Exec("DELETE FROM question_tags WHERE tag_id = ?", uid)
+		tx.Delete(&tag)
+		if err := tx.Commit().Error; err != nil {
+			glog.Errorf("Failed to delete tag: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusOK,
+			"message": "success delete tag",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusNotFound,
+		"message": "cannot find Tag",
+	})
+	return
+}
+
+func (ctrl *Controller) GetListTag(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+
+	var tags []dataModel.Tag
+	var responses []tagResponse
+	var total int
+
+	pagination, err := u.NewPagination(c, taxonomySortColumns, "name")
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+		return
+	}
+
+	query := db.Model(&dataModel.Tag{})
+	if pagination.Search != "" {
+		query = pagination.SearchWhere(query, "name")
+	}
+	query.Count(&total)
+	pagination.Apply(query).Find(&tags)
+
+	for _, v := range tags {
+		res := tagResponse{
+			ID:   v.ID,
+			Name: v.Name,
+		}
+		db.Table("test_tags").Where("tag_id = ?", v.ID).Count(&res.TotalTest)
+		db.Table("question_tags").Where("tag_id = ?", v.ID).Count(&res.TotalQuestion)
+		responses = append(responses, res)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     http.StatusOK,
+		"message":    "success get list tag",
+		"data":       responses,
+		"total":      total,
+		"pagination": pagination.Meta(total),
+	})
+	return
+}
+
+// normalizeTag tags are stored trimmed and lower cased
+func normalizeTag(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// findOrCreateTags get the tags by name, the missing ones are created
+func findOrCreateTags(db *gorm.DB, names []string) ([]dataModel.Tag, error) {
+	var tags []dataModel.Tag
+	seen := make(map[string]bool)
+
+	for _, v := range names {
+		name := normalizeTag(v)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		var tag dataModel.Tag
+		if err := db.Where(dataModel.Tag{Name: name}).FirstOrCreate(&tag).Error; err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+// resolveSubject get the optional subject id of a request, false is returned when the subject does not exist
+func resolveSubject(db *gorm.DB, id string) (*uuid.UUID, bool) {
+	if id == "" {
+		return nil, true
+	}
+
+	var subject dataModel.Subject
+	uid, _ := uuid.FromString(id)
+	if err := db.Where("id = ?", uid).First(&subject).Error; err != nil {
+		return nil, false
+	}
+
+	return &subject.ID, true
+}
+
+// resolveTopic get the optional topic id of a request, false is returned when the topic does not exist
+func resolveTopic(db *gorm.DB, id string) (*uuid.UUID, bool) {
+	if id == "" {
+		return nil, true
+	}
+
+	var topic dataModel.Topic
+	uid, _ := uuid.FromString(id)
+	if err := db.Where("id = ?", uid).First(&topic).Error; err != nil {
+		return nil, false
+	}
+
+	return &topic.ID, true
+}
+
+func tagNames(tags []dataModel.Tag) []string {
+	names := []string{}
+	for _, v := range tags {
+		names = append(names, v.Name)
+	}
+
+	return names
+}
+
+// findOrCreateCategory get the topic of a category, the first name of the category is the subject and the last one the topic.
+// A category with a single name is both the subject and the topic, missing subjects and topics are created.
+func findOrCreateCategory(db *gorm.DB, category string) (uuid.UUID, error) {
+	names := exchange.CategoryNames(category)
+	if len(names) == 0 {
+		return uuid.Nil, fmt.Errorf("category is empty")
+	}
+
+	var subject dataModel.Subject
+	if err := db.Where(dataModel.Subject{Name: names[0]}).FirstOrCreate(&subject).Error; err != nil {
+		return uuid.Nil, err
+	}
+
+	var topic dataModel.Topic
+	if err := db.Where(dataModel.Topic{Name: names[len(names)-1], SubjectID: subject.ID}).FirstOrCreate(&topic).Error; err != nil {
+		return uuid.Nil, err
+	}
+
+	return topic.ID, nil
+}
+
+// topicCategories the category of the topics of the questions by topic id
+func topicCategories(db *gorm.DB, questions []dataModel.Question) (map[uuid.UUID]string, error) {
+	var ids []uuid.UUID
+	for _, v := range questions {
+		if v.TopicID != nil {
+			ids = append(ids, *v.TopicID)
+		}
+	}
+
+	categories := make(map[uuid.UUID]string)
+	if len(ids) == 0 {
+		return categories, nil
+	}
+
+	var topics []dataModel.Topic
+	if err := db.Where("id IN (?)", ids).Preload("Subject").Find(&topics).Error; err != nil {
+		return nil, err
+	}
+	for _, v := range topics {
+		if v.Subject.Name == v.Name {
+			categories[v.ID] = exchange.JoinCategory(v.Name)
+		} else {
+			categories[v.ID] = exchange.JoinCategory(v.Subject.Name, v.Name)
+		}
+	}
+
+	return categories, nil
+}
@@ -0,0 +1,126 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	dataModel "okkybudiman/data/model"
+	"okkybudiman/formula"
+	u "okkybudiman/utility"
+
+	"github.com/gin-gonic/gin"
+)
+
+// number of seeds a template is drawn with when it is validated, and bounds of the samples of a preview
+const (
+	templateCheckDraws    = 20
+	defaultPreviewSamples = 5
+	maxPreviewSamples     = 50
+)
+
+// TemplatePreview validate a calculated question and draw samples of it, with the values of the variables,
+// the rendered question and explanation and the answer. Nothing is saved.
+func (ctrl *Controller) TemplatePreview(c *gin.Context) {
+	var req templatePreviewRequest
+	if !u.BindJSON(c, &req) {
+		return
+	}
+
+	format := req.Format
+	if format == "" {
+		format = dataModel.QuestionFormatPlain
+	}
+	if !dataModel.IsValidQuestionFormat(format) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"format must be plain, markdown or markdown_math"}})
+		return
+	}
+
+	if errors := validateTemplate(req.Question, req.Explanation, req.Variables, req.AnswerExpression); len(errors) > 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": errors})
+		return
+	}
+	template, _ := formula.NewTemplate(req.Variables, req.AnswerExpression)
+
+	total := req.Samples
+	if total <= 0 {
+		total = defaultPreviewSamples
+	}
+	if total > maxPreviewSamples {
+		total = maxPreviewSamples
+	}
+
+	question := dataModel.Question{Format: format}
+	var samples []templateSample
+	for i := 0; i < total; i++ {
+		seed := req.Seed + int64(i)
+		sample, err := template.Draw(seed)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{err.Error()}})
+			return
+		}
+
+		text := template.Render(req.Question, sample)
+		explanation := template.Render(req.Explanation, sample)
+		samples = append(samples, templateSample{
+			Seed:            seed,
+			Values:          sample.Values,
+			Question:        text,
+			QuestionHTML:    u.RenderContent(text, format),
+			Explanation:     explanation,
+			ExplanationHTML: u.RenderContent(explanation, question.ExplanationFormat()),
+			Answer:          formula.Format(sample.Answer),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "success preview template",
+		"data":    samples,
+	})
+	return
+}
+
+// validateTemplate check the variables, the answer expression and the placeholders of a calculated question,
+// and that its answer can be computed from the values drawn for a few seeds
+func validateTemplate(question string, explanation string, variables []formula.Variable, answerExpression string) []string {
+	template, err := formula.NewTemplate(variables, answerExpression)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var errors []string
+	if err := template.CheckText(question); err != nil {
+		errors = append(errors, fmt.Sprintf("question: %s", err))
+	}
+	if err := template.CheckText(explanation); err != nil {
+		errors = append(errors, fmt.Sprintf("explanation: %s", err))
+	}
+	for seed := int64(0); seed < templateCheckDraws; seed++ {
+		if _, err := template.Draw(seed); err != nil {
+			errors = append(errors, err.Error())
+			break
+		}
+	}
+
+	return errors
+}
+
+// questionVariables the variables of a calculated question
+func questionVariables(q dataModel.Question) []formula.Variable {
+	var variables []formula.Variable
+	if q.Variables != "" {
+		json.Unmarshal([]byte(q.Variables), &variables)
+	}
+
+	return variables
+}
+
+// encodeVariables encode the variables of a calculated question to be saved
+func encodeVariables(variables []formula.Variable) string {
+	if len(variables) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(variables)
+
+	return string(data)
+}
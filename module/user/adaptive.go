@@ -0,0 +1,333 @@
+package user
+
+import (
+	"net/http"
+	"okkybudiman/analytics"
+	dataModel "okkybudiman/data/model"
+	u "okkybudiman/utility"
+	"sort"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+// adaptive attempts of tests without target stop once the standard error is at most defaultTargetStandardError
+const defaultTargetStandardError = 0.3
+
+// adaptiveState progress of an adaptive attempt
+type adaptiveState struct {
+	ability  analytics.Ability
+	answered int
+	finished bool
+	next     *dataModel.Question
+}
+
+// AdaptiveNext get the next question of an adaptive attempt, the calibrated question of the test giving the most
+// information at the current ability estimate. The attempt is finished when the estimate is precise enough, when
+// total_question questions are answered or when every calibrated question is answered.
+func (ctrl *Controller) AdaptiveNext(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+	claims := jwt.ExtractClaims(c)
+	name := claims["id"].(string)
+	var user dataModel.User
+	db.Where("name = ?", name).Find(&user)
+
+	attempt, test, ok := adaptiveAttempt(c, db, user.ID, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	state, err := loadAdaptiveState(db, attempt, test)
+	if err != nil {
+		glog.Errorf("Failed to load adaptive attempt: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if state.finished && !attempt.IsFinished {
+		if err := finishAdaptive(db, &attempt, state); err != nil {
+			glog.Errorf("Failed to finish adaptive attempt: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "success get next question",
+		"data":    newAdaptiveResponse(attempt, state),
+	})
+	return
+}
+
+// AdaptiveAnswer answer the current question of an adaptive attempt, it must be the question given by AdaptiveNext.
+// The answer is saved as an answer of the test and the ability is estimated again, the correctness is not shown.
+func (ctrl *Controller) AdaptiveAnswer(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+	claims := jwt.ExtractClaims(c)
+	name := claims["id"].(string)
+	var user dataModel.User
+	db.Where("name = ?", name).Find(&user)
+
+	var req attemptAnswerRequest
+	if !u.BindJSON(c, &req) {
+		return
+	}
+
+	attempt, test, ok := adaptiveAttempt(c, db, user.ID, req.AttemptID)
+	if !ok {
+		return
+	}
+
+	state, err := loadAdaptiveState(db, attempt, test)
+	if err != nil {
+		glog.Errorf("Failed to load adaptive attempt: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if attempt.IsFinished || state.finished {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  http.StatusForbidden,
+			"message": "attempt is finished",
+		})
+		return
+	}
+	if state.next.ID.String() != req.QuestionID {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  http.StatusForbidden,
+			"message": "question is not the next question of the attempt",
+		})
+		return
+	}
+
+	data := answerData{
+		QuestionID: req.QuestionID,
+		ChoiceID:   req.ChoiceID,
+		ChoiceIDs:  req.ChoiceIDs,
+		Answer:     req.Answer,
+		Matches:    req.Matches,
+		Blanks:     req.Blanks,
+	}
+	status, point := gradeAnswer(*state.next, state.next.QuestionChoices, data)
+	answer := dataModel.UserAnswer{
+		UserID:     user.ID,
+		TestID:     attempt.TestID,
+		QuestionID: state.next.ID,
+		Point:      point,
+		Response:   answerResponse(*state.next, data),
+
+		UserAttemptTestID: attempt.ID,
+	}
+	if state.next.Type == dataModel.QuestionTypeSingleChoice && req.ChoiceID != "" {
+		answer.QuestionChoiceID, _ = uuid.FromString(req.ChoiceID)
+	}
+	if err := db.Save(&answer).Error; err != nil {
+		glog.Errorf("Failed to save answer: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if status == answerWrong {
+		if err := addReviewItem(db, user.ID, answer.QuestionID, time.Now()); err != nil {
+			glog.Errorf("Failed to add review item: %s", err)
+		}
+	}
+
+	state, err = loadAdaptiveState(db, attempt, test)
+	if err != nil {
+		glog.Errorf("Failed to load adaptive attempt: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if state.finished {
+		if err := finishAdaptive(db, &attempt, state); err != nil {
+			glog.Errorf("Failed to finish adaptive attempt: %s", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  http.StatusCreated,
+		"message": "success save answer",
+		"data":    newAdaptiveResponse(attempt, state),
+	})
+	return
+}
+
+// adaptiveAttempt load an adaptive attempt of the user with its test, the request is aborted when it does not exist
+func adaptiveAttempt(c *gin.Context, db *gorm.DB, userID uuid.UUID, id string) (dataModel.UserAttemptTest, dataModel.Test, bool) {
+	var attempt dataModel.UserAttemptTest
+	var test dataModel.Test
+
+	attemptID, _ := uuid.FromString(id)
+	if err := db.Where("id = ? AND user_id = ? AND mode = ?", attemptID, userID, dataModel.AttemptModeAdaptive).First(&attempt).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find adaptive attempt",
+		})
+		return attempt, test, false
+	}
+	if err := db.Where("id = ?", attempt.TestID).First(&test).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Test",
+		})
+		return attempt, test, false
+	}
+
+	return attempt, test, true
+}
+
+// loadAdaptiveState estimate the ability from the answers of the attempt and choose its next question.
+// Answers which are not right count as incorrect.
+func loadAdaptiveState(db *gorm.DB, attempt dataModel.UserAttemptTest, test dataModel.Test) (adaptiveState, error) {
+	var state adaptiveState
+	var questions []dataModel.Question
+	var answers []dataModel.UserAnswer
+
+	//programming answers are graded asynchronously, the next question cannot wait for them
+	err := db.Where("test_id = ? AND irt_difficulty IS NOT NULL AND type <> ?", test.ID, dataModel.QuestionTypeProgramming).Preload("QuestionChoices").
+		Order("created_at").Find(&questions).Error
+	if err != nil {
+		return state, err
+	}
+	if err := db.Where("user_attempt_test_id = ?", attempt.ID).Order("created_at").Find(&answers).Error; err != nil {
+		return state, err
+	}
+
+	calibrated := make(map[uuid.UUID]analytics.ItemParams)
+	for _, v := range questions {
+		calibrated[v.ID] = questionParams(v)
+	}
+
+	var items []analytics.ItemParams
+	var correct []bool
+	answered := make(map[uuid.UUID]bool)
+	for _, v := range answers {
+		params, ok := calibrated[v.QuestionID]
+		if !ok || answered[v.QuestionID] {
+			continue
+		}
+		answered[v.QuestionID] = true
+		items = append(items, params)
+		correct = append(correct, v.Point > 0)
+	}
+	state.ability = analytics.EstimateAbility(items, correct)
+	state.answered = len(items)
+
+	var remaining []dataModel.Question
+	var params []analytics.ItemParams
+	for _, v := range questions {
+		if !answered[v.ID] {
+			remaining = append(remaining, v)
+			params = append(params, calibrated[v.ID])
+		}
+	}
+
+	target := test.TargetStandardError
+	if target <= 0 {
+		target = defaultTargetStandardError
+	}
+	state.finished = len(remaining) == 0 ||
+		(state.answered > 0 && state.ability.StandardError <= target) ||
+		(test.TotalQuestion > 0 && state.answered >= test.TotalQuestion)
+	if !state.finished {
+		next := instantiateQuestion(remaining[analytics.MostInformative(state.ability.Theta, params)], attempt.Seed)
+		sort.Slice(next.QuestionChoices, func(i, j int) bool { return next.QuestionChoices[i].Key < next.QuestionChoices[j].Key })
+		state.next = &next
+	}
+
+	return state, nil
+}
+
+// finishAdaptive save the score of a finished adaptive attempt with its ability estimate
+func finishAdaptive(db *gorm.DB, attempt *dataModel.UserAttemptTest, state adaptiveState) error {
+	var answers []dataModel.UserAnswer
+	if err := db.Where("user_attempt_test_id = ?", attempt.ID).Find(&answers).Error; err != nil {
+		return err
+	}
+
+	theta := state.ability.Theta
+	standardError := state.ability.StandardError
+	score := dataModel.UserScore{
+		UserID:            attempt.UserID,
+		TestID:            attempt.TestID,
+		UserAttemptTestID: attempt.ID,
+		Theta:             &theta,
+		StandardError:     &standardError,
+	}
+	for _, v := range answers {
+		switch pointStatus(v.Point) {
+		case answerRight:
+			score.TotalRightAnswered++
+		case answerWrong:
+			score.TotalWrongAnswered++
+		default:
+			score.TotalNotAnswered++
+		}
+		score.Score += v.Point
+	}
+
+	now := time.Now()
+	attempt.IsFinished = true
+	attempt.EndTest = now
+	attempt.FinishTime = time.Time{}.Add(now.Sub(attempt.StartTest)).Format("15:04:05")
+
+	tx := db.Begin()
+	if err := tx.Save(&score).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Save(attempt).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// questionParams item parameters of a calibrated question
+func questionParams(q dataModel.Question) analytics.ItemParams {
+	params := analytics.ItemParams{Discrimination: 1, Calibrated: true}
+	if q.IrtDiscrimination != nil {
+		params.Discrimination = *q.IrtDiscrimination
+	}
+	if q.IrtDifficulty != nil {
+		params.Difficulty = *q.IrtDifficulty
+	}
+
+	return params
+}
+
+func newAdaptiveResponse(attempt dataModel.UserAttemptTest, state adaptiveState) adaptiveResponse {
+	res := adaptiveResponse{
+		AttemptID: attempt.ID,
+		Finished:  attempt.IsFinished || state.finished,
+		Answered:  state.answered,
+	}
+	if res.Finished {
+		res.Theta = &state.ability.Theta
+		res.StandardError = &state.ability.StandardError
+	}
+	if state.next != nil && !res.Finished {
+		question := newServedQuestion(*state.next, attempt.Seed)
+		res.Question = &question
+	}
+
+	return res
+}
@@ -6,12 +6,14 @@ import (
 	"net/http"
 	"okkybudiman/data"
 	dataModel "okkybudiman/data/model"
+	u "okkybudiman/utility"
 	"time"
 
 	jwt "github.com/appleboy/gin-jwt"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/golang/glog"
+	"github.com/jinzhu/gorm"
 	uuid "github.com/satori/go.uuid"
 	validator "gopkg.in/go-playground/validator.v8"
 )
@@ -59,22 +61,122 @@ func (ctrl *Controller) AttempTest(c *gin.Context) {
 		return
 	}
 	testID, _ := uuid.FromString(req.TestID)
+	mode := req.Mode
+	if mode == "" {
+		mode = dataModel.AttemptModeOfficial
+	}
+	if mode != dataModel.AttemptModeOfficial && mode != dataModel.AttemptModePractice && mode != dataModel.AttemptModeAdaptive {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"mode must be official, practice or adaptive"}})
+		return
+	}
 
 	//set timezone,
 	now := time.Now()
+
+	var test dataModel.Test
+	found := db.Where("id = ?", testID).First(&test).Error == nil
+	if found && mode == dataModel.AttemptModePractice && !test.AllowPractice {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  http.StatusForbidden,
+			"message": "test cannot be practiced",
+		})
+		return
+	}
+	//practice and adaptive attempts must not tell the answers of an official attempt in progress
+	if found && mode != dataModel.AttemptModeOfficial && officialInProgress(db, testID, userId) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  http.StatusForbidden,
+			"message": "official attempt of the test is not finished",
+		})
+		return
+	}
+	if found && mode != dataModel.AttemptModePractice {
+		if test.AvailableUntil != nil && now.After(*test.AvailableUntil) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"status":  http.StatusForbidden,
+				"message": "test is closed",
+			})
+			return
+		}
+
+		//practice attempts do not count against the limit
+		var count int
+		db.Model(&dataModel.UserAttemptTest{}).Where("test_id = ? AND user_id = ? AND mode <> ?", testID, userId, dataModel.AttemptModePractice).Count(&count)
+		if test.MaxAttempts > 0 && count >= test.MaxAttempts {
+			c.JSON(http.StatusForbidden, gin.H{
+				"status":  http.StatusForbidden,
+				"message": "attempt limit is reached",
+			})
+			return
+		}
+
+		//every question of a test with sections is served in its section
+		if mode == dataModel.AttemptModeOfficial {
+			var sections, unsectioned int
+			db.Model(&dataModel.Section{}).Where("test_id = ?", testID).Count(&sections)
+			db.Model(&dataModel.Question{}).Where("test_id = ? AND section_id IS NULL", testID).Count(&unsectioned)
+			if sections > 0 && unsectioned > 0 {
+				c.JSON(http.StatusForbidden, gin.H{
+					"status":  http.StatusForbidden,
+					"message": "test has questions without section",
+				})
+				return
+			}
+		}
+
+		if mode == dataModel.AttemptModeAdaptive {
+			db.Model(&dataModel.Question{}).Where("test_id = ? AND irt_difficulty IS NOT NULL AND type <> ?", testID, dataModel.QuestionTypeProgramming).Count(&count)
+			if count == 0 {
+				c.JSON(http.StatusForbidden, gin.H{
+					"status":  http.StatusForbidden,
+					"message": "test has no calibrated question",
+				})
+				return
+			}
+		}
+	}
 	attemptTest := dataModel.UserAttemptTest{
 		UserID:     userId,
 		TestID:     testID,
 		IsFinished: false,
 		StartTest:  now,
 		EndTest:    now,
+		Mode:       mode,
+		Seed:       newSeed(),
+	}
+
+	//the test is locked while the attempt is saved so its questions are not replaced in between, see BulkQuestion
+	tx := db.Begin()
+	u.ForUpdate(tx).Where("id = ?", testID).First(&dataModel.Test{})
+	if err := tx.Save(&attemptTest).Error; err != nil {
+		tx.Rollback()
+		glog.Errorf("Failed to save attempt: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		glog.Errorf("Failed to commit attempt: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
 	}
 
-	db.Save(&attemptTest)
+	//the first section starts with the attempt
+	state, err := loadSectionState(db, &attemptTest, now)
+	if err != nil {
+		glog.Errorf("Failed to start section: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
 
 	c.JSON(http.StatusCreated, gin.H{
 		"status":  http.StatusCreated,
 		"message": "success attempt test",
+		"data": attemptResponse{
+			ID:      attemptTest.ID,
+			TestID:  attemptTest.TestID,
+			Mode:    attemptTest.Mode,
+			Section: newSectionStatus(state, now),
+		},
 	})
 	return
 }
@@ -110,49 +212,96 @@ func (ctrl *Controller) AnswerTest(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": errors})
 		return
 	}
+	if errors := validateCode(req.Answers); len(errors) > 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": errors})
+		return
+	}
 	totalRightAnswer := 0
 	totalWrongAnswer := 0
 	totalNotAnswer := 0
 	calculatePoint := 0
+	var pending []uuid.UUID
 	//save data
 	testID, _ := uuid.FromString(req.TestID)
+	query := db.Where("test_id = ? AND user_id = ? AND mode = ?", testID, userId, dataModel.AttemptModeOfficial)
+	if req.AttemptID != "" {
+		attemptID, _ := uuid.FromString(req.AttemptID)
+		query = query.Where("id = ?", attemptID)
+	} else {
+		//without attempt_id the answers are for the last attempt started
+		query = query.Where("is_finished = ?", false).Order("start_test DESC")
+	}
+	if err := query.First(&userAttempt).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find attempt",
+		})
+		return
+	}
+
 	if err := db.Where("id = ?", testID).Find(&test).Error; err == nil {
-		for _, v := range req.Answers {
-			var question dataModel.Question
-
-			questionID, _ := uuid.FromString(v.QuestionID)
-			var point int
-			db.Where("id = ?", questionID).Find(&question)
-			if question.Answer == v.Answer {
-				point = 4
-				totalRightAnswer = totalRightAnswer + 1
-			} else {
-				point = -2
-				totalWrongAnswer = totalWrongAnswer + 1
-			}
+		//tests with sections are answered section by section, see SubmitSection
+		var sections int
+		db.Model(&dataModel.Section{}).Where("test_id = ?", testID).Count(&sections)
+		if sections > 0 {
+			c.JSON(http.StatusForbidden, gin.H{
+				"status":  http.StatusForbidden,
+				"message": "answers of a test with sections are submitted section by section",
+			})
+			return
+		}
+	}
 
-			if v.Answer == "" {
-				point = 0
-				totalNotAnswer = totalNotAnswer + 1
-			}
+	//the attempt is finished before its answers are saved so answers sent twice are not both saved
+	t2 := time.Now()
+	res := db.Model(&dataModel.UserAttemptTest{}).Where("id = ? AND is_finished = ?", userAttempt.ID, false).
+		Updates(map[string]interface{}{"is_finished": true, "end_test": t2})
+	if res.Error != nil {
+		glog.Errorf("Failed to finish attempt: %s", res.Error)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  http.StatusForbidden,
+			"message": "attempt is already finished",
+		})
+		return
+	}
 
-			calculatePoint = calculatePoint + point
+	for _, v := range req.Answers {
+		var question dataModel.Question
+		var choices []dataModel.QuestionChoice
 
-			answer := dataModel.UserAnswer{
-				UserID:     userId,
-				TestID:     testID,
-				QuestionID: questionID,
-				Answer:     v.Answer,
-				Point:      point,
-			}
+		questionID, _ := uuid.FromString(v.QuestionID)
+		db.Where("id = ?", questionID).Find(&question)
+		db.Where("question_id = ?", question.ID).Find(&choices)
+		//answers to unknown questions are still saved with the id sent
+		question.ID = questionID
+
+		status, answer, err := saveAnswer(db, userId, testID, userAttempt, question, choices, v)
+		if err != nil {
+			glog.Errorf("Failed to save answer: %s", err)
+		}
+		switch status {
+		case answerRight:
+			totalRightAnswer = totalRightAnswer + 1
+		case answerWrong:
+			totalWrongAnswer = totalWrongAnswer + 1
+		default:
+			totalNotAnswer = totalNotAnswer + 1
+		}
 
-			db.Save(&answer)
+		calculatePoint = calculatePoint + answer.Point
+		if status == answerPending {
+			pending = append(pending, answer.ID)
 		}
 	}
 	//update score
 	score := dataModel.UserScore{
 		UserID:             userId,
 		TestID:             testID,
+		UserAttemptTestID:  userAttempt.ID,
 		TotalRightAnswered: totalRightAnswer,
 		TotalWrongAnswered: totalWrongAnswer,
 		TotalNotAnswered:   totalNotAnswer,
@@ -161,9 +310,7 @@ func (ctrl *Controller) AnswerTest(c *gin.Context) {
 
 	db.Save(&score)
 	//update tb user_attempt_test
-	db.Where("test_id = ? AND user_id = ?", testID, userId).Find(&userAttempt)
 	t1 := userAttempt.StartTest
-	t2 := time.Now()
 
 	diff := t2.Sub(t1)
 	out := time.Time{}.Add(diff)
@@ -174,13 +321,54 @@ func (ctrl *Controller) AnswerTest(c *gin.Context) {
 
 	db.Save(&userAttempt)
 
+	//the code is graded once the score exists, the grader updates it with the points of the test cases
+	for _, v := range pending {
+		if err := db.Create(&dataModel.GradingJob{UserAnswerID: v, Status: dataModel.GradingJobQueued}).Error; err != nil {
+			glog.Errorf("Failed to queue grading of answer %s: %s", v, err)
+		}
+	}
+
 	c.JSON(http.StatusCreated, gin.H{
 		"status":  http.StatusCreated,
 		"message": "success save data",
+		"pending": len(pending),
 	})
 	return
 }
 
+// saveAnswer grade the answer of a question of an attempt and save it, choices are all the choices of the question.
+// Calculated questions are graded with the values of the attempt and wrong answers are added to the review deck.
+// It returns the status of the answer and the saved answer.
+func saveAnswer(db *gorm.DB, userID uuid.UUID, testID uuid.UUID, attempt dataModel.UserAttemptTest, question dataModel.Question,
+	choices []dataModel.QuestionChoice, data answerData) (string, dataModel.UserAnswer, error) {
+	question = instantiateQuestion(question, attempt.Seed)
+
+	status, point := gradeAnswer(question, choices, data)
+	if status == answerWrong {
+		if err := addReviewItem(db, userID, question.ID, time.Now()); err != nil {
+			glog.Errorf("Failed to add review item: %s", err)
+		}
+	}
+
+	answer := dataModel.UserAnswer{
+		UserID:     userID,
+		TestID:     testID,
+		QuestionID: question.ID,
+		Point:      point,
+		Response:   answerResponse(question, data),
+
+		UserAttemptTestID: attempt.ID,
+	}
+	if question.Type == dataModel.QuestionTypeSingleChoice && data.ChoiceID != "" {
+		answer.QuestionChoiceID, _ = uuid.FromString(data.ChoiceID)
+	}
+	if status == answerPending {
+		answer.GradingStatus = dataModel.GradingPending
+	}
+
+	return status, answer, db.Save(&answer).Error
+}
+
 func (ctrl *Controller) Result(c *gin.Context) {
 	db, err := ctrl.dbFactory.DBConnection()
 	if err != nil {
@@ -196,12 +384,9 @@ func (ctrl *Controller) Result(c *gin.Context) {
 	db.Where("name = ?", name).Find(&user)
 	userId := user.ID
 
-	var userScore dataModel.UserScore
-	var userAttempt dataModel.UserAttemptTest
 	id := c.Param("id")
 	testID, _ := uuid.FromString(id)
-	if err := db.Where("test_id = ? AND user_id = ?", testID, userId).Find(&userScore).Error; err == nil {
-		db.Where("test_id = ? AND user_id = ?", testID, userId).Find(&userAttempt)
+	if userAttempt, userScore, err := latestScore(db, testID, userId); err == nil {
 		data := result{
 			ID:                 userScore.ID,
 			UserID:             userScore.UserID,
@@ -211,6 +396,23 @@ func (ctrl *Controller) Result(c *gin.Context) {
 			TotalNotAnswered:   userScore.TotalNotAnswered,
 			Score:              userScore.Score,
 			TimeComplete:       userAttempt.FinishTime,
+			Theta:              userScore.Theta,
+			StandardError:      userScore.StandardError,
+		}
+
+		rankings, _, err := testRanking(db, testID)
+		if err != nil {
+			glog.Errorf("Failed to get ranking: %s", err)
+		}
+		for _, v := range rankings {
+			if v.ID == userId.String() {
+				data.Position = v.Position
+				data.PercentileRank = v.PercentileRank
+			}
+		}
+		data.TotalParticipant = len(rankings)
+		if data.Sections, err = sectionScores(db, testID, userAttempt.ID); err != nil {
+			glog.Errorf("Failed to get section scores: %s", err)
 		}
 
 		c.JSON(http.StatusOK, gin.H{
@@ -227,3 +429,29 @@ func (ctrl *Controller) Result(c *gin.Context) {
 		"result":  nil,
 	})
 }
+
+// officialInProgress check whether the user has an official attempt of the test which is not finished
+func officialInProgress(db *gorm.DB, testID uuid.UUID, userID uuid.UUID) bool {
+	var count int
+	db.Model(&dataModel.UserAttemptTest{}).
+		Where("test_id = ? AND user_id = ? AND mode = ? AND is_finished = ?", testID, userID, dataModel.AttemptModeOfficial, false).
+		Count(&count)
+
+	return count > 0
+}
+
+// latestScore the last finished attempt of a user on a test which is not a practice, with its score.
+// The results and the review of a test are the ones of this attempt.
+func latestScore(db *gorm.DB, testID uuid.UUID, userID uuid.UUID) (dataModel.UserAttemptTest, dataModel.UserScore, error) {
+	var attempt dataModel.UserAttemptTest
+	var score dataModel.UserScore
+
+	err := db.Where("test_id = ? AND user_id = ? AND mode <> ? AND is_finished = ?", testID, userID, dataModel.AttemptModePractice, true).
+		Order("end_test DESC").First(&attempt).Error
+	if err != nil {
+		return attempt, score, err
+	}
+	err = db.Where("user_attempt_test_id = ?", attempt.ID).First(&score).Error
+
+	return attempt, score, err
+}
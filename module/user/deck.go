@@ -0,0 +1,323 @@
+package user
+
+import (
+	"net/http"
+	dataModel "okkybudiman/data/model"
+	"okkybudiman/srs"
+	u "okkybudiman/utility"
+	"sort"
+	"strconv"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	defaultDueSize    = 20
+	maxDueSize        = 100
+	defaultStatsDays  = 30
+	maxStatsDays      = 365
+	matureIntervalDay = 21
+)
+
+// DueReviews list the review items of the user due today, the oldest first. Wrongly answered questions which are
+// not in the deck yet are added first. The answer is not sent, it is shown once the recall is recorded.
+func (ctrl *Controller) DueReviews(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+	claims := jwt.ExtractClaims(c)
+	name := claims["id"].(string)
+	var user dataModel.User
+	db.Where("name = ?", name).Find(&user)
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultDueSize)))
+	if err != nil || limit < 1 || limit > maxDueSize {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"limit must be a number between 1 and 100"}})
+		return
+	}
+
+	now := time.Now()
+	if err := syncReviewItems(db, user.ID, now); err != nil {
+		glog.Errorf("Failed to add review items: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var items []dataModel.ReviewItem
+	var total int
+	query := dueItems(db, user.ID, now)
+	query.Count(&total)
+	if err := query.Preload("Question").Preload("Question.QuestionChoices").Preload("Question.TestCases").Order("due_at").Limit(limit).Find(&items).Error; err != nil {
+		glog.Errorf("Failed to get due reviews: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var responses []deckItem
+	for _, v := range items {
+		res := newDeckItem(v)
+		sort.Slice(v.Question.QuestionChoices, func(i, j int) bool {
+			return v.Question.QuestionChoices[i].Key < v.Question.QuestionChoices[j].Key
+		})
+		res.Question = newServedQuestion(instantiateQuestion(v.Question, itemSeed(v.ID)), itemSeed(v.ID))
+		responses = append(responses, res)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"message":   "success get due reviews",
+		"data":      responses,
+		"total_due": total,
+	})
+	return
+}
+
+// RecallReview record how well the user recalled a review item, quality goes from 0 (forgotten) to 5 (perfect),
+// and schedule its next review. The correct answer and the explanation of the question are sent back.
+func (ctrl *Controller) RecallReview(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+	claims := jwt.ExtractClaims(c)
+	name := claims["id"].(string)
+	var user dataModel.User
+	db.Where("name = ?", name).Find(&user)
+
+	var req recallRequest
+	if !u.BindJSON(c, &req) {
+		return
+	}
+	if *req.Quality < srs.QualityMin || *req.Quality > srs.QualityMax {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"quality must be a number between 0 and 5"}})
+		return
+	}
+
+	var item dataModel.ReviewItem
+	id, _ := uuid.FromString(c.Param("id"))
+	if err := db.Where("id = ? AND user_id = ?", id, user.ID).Preload("Question").Preload("Question.QuestionChoices").Preload("Question.TestCases").First(&item).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find review item",
+		})
+		return
+	}
+
+	//the policy of the test may have changed since the item was added
+	now := time.Now()
+	var test dataModel.Test
+	db.Where("id = ?", item.Question.TestID).First(&test)
+	if message, ok := reviewReleased(test, now); !ok {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  http.StatusForbidden,
+			"message": message,
+		})
+		return
+	}
+
+	card := srs.Review(itemCard(item), *req.Quality, now)
+	setItemCard(&item, card)
+	item.LastReviewedAt = &now
+
+	tx := db.Begin()
+	if err := tx.Save(&item).Error; err != nil {
+		tx.Rollback()
+		glog.Errorf("Failed to save review item: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	log := dataModel.ReviewLog{
+		ReviewItemID: item.ID,
+		UserID:       user.ID,
+		QuestionID:   item.QuestionID,
+		Quality:      *req.Quality,
+		IntervalDays: item.IntervalDays,
+		EaseFactor:   item.EaseFactor,
+		ReviewedAt:   now,
+	}
+	if err := tx.Save(&log).Error; err != nil {
+		tx.Rollback()
+		glog.Errorf("Failed to save review log: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		glog.Errorf("Failed to commit review: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	res := newDeckItem(item)
+	answer, _ := newReviewQuestion(0, instantiateQuestion(item.Question, itemSeed(item.ID)))
+	res.Answer = &answer
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "success record recall",
+		"data":    res,
+	})
+	return
+}
+
+// DeckStats count the review items of the user and report the retention, the share of reviews recalled with
+// a passing quality, for each of the last days (default 30)
+func (ctrl *Controller) DeckStats(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+	claims := jwt.ExtractClaims(c)
+	name := claims["id"].(string)
+	var user dataModel.User
+	db.Where("name = ?", name).Find(&user)
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(defaultStatsDays)))
+	if err != nil || days < 1 || days > maxStatsDays {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"days must be a number between 1 and 365"}})
+		return
+	}
+
+	now := time.Now()
+	if err := syncReviewItems(db, user.ID, now); err != nil {
+		glog.Errorf("Failed to add review items: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var stats deckStats
+	items := db.Model(&dataModel.ReviewItem{}).Where("user_id = ?", user.ID)
+	items.Count(&stats.TotalItem)
+	dueItems(db, user.ID, now).Count(&stats.Due)
+	items.Where("repetitions = ?", 0).Count(&stats.New)
+	items.Where("interval_days >= ?", matureIntervalDay).Count(&stats.Mature)
+
+	var logs []dataModel.ReviewLog
+	from := srs.StartOfDay(now).AddDate(0, 0, -days+1)
+	if err := db.Where("user_id = ? AND reviewed_at >= ?", user.ID, from).Find(&logs).Error; err != nil {
+		glog.Errorf("Failed to get review logs: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var recalls []srs.Recall
+	for _, v := range logs {
+		recalls = append(recalls, srs.Recall{Quality: v.Quality, At: v.ReviewedAt})
+		stats.TotalReview++
+		if v.Quality >= srs.QualityPass {
+			stats.TotalRecalled++
+		}
+	}
+	if stats.TotalReview > 0 {
+		stats.Retention = float64(stats.TotalRecalled) / float64(stats.TotalReview)
+	}
+	stats.Days = srs.Retention(recalls, days, now)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "success get review stats",
+		"data":    stats,
+	})
+	return
+}
+
+// addReviewItem add a wrongly answered question to the deck of the user, it is due again right away when it is
+// already in the deck. Questions of tests whose review is not released are left out, syncReviewItems adds them
+// once it is.
+func addReviewItem(db *gorm.DB, userID uuid.UUID, questionID uuid.UUID, now time.Time) error {
+	var item dataModel.ReviewItem
+	var released int
+	if err := db.Model(&dataModel.Question{}).Where("id = ? AND test_id IN (?)", questionID, releasedTests(db, now)).Count(&released).Error; err != nil {
+		return err
+	}
+	if released == 0 {
+		return nil
+	}
+	if err := db.Where("user_id = ? AND question_id = ?", userID, questionID).First(&item).Error; err != nil {
+		item = dataModel.ReviewItem{UserID: userID, QuestionID: questionID}
+		setItemCard(&item, srs.NewCard(now))
+	} else {
+		setItemCard(&item, srs.Lapse(itemCard(item), now))
+	}
+
+	return db.Save(&item).Error
+}
+
+// syncReviewItems add the wrongly answered questions of the user which are not in the deck yet, like the ones
+// answered before the deck existed or before the review of their test was released
+func syncReviewItems(db *gorm.DB, userID uuid.UUID, now time.Time) error {
+	var questionIDs []uuid.UUID
+	err := db.Model(&dataModel.UserAnswer{}).
+		Where("user_id = ? AND point < 0", userID).
+		Where("test_id IN (?)", releasedTests(db, now)).
+		Where("question_id NOT IN (?)", db.Table("review_items").Select("question_id").
+			Where("user_id = ? AND deleted_at IS NULL", userID).QueryExpr()).
+		Pluck("DISTINCT question_id", &questionIDs).Error
+	if err != nil {
+		return err
+	}
+
+	for _, v := range questionIDs {
+		item := dataModel.ReviewItem{UserID: userID, QuestionID: v}
+		setItemCard(&item, srs.NewCard(now))
+		if err := db.Save(&item).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dueItems the review items of the user due by the end of the day of now, whose question still exists and whose test
+// review is still released
+func dueItems(db *gorm.DB, userID uuid.UUID, now time.Time) *gorm.DB {
+	return db.Model(&dataModel.ReviewItem{}).
+		Where("user_id = ? AND due_at < ?", userID, srs.StartOfDay(now).AddDate(0, 0, 1)).
+		Where("question_id IN (?)", db.Table("questions").Select("id").
+			Where("deleted_at IS NULL AND test_id IN (?)", releasedTests(db, now)).QueryExpr())
+}
+
+func itemCard(item dataModel.ReviewItem) srs.Card {
+	return srs.Card{
+		Ease:        item.EaseFactor,
+		Interval:    item.IntervalDays,
+		Repetitions: item.Repetitions,
+		Lapses:      item.Lapses,
+		Due:         item.DueAt,
+	}
+}
+
+func setItemCard(item *dataModel.ReviewItem, card srs.Card) {
+	item.EaseFactor = card.Ease
+	item.IntervalDays = card.Interval
+	item.Repetitions = card.Repetitions
+	item.Lapses = card.Lapses
+	item.DueAt = card.Due
+}
+
+func newDeckItem(item dataModel.ReviewItem) deckItem {
+	return deckItem{
+		ID:             item.ID,
+		QuestionID:     item.QuestionID,
+		DueAt:          item.DueAt,
+		Interval:       item.IntervalDays,
+		Repetitions:    item.Repetitions,
+		Lapses:         item.Lapses,
+		EaseFactor:     item.EaseFactor,
+		LastReviewedAt: item.LastReviewedAt,
+	}
+}
@@ -0,0 +1,70 @@
+package user
+
+import (
+	dataModel "okkybudiman/data/model"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestReviewItemsReleased(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	now := time.Now()
+	closesAt := now.Add(time.Hour)
+	userID := uuid.NewV4()
+
+	//a question answered wrongly in a test of every review policy
+	questions := make(map[string]uuid.UUID)
+	for _, policy := range []string{dataModel.ReviewImmediately, dataModel.ReviewAfterClose, dataModel.ReviewNever} {
+		test := dataModel.Test{ReviewPolicy: policy, AvailableUntil: &closesAt}
+		if err := db.Create(&test).Error; err != nil {
+			t.Fatal(err)
+		}
+		question := dataModel.Question{TestID: test.ID, Type: dataModel.QuestionTypeTextEntry, Answer: "right"}
+		if err := db.Create(&question).Error; err != nil {
+			t.Fatal(err)
+		}
+		answer := dataModel.UserAnswer{UserID: userID, TestID: test.ID, QuestionID: question.ID, Point: pointWrong}
+		if err := db.Create(&answer).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := addReviewItem(db, userID, question.ID, now); err != nil {
+			t.Fatal(err)
+		}
+		questions[policy] = question.ID
+	}
+
+	inDeck := func(at time.Time) map[string]bool {
+		if err := syncReviewItems(db, userID, at); err != nil {
+			t.Fatal(err)
+		}
+		var due []dataModel.ReviewItem
+		if err := dueItems(db, userID, at).Find(&due).Error; err != nil {
+			t.Fatal(err)
+		}
+		res := make(map[string]bool)
+		for _, v := range due {
+			for policy, id := range questions {
+				if v.QuestionID == id {
+					res[policy] = true
+				}
+			}
+		}
+		return res
+	}
+
+	if deck := inDeck(now); !deck[dataModel.ReviewImmediately] || deck[dataModel.ReviewAfterClose] || deck[dataModel.ReviewNever] {
+		t.Errorf("deck before the test closes has %v, want only the question of the immediately released test", deck)
+	}
+	if deck := inDeck(closesAt.Add(time.Minute)); !deck[dataModel.ReviewImmediately] || !deck[dataModel.ReviewAfterClose] || deck[dataModel.ReviewNever] {
+		t.Errorf("deck after the test closes has %v, want every question but the one of the never released test", deck)
+	}
+
+	var total int
+	db.Model(&dataModel.ReviewItem{}).Where("question_id = ?", questions[dataModel.ReviewNever]).Count(&total)
+	if total != 0 {
+		t.Errorf("question of the never released test has %d review items, want none", total)
+	}
+}
@@ -0,0 +1,354 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"okkybudiman/config"
+	"okkybudiman/data"
+	dataModel "okkybudiman/data/model"
+	"okkybudiman/sandbox"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// maximum size in bytes of an answer, the code of programming answers included
+	maxCodeLength = 65536
+	// a job failing this many times is not run again, the answer keeps no point
+	maxGradingAttempts  = 3
+	defaultPollInterval = time.Second
+	// delay before a failed job is run again, doubled after every failure
+	gradingRetryDelay = 10 * time.Second
+	// lease of a worker on the job it runs, renewed every third of it while the job runs
+	gradingLease = time.Minute
+)
+
+// Grader workers grading the programming answers queued by AnswerTest. A job is run by the first worker claiming it,
+// so several servers can share the queue.
+type Grader struct {
+	dbFactory *data.DBFactory
+	runner    *sandbox.Runner
+	workers   int
+	interval  time.Duration
+	cfg       config.SandboxConfiguration
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewGrader instantiate the grader, nothing is graded until it is started
+func NewGrader(dbFactory *data.DBFactory, runner *sandbox.Runner, cfg config.SandboxConfiguration) (*Grader, error) {
+	if dbFactory == nil {
+		return nil, errors.New("failed to instantiate grader")
+	}
+
+	interval := time.Duration(cfg.PollInterval) * time.Millisecond
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	return &Grader{dbFactory: dbFactory, runner: runner, workers: cfg.Workers, interval: interval, cfg: cfg, stop: make(chan struct{})}, nil
+}
+
+// Start start the workers, the jobs left running by a stopped server are queued again once their lease expired.
+// Isolated code must be limited in processes, the grader does not start when it is not.
+func (g *Grader) Start() error {
+	if g.workers > 0 && g.cfg.Isolate && g.cfg.MaxProcesses <= 0 {
+		return errors.New("sandbox maxProcesses must be set when the code is isolated")
+	}
+
+	db, err := g.dbFactory.DBConnection()
+	if err != nil {
+		return err
+	}
+	err = requeueExpired(db, time.Now())
+	db.Close()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < g.workers; i++ {
+		g.wg.Add(1)
+		go g.work()
+	}
+
+	return nil
+}
+
+// Stop stop the workers once the jobs they are running are done
+func (g *Grader) Stop() {
+	close(g.stop)
+	g.wg.Wait()
+}
+
+// work run the queued jobs one by one, polling the queue when it is empty
+func (g *Grader) work() {
+	defer g.wg.Done()
+
+	db, err := g.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		return
+	}
+	defer db.Close()
+
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+	for {
+		for g.next(db) {
+			select {
+			case <-g.stop:
+				return
+			default:
+			}
+		}
+
+		select {
+		case <-g.stop:
+			return
+		case <-ticker.C:
+		}
+
+		if err := requeueExpired(db, time.Now()); err != nil {
+			glog.Errorf("Failed to queue expired grading jobs: %s", err)
+		}
+	}
+}
+
+// requeueExpired queue again the running jobs whose lease expired at now, their worker stopped
+func requeueExpired(db *gorm.DB, now time.Time) error {
+	return db.Model(&dataModel.GradingJob{}).
+		Where("status = ? AND (lease_until IS NULL OR lease_until < ?)", dataModel.GradingJobRunning, now).
+		UpdateColumn("status", dataModel.GradingJobQueued).Error
+}
+
+// renewLease extend the lease of the worker on a running job until done is closed
+func renewLease(db *gorm.DB, jobID uuid.UUID, done chan struct{}) {
+	ticker := time.NewTicker(gradingLease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		err := db.Model(&dataModel.GradingJob{}).Where("id = ? AND status = ?", jobID, dataModel.GradingJobRunning).
+			UpdateColumn("lease_until", time.Now().Add(gradingLease)).Error
+		if err != nil {
+			glog.Errorf("Failed to renew the lease of grading job %s: %s", jobID, err)
+		}
+	}
+}
+
+// next claim and run the oldest queued job which is due, false when there is none
+func (g *Grader) next(db *gorm.DB) bool {
+	var job dataModel.GradingJob
+	err := db.Where("status = ? AND (run_after IS NULL OR run_after <= ?)", dataModel.GradingJobQueued, time.Now()).
+		Order("created_at").First(&job).Error
+	if err != nil {
+		if !gorm.IsRecordNotFoundError(err) {
+			glog.Errorf("Failed to load grading job: %s", err)
+		}
+		return false
+	}
+
+	//the job is claimed by the worker which moves it out of the queue first
+	now := time.Now()
+	claim := db.Model(&dataModel.GradingJob{}).Where("id = ? AND status = ?", job.ID, dataModel.GradingJobQueued).
+		Updates(map[string]interface{}{"status": dataModel.GradingJobRunning, "attempts": job.Attempts + 1, "started_at": now, "lease_until": now.Add(gradingLease)})
+	if claim.Error != nil {
+		glog.Errorf("Failed to claim grading job %s: %s", job.ID, claim.Error)
+		return false
+	}
+	if claim.RowsAffected == 0 {
+		return true
+	}
+	job.Attempts++
+
+	status := dataModel.GradingJobDone
+	message := ""
+	var runAfter *time.Time
+	done := make(chan struct{})
+	go renewLease(db, job.ID, done)
+	err = g.grade(db, job.UserAnswerID)
+	close(done)
+	if err != nil {
+		glog.Errorf("Failed to grade answer %s: %s", job.UserAnswerID, err)
+		message = err.Error()
+		status = dataModel.GradingJobQueued
+		retry := time.Now().Add(retryDelay(job.Attempts))
+		runAfter = &retry
+		if job.Attempts >= maxGradingAttempts {
+			status = dataModel.GradingJobFailed
+			db.Model(&dataModel.UserAnswer{}).Where("id = ?", job.UserAnswerID).UpdateColumn("grading_status", dataModel.GradingFailed)
+		}
+	}
+
+	err = db.Model(&dataModel.GradingJob{}).Where("id = ?", job.ID).
+		Updates(map[string]interface{}{"status": status, "error": message, "run_after": runAfter, "lease_until": nil, "finished_at": time.Now()}).Error
+	if err != nil {
+		glog.Errorf("Failed to save grading job %s: %s", job.ID, err)
+	}
+
+	return true
+}
+
+// retryDelay delay before a job which failed attempts times is run again
+func retryDelay(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	return gradingRetryDelay << uint(attempts-1)
+}
+
+// grade run the code of a programming answer against the test cases of its question, save the result of every test
+// case and the point of the answer, then score its attempt again. The answer gets the points of the test cases it
+// passed, or the point of a wrong answer when it passed none.
+func (g *Grader) grade(db *gorm.DB, answerID uuid.UUID) error {
+	var answer dataModel.UserAnswer
+	var question dataModel.Question
+	if err := db.Where("id = ?", answerID).First(&answer).Error; err != nil {
+		return err
+	}
+	if err := db.Where("id = ?", answer.QuestionID).Preload("TestCases").First(&question).Error; err != nil {
+		return err
+	}
+	sort.Slice(question.TestCases, func(i, j int) bool { return question.TestCases[i].Key < question.TestCases[j].Key })
+
+	var response dataModel.ProgrammingResponse
+	if err := json.Unmarshal([]byte(answer.Response), &response); err != nil {
+		return fmt.Errorf("cannot read the code: %s", err)
+	}
+
+	var cases []sandbox.Case
+	for _, v := range question.TestCases {
+		cases = append(cases, sandbox.Case{Input: v.Input, Expected: v.ExpectedOutput})
+	}
+
+	var results []sandbox.Result
+	if question.Language != "" && response.Language != question.Language {
+		//code in another language than the one of the question does not compile
+		for range cases {
+			results = append(results, sandbox.Result{
+				Status:  sandbox.StatusCompileError,
+				Message: fmt.Sprintf("code must be written in %s", question.Language),
+			})
+		}
+	} else {
+		var err error
+		limits := sandbox.Limits{
+			Time:   time.Duration(question.TimeLimit) * time.Millisecond,
+			Memory: int64(question.MemoryLimit) << 20,
+		}
+		if results, err = g.runner.Judge(response.Language, response.Code, cases, limits); err != nil {
+			return err
+		}
+	}
+
+	point := 0
+	passed := 0
+	tx := db.Begin()
+	if err := tx.Where("user_answer_id = ?", answer.ID).Delete(&dataModel.TestCaseResult{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	for k, v := range results {
+		result := dataModel.TestCaseResult{
+			UserAnswerID: answer.ID,
+			TestCaseID:   question.TestCases[k].ID,
+			Status:       v.Status,
+			TimeMs:       int(v.Time / time.Millisecond),
+			Output:       v.Output,
+			Message:      v.Message,
+		}
+		if v.Status == sandbox.StatusPassed {
+			result.Point = question.TestCases[k].Point
+			point += result.Point
+			passed++
+		}
+		if err := tx.Create(&result).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if passed == 0 {
+		point = pointWrong
+	}
+
+	err := tx.Model(&dataModel.UserAnswer{}).Where("id = ?", answer.ID).
+		Updates(map[string]interface{}{"point": point, "grading_status": dataModel.GradingGraded}).Error
+	if err == nil {
+		err = rescoreAttempt(tx, answer.UserAttemptTestID)
+	}
+	if err == nil && point < 0 {
+		err = addReviewItem(tx, answer.UserID, answer.QuestionID, time.Now())
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// rescoreAttempt count the right, wrong and not answered questions of an official attempt again from its answers
+// and save them in the score of the attempt
+func rescoreAttempt(db *gorm.DB, attemptID uuid.UUID) error {
+	var attempt dataModel.UserAttemptTest
+	var score dataModel.UserScore
+	var answers []dataModel.UserAnswer
+
+	if err := db.Where("id = ?", attemptID).First(&attempt).Error; err != nil {
+		//answers saved without an attempt have no score of their own
+		if gorm.IsRecordNotFoundError(err) {
+			return nil
+		}
+		return err
+	}
+	if err := db.Where("user_attempt_test_id = ?", attempt.ID).First(&score).Error; err != nil {
+		return err
+	}
+	if err := db.Where("user_attempt_test_id = ?", attempt.ID).Find(&answers).Error; err != nil {
+		return err
+	}
+
+	score.Score = 0
+	score.TotalRightAnswered = 0
+	score.TotalWrongAnswered = 0
+	score.TotalNotAnswered = 0
+	for _, v := range answers {
+		score.Score += v.Point
+		switch pointStatus(v.Point) {
+		case answerRight:
+			score.TotalRightAnswered++
+		case answerWrong:
+			score.TotalWrongAnswered++
+		default:
+			score.TotalNotAnswered++
+		}
+	}
+
+	return db.Save(&score).Error
+}
+
+// validateCode check the language of the code of the answers and their size
+func validateCode(answers []answerData) []string {
+	var errors []string
+	for k, v := range answers {
+		if v.Language != "" && !sandbox.IsSupportedLanguage(v.Language) {
+			errors = append(errors, fmt.Sprintf("language of answer %d must be go or python", k+1))
+		}
+		if len(v.Answer) > maxCodeLength {
+			errors = append(errors, fmt.Sprintf("answer %d must not be longer than %d bytes", k+1, maxCodeLength))
+		}
+	}
+
+	return errors
+}
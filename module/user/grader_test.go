@@ -0,0 +1,44 @@
+package user
+
+import (
+	dataModel "okkybudiman/data/model"
+	"testing"
+	"time"
+)
+
+func TestRequeueExpired(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	now := time.Now()
+	expired := now.Add(-time.Second)
+	leased := now.Add(time.Minute)
+
+	//a job run by a live worker, one whose worker stopped and one claimed before the jobs had a lease
+	tests := []struct {
+		name string
+		job  dataModel.GradingJob
+		want string
+	}{
+		{"leased", dataModel.GradingJob{Status: dataModel.GradingJobRunning, LeaseUntil: &leased}, dataModel.GradingJobRunning},
+		{"expired", dataModel.GradingJob{Status: dataModel.GradingJobRunning, LeaseUntil: &expired}, dataModel.GradingJobQueued},
+		{"no lease", dataModel.GradingJob{Status: dataModel.GradingJobRunning}, dataModel.GradingJobQueued},
+	}
+	for k := range tests {
+		if err := db.Create(&tests[k].job).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := requeueExpired(db, now); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		var job dataModel.GradingJob
+		if err := db.Where("id = ?", tt.job.ID).First(&job).Error; err != nil {
+			t.Fatal(err)
+		}
+		if job.Status != tt.want {
+			t.Errorf("%s job has status %s, want %s", tt.name, job.Status, tt.want)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package user
+
+import (
+	"encoding/json"
+	"math"
+	dataModel "okkybudiman/data/model"
+	"okkybudiman/sandbox"
+	"sort"
+	"strconv"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// point of an answer
+const (
+	pointRight = dataModel.PointRight
+	pointWrong = dataModel.PointWrong
+	pointEmpty = 0
+)
+
+// status of a graded answer
+const (
+	answerRight = "right"
+	answerWrong = "wrong"
+	answerEmpty = "not_answered"
+	//programming answers are graded by the grader once they are saved
+	answerPending = "pending"
+)
+
+// gradeAnswer grade the answer of a question, choices are all the choices of the question.
+// Calculated questions must be instantiated with the seed of the attempt first.
+// It returns the status of the answer and its point.
+func gradeAnswer(question dataModel.Question, choices []dataModel.QuestionChoice, answer answerData) (string, int) {
+	switch question.Type {
+	case dataModel.QuestionTypeProgramming:
+		if strings.TrimSpace(answer.Answer) == "" {
+			return answerEmpty, pointEmpty
+		}
+		return answerPending, pointEmpty
+
+	case dataModel.QuestionTypeCalculated:
+		if strings.TrimSpace(answer.Answer) == "" {
+			return answerEmpty, pointEmpty
+		}
+		if matchNumber(answer.Answer, question.Answer, question.AnswerTolerance) {
+			return answerRight, pointRight
+		}
+		return answerWrong, pointWrong
+
+	case dataModel.QuestionTypeTextEntry:
+		if strings.TrimSpace(answer.Answer) == "" {
+			return answerEmpty, pointEmpty
+		}
+		if normalizeText(answer.Answer) == normalizeText(question.Answer) {
+			return answerRight, pointRight
+		}
+		return answerWrong, pointWrong
+
+	case dataModel.QuestionTypeOrdering, dataModel.QuestionTypeMatching:
+		return gradeArrangement(question, choices, answer)
+
+	case dataModel.QuestionTypeCloze:
+		return gradeCloze(question, answer)
+
+	case dataModel.QuestionTypeMultipleChoice:
+		picked := pickedChoices(answer)
+		if len(picked) == 0 {
+			return answerEmpty, pointEmpty
+		}
+
+		//every correct choice and only them must be picked
+		for _, v := range choices {
+			if v.IsCorrect != picked[v.ID] {
+				return answerWrong, pointWrong
+			}
+			delete(picked, v.ID)
+		}
+		if len(picked) > 0 {
+			return answerWrong, pointWrong
+		}
+		return answerRight, pointRight
+	}
+
+	if answer.ChoiceID == "" {
+		return answerEmpty, pointEmpty
+	}
+
+	//the submitted choice must belong to the question
+	choiceID, _ := uuid.FromString(answer.ChoiceID)
+	for _, v := range choices {
+		if v.ID == choiceID && v.IsCorrect {
+			return answerRight, pointRight
+		}
+	}
+	return answerWrong, pointWrong
+}
+
+// gradeArrangement grade the answer of an ordering question, the choice ids in the order of the user, or of a matching
+// question, the match picked for every choice. The answer is right when every position or pair is, with partial
+// credit it gets the item point of every right position or pair instead. An answer with none right is wrong.
+func gradeArrangement(question dataModel.Question, choices []dataModel.QuestionChoice, answer answerData) (string, int) {
+	sorted := append([]dataModel.QuestionChoice{}, choices...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+
+	total, right := 0, 0
+	if question.Type == dataModel.QuestionTypeOrdering {
+		if len(answer.ChoiceIDs) == 0 {
+			return answerEmpty, pointEmpty
+		}
+		total = len(sorted)
+		for k, v := range sorted {
+			if k < len(answer.ChoiceIDs) && answer.ChoiceIDs[k] == v.ID.String() {
+				right++
+			}
+		}
+	} else {
+		if len(answer.Matches) == 0 {
+			return answerEmpty, pointEmpty
+		}
+		picked := make(map[string]string)
+		for _, v := range answer.Matches {
+			picked[v.ChoiceID] = v.MatchID
+		}
+		for _, v := range sorted {
+			//distractors are not paired with any choice
+			if strings.TrimSpace(v.Choice) == "" {
+				continue
+			}
+			total++
+			if picked[v.ID.String()] == dataModel.MatchID(question.ID, v.Match).String() {
+				right++
+			}
+		}
+	}
+
+	switch {
+	case right == 0:
+		return answerWrong, pointWrong
+	case question.PartialCredit:
+		return answerRight, right * question.ItemPoint
+	case right == total:
+		return answerRight, pointRight
+	}
+	return answerWrong, pointWrong
+}
+
+// gradeCloze grade the answer of a cloze question blank by blank, the answer gets the point of every right blank.
+// An answer with no right blank is wrong.
+func gradeCloze(question dataModel.Question, answer answerData) (string, int) {
+	answered := false
+	for _, v := range answer.Blanks {
+		if strings.TrimSpace(v) != "" {
+			answered = true
+		}
+	}
+	if !answered {
+		return answerEmpty, pointEmpty
+	}
+
+	point, right := 0, 0
+	for k, v := range question.ClozeBlanks() {
+		if k < len(answer.Blanks) && blankRight(v, answer.Blanks[k]) {
+			point += v.Point
+			right++
+		}
+	}
+	if right == 0 {
+		return answerWrong, pointWrong
+	}
+	return answerRight, point
+}
+
+// blankRight check whether text answers blank, the option of a dropdown blank must be one of its answers exactly and
+// the text of a text blank any of its answers, compared like text entry answers
+func blankRight(blank dataModel.ClozeBlank, text string) bool {
+	if strings.TrimSpace(text) == "" {
+		return false
+	}
+	for _, v := range blank.Answers {
+		if blank.Type == dataModel.BlankTypeDropdown && strings.TrimSpace(v) == strings.TrimSpace(text) {
+			return true
+		}
+		if blank.Type != dataModel.BlankTypeDropdown && normalizeText(v) == normalizeText(text) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pickedChoices get the choices of a multiple choice answer, a single choice_id is accepted too
+func pickedChoices(answer answerData) map[uuid.UUID]bool {
+	ids := answer.ChoiceIDs
+	if len(ids) == 0 && answer.ChoiceID != "" {
+		ids = []string{answer.ChoiceID}
+	}
+
+	picked := make(map[uuid.UUID]bool)
+	for _, v := range ids {
+		id, _ := uuid.FromString(v)
+		picked[id] = true
+	}
+
+	return picked
+}
+
+// answerResponse encode the answer of the question types other than single choice to be saved in UserAnswer.Response.
+// Matching answers are saved as the id of the match picked by choice id, cloze answers as the answers of the blanks.
+func answerResponse(question dataModel.Question, answer answerData) string {
+	var response interface{}
+	switch question.Type {
+	case dataModel.QuestionTypeTextEntry, dataModel.QuestionTypeCalculated:
+		response = answer.Answer
+	case dataModel.QuestionTypeProgramming:
+		response = dataModel.ProgrammingResponse{Language: answerLanguage(question, answer), Code: answer.Answer}
+	case dataModel.QuestionTypeMultipleChoice:
+		var ids []string
+		for id := range pickedChoices(answer) {
+			ids = append(ids, id.String())
+		}
+		//the choices are picked from a map, they are sorted so the same answer is always saved the same way
+		sort.Strings(ids)
+		response = ids
+	case dataModel.QuestionTypeOrdering:
+		response = answer.ChoiceIDs
+	case dataModel.QuestionTypeCloze:
+		response = answer.Blanks
+	case dataModel.QuestionTypeMatching:
+		matches := make(map[string]string)
+		for _, v := range answer.Matches {
+			matches[v.ChoiceID] = v.MatchID
+		}
+		response = matches
+	default:
+		return ""
+	}
+
+	data, _ := json.Marshal(response)
+	return string(data)
+}
+
+// answerLanguage the language of the code of a programming answer, the language of the question when it is not sent
+func answerLanguage(question dataModel.Question, answer answerData) string {
+	if answer.Language == "" && question.Language != "" {
+		return question.Language
+	}
+	if answer.Language == "" {
+		return sandbox.LanguageGo
+	}
+
+	return answer.Language
+}
+
+// normalizeText text answers are compared case insensitively, ignoring extra spaces
+func normalizeText(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+// matchNumber check whether the number answered is the expected one, give or take tolerance.
+// Numbers can be written with a decimal comma or as a fraction such as 3/4.
+func matchNumber(answer string, expected string, tolerance float64) bool {
+	value, ok := parseNumber(answer)
+	if !ok {
+		return false
+	}
+	want, ok := parseNumber(expected)
+	if !ok {
+		return false
+	}
+
+	//the expected answer is rounded when it is formatted, a tolerance of 0 still accepts that rounding
+	tolerance = math.Max(math.Abs(tolerance), 1e-9*math.Max(1, math.Abs(want)))
+	return math.Abs(value-want) <= tolerance
+}
+
+func parseNumber(text string) (float64, bool) {
+	text = strings.Replace(strings.TrimSpace(text), " ", "", -1)
+	if !strings.Contains(text, ".") {
+		text = strings.Replace(text, ",", ".", 1)
+	}
+
+	if parts := strings.Split(text, "/"); len(parts) == 2 {
+		numerator, err1 := strconv.ParseFloat(parts[0], 64)
+		denominator, err2 := strconv.ParseFloat(parts[1], 64)
+		if err1 != nil || err2 != nil || denominator == 0 {
+			return 0, false
+		}
+		return numerator / denominator, true
+	}
+
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, false
+	}
+	return value, true
+}
@@ -0,0 +1,279 @@
+package user
+
+import (
+	"encoding/json"
+	dataModel "okkybudiman/data/model"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// newChoices choices of a question keyed from 1, the ones at correct are the right ones
+func newChoices(texts []string, correct ...int) []dataModel.QuestionChoice {
+	choices := make([]dataModel.QuestionChoice, len(texts))
+	for k, v := range texts {
+		choices[k] = dataModel.QuestionChoice{Key: k + 1, Choice: v}
+		choices[k].ID = uuid.NewV4()
+	}
+	for _, v := range correct {
+		choices[v].IsCorrect = true
+	}
+
+	return choices
+}
+
+// newBlanks the blanks of a cloze question saved as in Question.Blanks
+func newBlanks(blanks ...dataModel.ClozeBlank) string {
+	data, _ := json.Marshal(blanks)
+	return string(data)
+}
+
+func TestGradeAnswer(t *testing.T) {
+	single := newChoices([]string{"a", "b", "c"}, 1)
+	multiple := newChoices([]string{"a", "b", "c"}, 0, 2)
+	other := newChoices([]string{"a"}, 0)
+
+	tests := []struct {
+		name     string
+		question dataModel.Question
+		choices  []dataModel.QuestionChoice
+		answer   answerData
+		status   string
+		point    int
+	}{
+		{"single choice right", dataModel.Question{}, single, answerData{ChoiceID: single[1].ID.String()}, answerRight, pointRight},
+		{"single choice wrong", dataModel.Question{}, single, answerData{ChoiceID: single[0].ID.String()}, answerWrong, pointWrong},
+		{"single choice empty", dataModel.Question{}, single, answerData{}, answerEmpty, pointEmpty},
+		{"single choice of another question", dataModel.Question{}, single, answerData{ChoiceID: other[0].ID.String()}, answerWrong, pointWrong},
+
+		{"multiple choice right", dataModel.Question{Type: dataModel.QuestionTypeMultipleChoice}, multiple,
+			answerData{ChoiceIDs: []string{multiple[2].ID.String(), multiple[0].ID.String()}}, answerRight, pointRight},
+		{"multiple choice missing a right choice", dataModel.Question{Type: dataModel.QuestionTypeMultipleChoice}, multiple,
+			answerData{ChoiceIDs: []string{multiple[0].ID.String()}}, answerWrong, pointWrong},
+		{"multiple choice with a wrong choice", dataModel.Question{Type: dataModel.QuestionTypeMultipleChoice}, multiple,
+			answerData{ChoiceIDs: []string{multiple[0].ID.String(), multiple[1].ID.String(), multiple[2].ID.String()}}, answerWrong, pointWrong},
+		{"multiple choice with a choice of another question", dataModel.Question{Type: dataModel.QuestionTypeMultipleChoice}, multiple,
+			answerData{ChoiceIDs: []string{multiple[0].ID.String(), multiple[2].ID.String(), other[0].ID.String()}}, answerWrong, pointWrong},
+		{"multiple choice empty", dataModel.Question{Type: dataModel.QuestionTypeMultipleChoice}, multiple, answerData{}, answerEmpty, pointEmpty},
+
+		{"text entry right", dataModel.Question{Type: dataModel.QuestionTypeTextEntry, Answer: "New York"}, nil,
+			answerData{Answer: "  new   YORK "}, answerRight, pointRight},
+		{"text entry wrong", dataModel.Question{Type: dataModel.QuestionTypeTextEntry, Answer: "New York"}, nil,
+			answerData{Answer: "York"}, answerWrong, pointWrong},
+		{"text entry empty", dataModel.Question{Type: dataModel.QuestionTypeTextEntry, Answer: "New York"}, nil,
+			answerData{Answer: "  "}, answerEmpty, pointEmpty},
+
+		{"calculated right", dataModel.Question{Type: dataModel.QuestionTypeCalculated, Answer: "3.5"}, nil,
+			answerData{Answer: "3,5"}, answerRight, pointRight},
+		{"calculated right within tolerance", dataModel.Question{Type: dataModel.QuestionTypeCalculated, Answer: "0.75", AnswerTolerance: 0.01}, nil,
+			answerData{Answer: "0.755"}, answerRight, pointRight},
+		{"calculated wrong", dataModel.Question{Type: dataModel.QuestionTypeCalculated, Answer: "0.75", AnswerTolerance: 0.01}, nil,
+			answerData{Answer: "0.8"}, answerWrong, pointWrong},
+		{"calculated not a number", dataModel.Question{Type: dataModel.QuestionTypeCalculated, Answer: "0.75"}, nil,
+			answerData{Answer: "three quarters"}, answerWrong, pointWrong},
+		{"calculated empty", dataModel.Question{Type: dataModel.QuestionTypeCalculated, Answer: "0.75"}, nil,
+			answerData{}, answerEmpty, pointEmpty},
+
+		{"programming answered", dataModel.Question{Type: dataModel.QuestionTypeProgramming}, nil,
+			answerData{Answer: "package main"}, answerPending, pointEmpty},
+		{"programming empty", dataModel.Question{Type: dataModel.QuestionTypeProgramming}, nil,
+			answerData{Answer: "\n"}, answerEmpty, pointEmpty},
+	}
+
+	for _, tt := range tests {
+		status, point := gradeAnswer(tt.question, tt.choices, tt.answer)
+		if status != tt.status || point != tt.point {
+			t.Errorf("%s: got %s with %d, want %s with %d", tt.name, status, point, tt.status, tt.point)
+		}
+	}
+}
+
+func TestGradeArrangement(t *testing.T) {
+	ordering := newChoices([]string{"1", "2", "3"})
+	//the last choice is a distractor, only its match is sent
+	matching := newChoices([]string{"France", "Japan", ""})
+	matching[0].Match, matching[1].Match, matching[2].Match = "Paris", "Tokyo", "Berlin"
+
+	question := dataModel.Question{Type: dataModel.QuestionTypeMatching, ItemPoint: 2}
+	question.ID = uuid.NewV4()
+	match := func(k int, item string) matchAnswer {
+		return matchAnswer{ChoiceID: matching[k].ID.String(), MatchID: dataModel.MatchID(question.ID, item).String()}
+	}
+	order := func(keys ...int) []string {
+		var ids []string
+		for _, v := range keys {
+			ids = append(ids, ordering[v].ID.String())
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name    string
+		typ     string
+		partial bool
+		answer  answerData
+		status  string
+		point   int
+	}{
+		{"ordering right", dataModel.QuestionTypeOrdering, false, answerData{ChoiceIDs: order(0, 1, 2)}, answerRight, pointRight},
+		{"ordering partly right", dataModel.QuestionTypeOrdering, false, answerData{ChoiceIDs: order(0, 2, 1)}, answerWrong, pointWrong},
+		{"ordering partly right with partial credit", dataModel.QuestionTypeOrdering, true, answerData{ChoiceIDs: order(0, 2, 1)}, answerRight, 2},
+		{"ordering right with partial credit", dataModel.QuestionTypeOrdering, true, answerData{ChoiceIDs: order(0, 1, 2)}, answerRight, 6},
+		{"ordering too short", dataModel.QuestionTypeOrdering, true, answerData{ChoiceIDs: order(0, 1)}, answerRight, 4},
+		{"ordering wrong with partial credit", dataModel.QuestionTypeOrdering, true, answerData{ChoiceIDs: order(2, 0, 1)}, answerWrong, pointWrong},
+		{"ordering empty", dataModel.QuestionTypeOrdering, false, answerData{}, answerEmpty, pointEmpty},
+
+		{"matching right", dataModel.QuestionTypeMatching, false,
+			answerData{Matches: []matchAnswer{match(0, "Paris"), match(1, "Tokyo")}}, answerRight, pointRight},
+		{"matching partly right", dataModel.QuestionTypeMatching, false,
+			answerData{Matches: []matchAnswer{match(0, "Paris"), match(1, "Berlin")}}, answerWrong, pointWrong},
+		{"matching partly right with partial credit", dataModel.QuestionTypeMatching, true,
+			answerData{Matches: []matchAnswer{match(0, "Paris"), match(1, "Berlin")}}, answerRight, 2},
+		{"matching right with partial credit", dataModel.QuestionTypeMatching, true,
+			answerData{Matches: []matchAnswer{match(0, "Paris"), match(1, "Tokyo")}}, answerRight, 4},
+		{"matching wrong with partial credit", dataModel.QuestionTypeMatching, true,
+			answerData{Matches: []matchAnswer{match(0, "Tokyo"), match(1, "Paris")}}, answerWrong, pointWrong},
+		{"matching empty", dataModel.QuestionTypeMatching, true, answerData{}, answerEmpty, pointEmpty},
+	}
+
+	for _, tt := range tests {
+		q := question
+		q.Type = tt.typ
+		q.PartialCredit = tt.partial
+		choices := matching
+		if tt.typ == dataModel.QuestionTypeOrdering {
+			//the choices are graded in the order of their key whatever the order they are loaded in
+			choices = []dataModel.QuestionChoice{ordering[2], ordering[0], ordering[1]}
+		}
+
+		status, point := gradeAnswer(q, choices, tt.answer)
+		if status != tt.status || point != tt.point {
+			t.Errorf("%s: got %s with %d, want %s with %d", tt.name, status, point, tt.status, tt.point)
+		}
+	}
+}
+
+func TestGradeCloze(t *testing.T) {
+	question := dataModel.Question{
+		Type:     dataModel.QuestionTypeCloze,
+		Question: "[[1]] is the capital of [[2]]",
+		Blanks: newBlanks(
+			dataModel.ClozeBlank{Type: dataModel.BlankTypeDropdown, Options: []string{"Paris", "paris", "Rome"}, Answers: []string{"Paris"}, Point: 1},
+			dataModel.ClozeBlank{Type: dataModel.BlankTypeText, Answers: []string{"France", "French Republic"}, Point: 3},
+		),
+	}
+
+	tests := []struct {
+		name   string
+		blanks []string
+		status string
+		point  int
+	}{
+		{"right", []string{"Paris", "france"}, answerRight, 4},
+		{"right with another accepted answer", []string{"Paris", " french  republic"}, answerRight, 4},
+		{"partly right", []string{"Rome", "France"}, answerRight, 3},
+		{"dropdown option compared exactly", []string{"paris", "Italy"}, answerWrong, pointWrong},
+		{"one blank answered", []string{"Paris"}, answerRight, 1},
+		{"extra blanks", []string{"Paris", "France", "Europe"}, answerRight, 4},
+		{"empty blanks", []string{"", " "}, answerEmpty, pointEmpty},
+		{"empty", nil, answerEmpty, pointEmpty},
+	}
+
+	for _, tt := range tests {
+		status, point := gradeAnswer(question, nil, answerData{Blanks: tt.blanks})
+		if status != tt.status || point != tt.point {
+			t.Errorf("%s: got %s with %d, want %s with %d", tt.name, status, point, tt.status, tt.point)
+		}
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		text  string
+		value float64
+		ok    bool
+	}{
+		{"42", 42, true},
+		{" -1.5 ", -1.5, true},
+		{"1,5", 1.5, true},
+		{"1 000", 1000, true},
+		{"3/4", 0.75, true},
+		{"-3/4", -0.75, true},
+		{"1e3", 1000, true},
+		{"1/0", 0, false},
+		{"1/2/3", 0, false},
+		{"NaN", 0, false},
+		{"Inf", 0, false},
+		{"", 0, false},
+		{"abc", 0, false},
+	}
+
+	for _, tt := range tests {
+		value, ok := parseNumber(tt.text)
+		if value != tt.value || ok != tt.ok {
+			t.Errorf("parseNumber(%q) = %v, %v, want %v, %v", tt.text, value, ok, tt.value, tt.ok)
+		}
+	}
+}
+
+func TestMatchNumber(t *testing.T) {
+	tests := []struct {
+		answer    string
+		expected  string
+		tolerance float64
+		match     bool
+	}{
+		{"0.75", "3/4", 0, true},
+		{"3/4", "0.75", 0, true},
+		{"0.7500000000001", "0.75", 0, true},
+		{"0.76", "0.75", 0, false},
+		{"0.755", "0.75", 0.01, true},
+		{"0.745", "0.75", -0.01, true},
+		{"0.77", "0.75", 0.01, false},
+		{"1000000000.5", "1000000000", 0, true},
+		{"1000000002", "1000000000", 0, false},
+		{"x", "1", 1, false},
+		{"1", "", 1, false},
+	}
+
+	for _, tt := range tests {
+		if match := matchNumber(tt.answer, tt.expected, tt.tolerance); match != tt.match {
+			t.Errorf("matchNumber(%q, %q, %v) = %v, want %v", tt.answer, tt.expected, tt.tolerance, match, tt.match)
+		}
+	}
+}
+
+func TestAnswerResponse(t *testing.T) {
+	a, b := "00000000-0000-0000-0000-00000000000a", "00000000-0000-0000-0000-00000000000b"
+
+	tests := []struct {
+		name     string
+		question dataModel.Question
+		answer   answerData
+		response string
+	}{
+		{"single choice", dataModel.Question{}, answerData{ChoiceID: a}, ""},
+		{"text entry", dataModel.Question{Type: dataModel.QuestionTypeTextEntry}, answerData{Answer: `say "hi"`}, `"say \"hi\""`},
+		{"calculated", dataModel.Question{Type: dataModel.QuestionTypeCalculated}, answerData{Answer: "3/4"}, `"3/4"`},
+		{"multiple choice sorted", dataModel.Question{Type: dataModel.QuestionTypeMultipleChoice}, answerData{ChoiceIDs: []string{b, a, b}},
+			`["` + a + `","` + b + `"]`},
+		{"multiple choice from choice_id", dataModel.Question{Type: dataModel.QuestionTypeMultipleChoice}, answerData{ChoiceID: a}, `["` + a + `"]`},
+		{"multiple choice empty", dataModel.Question{Type: dataModel.QuestionTypeMultipleChoice}, answerData{}, "null"},
+		{"ordering", dataModel.Question{Type: dataModel.QuestionTypeOrdering}, answerData{ChoiceIDs: []string{b, a}}, `["` + b + `","` + a + `"]`},
+		{"matching", dataModel.Question{Type: dataModel.QuestionTypeMatching}, answerData{Matches: []matchAnswer{{ChoiceID: b, MatchID: a}, {ChoiceID: a, MatchID: b}}},
+			`{"` + a + `":"` + b + `","` + b + `":"` + a + `"}`},
+		{"cloze", dataModel.Question{Type: dataModel.QuestionTypeCloze}, answerData{Blanks: []string{"Paris", ""}}, `["Paris",""]`},
+		{"programming in the language of the question", dataModel.Question{Type: dataModel.QuestionTypeProgramming, Language: "python"},
+			answerData{Answer: "print(1)"}, `{"language":"python","code":"print(1)"}`},
+		{"programming in the language of the answer", dataModel.Question{Type: dataModel.QuestionTypeProgramming, Language: "python"},
+			answerData{Answer: "package main", Language: "go"}, `{"language":"go","code":"package main"}`},
+		{"programming in go by default", dataModel.Question{Type: dataModel.QuestionTypeProgramming},
+			answerData{Answer: "package main"}, `{"language":"go","code":"package main"}`},
+	}
+
+	for _, tt := range tests {
+		if response := answerResponse(tt.question, tt.answer); response != tt.response {
+			t.Errorf("%s: got %s, want %s", tt.name, response, tt.response)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package user
+
+import (
+	"net/http"
+	dataModel "okkybudiman/data/model"
+	u "okkybudiman/utility"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	uuid "github.com/satori/go.uuid"
+)
+
+// PracticeAnswer grade one question of a practice attempt right away and show the correct answer, the explanation and
+// the feedback of the picked choices. A question can be answered again, the last answer is kept.
+// Practice answers are saved apart from UserAnswer so they are never part of the results and analytics of the test.
+// The answers are only shown once the review of the test is released and no official attempt of the user is in progress.
+func (ctrl *Controller) PracticeAnswer(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+	claims := jwt.ExtractClaims(c)
+	name := claims["id"].(string)
+	var user dataModel.User
+	db.Where("name = ?", name).Find(&user)
+
+	var req attemptAnswerRequest
+	if !u.BindJSON(c, &req) {
+		return
+	}
+
+	var attempt dataModel.UserAttemptTest
+	attemptID, _ := uuid.FromString(req.AttemptID)
+	if err := db.Where("id = ? AND user_id = ? AND mode = ?", attemptID, user.ID, dataModel.AttemptModePractice).First(&attempt).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find practice attempt",
+		})
+		return
+	}
+
+	var test dataModel.Test
+	if err := db.Where("id = ?", attempt.TestID).First(&test).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Test",
+		})
+		return
+	}
+	if message, ok := reviewReleased(test, time.Now()); !ok || !test.AllowPractice {
+		if message == "" {
+			message = "test cannot be practiced"
+		}
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  http.StatusForbidden,
+			"message": message,
+		})
+		return
+	}
+	if officialInProgress(db, test.ID, user.ID) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  http.StatusForbidden,
+			"message": "official attempt of the test is not finished",
+		})
+		return
+	}
+
+	var question dataModel.Question
+	questionID, _ := uuid.FromString(req.QuestionID)
+	if err := db.Where("id = ? AND test_id = ?", questionID, attempt.TestID).Preload("QuestionChoices").First(&question).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Question",
+		})
+		return
+	}
+
+	//code is graded asynchronously, a practice answer is graded right away
+	if question.Type == dataModel.QuestionTypeProgramming {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"programming questions cannot be practiced"}})
+		return
+	}
+
+	question = instantiateQuestion(question, attempt.Seed)
+
+	data := answerData{
+		QuestionID: req.QuestionID,
+		ChoiceID:   req.ChoiceID,
+		ChoiceIDs:  req.ChoiceIDs,
+		Answer:     req.Answer,
+		Matches:    req.Matches,
+		Blanks:     req.Blanks,
+	}
+	status, point := gradeAnswer(question, question.QuestionChoices, data)
+
+	var answer dataModel.PracticeAnswer
+	db.Where("user_attempt_test_id = ? AND question_id = ?", attempt.ID, question.ID).First(&answer)
+	answer.UserAttemptTestID = attempt.ID
+	answer.UserID = user.ID
+	answer.TestID = attempt.TestID
+	answer.QuestionID = question.ID
+	answer.Point = point
+	answer.Status = status
+	answer.Response = answerResponse(question, data)
+	answer.QuestionChoiceID = uuid.Nil
+	if question.Type == dataModel.QuestionTypeSingleChoice && req.ChoiceID != "" {
+		answer.QuestionChoiceID, _ = uuid.FromString(req.ChoiceID)
+	}
+	if err := db.Save(&answer).Error; err != nil {
+		glog.Errorf("Failed to save practice answer: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	res, keys := newReviewQuestion(0, question)
+	res.setAnswer(question.Type, keys, answer.Response, answer.QuestionChoiceID)
+	res.Point = point
+	res.Status = status
+
+	result := practiceResult{Question: res}
+	picked := pickedChoices(data)
+	for _, v := range res.Choices {
+		if picked[v.ID] && v.Feedback != "" {
+			result.Feedback = append(result.Feedback, v)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "success grade answer",
+		"data":    result,
+	})
+	return
+}
+
+// PracticeSummary count the right, wrong and not answered questions of a practice attempt
+func (ctrl *Controller) PracticeSummary(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+	claims := jwt.ExtractClaims(c)
+	name := claims["id"].(string)
+	var user dataModel.User
+	db.Where("name = ?", name).Find(&user)
+
+	var attempt dataModel.UserAttemptTest
+	var answers []dataModel.PracticeAnswer
+	var total int
+
+	attemptID, _ := uuid.FromString(c.Param("id"))
+	if err := db.Where("id = ? AND user_id = ? AND mode = ?", attemptID, user.ID, dataModel.AttemptModePractice).First(&attempt).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find practice attempt",
+		})
+		return
+	}
+
+	db.Model(&dataModel.Question{}).Where("test_id = ?", attempt.TestID).Count(&total)
+	db.Where("user_attempt_test_id = ?", attempt.ID).Find(&answers)
+
+	summary := practiceSummary{
+		ID:            attempt.ID,
+		TestID:        attempt.TestID,
+		TotalQuestion: total,
+	}
+	for _, v := range answers {
+		switch v.Status {
+		case answerRight:
+			summary.TotalRightAnswered++
+		case answerWrong:
+			summary.TotalWrongAnswered++
+		}
+		summary.Point += v.Point
+	}
+	summary.TotalNotAnswered = total - summary.TotalRightAnswered - summary.TotalWrongAnswered
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "success get practice",
+		"data":    summary,
+	})
+	return
+}
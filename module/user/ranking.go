@@ -0,0 +1,191 @@
+package user
+
+import (
+	"net/http"
+	"okkybudiman/analytics"
+	dataModel "okkybudiman/data/model"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	jwt "github.com/appleboy/gin-jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	defaultLeaderboardSize = 10
+	maxLeaderboardSize     = 100
+)
+
+// participantRow a score of the ranking query with the timing of its attempt
+type participantRow struct {
+	UserID     uuid.UUID
+	Name       string
+	Score      int
+	StartTest  *time.Time
+	EndTest    *time.Time
+	IsFinished *bool
+	FinishTime *string
+}
+
+// Leaderboard list the best participants of a test when its leaderboard is enabled, names are shown, reduced to
+// initials or hidden following the leaderboard mode of the test. The position of the current user is always sent.
+func (ctrl *Controller) Leaderboard(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+	claims := jwt.ExtractClaims(c)
+	name := claims["id"].(string)
+	var user dataModel.User
+	db.Where("name = ?", name).Find(&user)
+
+	var test dataModel.Test
+	testID, _ := uuid.FromString(c.Param("id"))
+	if err := db.Where("id = ?", testID).First(&test).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Test",
+		})
+		return
+	}
+	if test.Leaderboard == "" || test.Leaderboard == dataModel.LeaderboardDisabled {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  http.StatusForbidden,
+			"message": "leaderboard of the test is disabled",
+		})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultLeaderboardSize)))
+	if err != nil || limit < 1 || limit > maxLeaderboardSize {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{"limit must be a number between 1 and 100"}})
+		return
+	}
+
+	rankings, names, err := testRanking(db, testID)
+	if err != nil {
+		glog.Errorf("Failed to get ranking: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var entries []leaderboardEntry
+	var me *leaderboardEntry
+	for k, v := range rankings {
+		entry := leaderboardEntry{
+			Position:       v.Position,
+			Name:           leaderboardName(test.Leaderboard, names[v.ID]),
+			Score:          int(v.Score),
+			Duration:       v.Duration,
+			PercentileRank: v.PercentileRank,
+			Me:             v.ID == user.ID.String(),
+		}
+		if entry.Me {
+			//users always see their own name
+			entry.Name = user.Name
+			me = &entry
+		}
+		if k < limit {
+			entries = append(entries, entry)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":            http.StatusOK,
+		"message":           "success get leaderboard",
+		"data":              entries,
+		"me":                me,
+		"total_participant": len(rankings),
+	})
+	return
+}
+
+// testRanking rank the participants of a test by the score of their last attempt, as shown in their result, with
+// the names of the participants by user id
+func testRanking(db *gorm.DB, testID uuid.UUID) ([]analytics.Ranking, map[string]string, error) {
+	rows, err := db.Table("user_scores").
+		Select("user_scores.user_id, users.name, user_scores.score, user_attempt_tests.start_test, "+
+			"user_attempt_tests.end_test, user_attempt_tests.is_finished, user_attempt_tests.finish_time").
+		Joins("JOIN users ON users.id = user_scores.user_id").
+		Joins("LEFT JOIN user_attempt_tests ON user_attempt_tests.id = user_scores.user_attempt_test_id "+
+			"AND user_attempt_tests.deleted_at IS NULL").
+		Where("user_scores.test_id = ? AND user_scores.deleted_at IS NULL", testID).
+		Order("user_attempt_tests.end_test, user_scores.created_at").
+		Rows()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var entries []analytics.Entry
+	names := make(map[string]string)
+	//the scores are read from the oldest attempt, a later one replaces the entry of the user
+	position := make(map[string]int)
+	for rows.Next() {
+		var row participantRow
+		if err := db.ScanRows(rows, &row); err != nil {
+			return nil, nil, err
+		}
+
+		id := row.UserID.String()
+		names[id] = row.Name
+		entry := analytics.Entry{
+			ID:       id,
+			Score:    float64(row.Score),
+			Duration: attemptDuration(row),
+		}
+		if k, ok := position[id]; ok {
+			entries[k] = entry
+			continue
+		}
+		position[id] = len(entries)
+		entries = append(entries, entry)
+	}
+
+	return analytics.Rank(entries), names, rows.Err()
+}
+
+// attemptDuration the time to complete of a finished attempt in seconds, from the attempt times or from FinishTime
+func attemptDuration(row participantRow) *float64 {
+	if row.IsFinished == nil || !*row.IsFinished {
+		return nil
+	}
+
+	if row.StartTest != nil && row.EndTest != nil && row.EndTest.After(*row.StartTest) {
+		seconds := row.EndTest.Sub(*row.StartTest).Seconds()
+		return &seconds
+	}
+
+	if row.FinishTime != nil {
+		if t, err := time.Parse("15:04:05", *row.FinishTime); err == nil {
+			seconds := float64(t.Hour()*3600 + t.Minute()*60 + t.Second())
+			return &seconds
+		}
+	}
+
+	return nil
+}
+
+// leaderboardName the name of a participant following the leaderboard mode of the test
+func leaderboardName(mode string, name string) string {
+	switch mode {
+	case dataModel.LeaderboardNames:
+		return name
+	case dataModel.LeaderboardInitials:
+		var initials []string
+		for _, v := range strings.Fields(name) {
+			initials = append(initials, string(unicode.ToUpper([]rune(v)[0]))+".")
+		}
+		return strings.Join(initials, "")
+	}
+
+	return ""
+}
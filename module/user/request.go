@@ -1,15 +1,53 @@
 package user
 
+// answers of the official attempt attempt_id, or of the last unfinished one when it is not set
 type answerRequest struct {
-	TestID  string       `json:"test_id" binding:"required"`
-	Answers []answerData `json:"answers" binding:"required"`
+	TestID    string       `json:"test_id" binding:"required"`
+	AttemptID string       `json:"attempt_id"`
+	Answers   []answerData `json:"answers" binding:"required"`
 }
 
+// the choices of ordering questions are answered in choice_ids in the order of the user
 type answerData struct {
-	QuestionID string `json:"question_id" binding:"required"`
-	Answer     string `json:"answer" binding:"required"`
+	QuestionID string   `json:"question_id" binding:"required"`
+	ChoiceID   string   `json:"choice_id"`
+	ChoiceIDs  []string `json:"choice_ids"`
+	Answer     string   `json:"answer"`
+	//language of the code answering a programming question
+	Language string `json:"language"`
+	//pairs answering a matching question
+	Matches []matchAnswer `json:"matches"`
+	//answers of the blanks of a cloze question, blank n is answered by the nth one
+	Blanks []string `json:"blanks"`
+}
+
+type matchAnswer struct {
+	ChoiceID string `json:"choice_id"`
+	MatchID  string `json:"match_id"`
+}
+
+// answers of the section section_id of an official attempt, the questions not listed are not answered
+type sectionAnswerRequest struct {
+	AttemptID string       `json:"attempt_id" binding:"required"`
+	SectionID string       `json:"section_id" binding:"required"`
+	Answers   []answerData `json:"answers"`
 }
 
 type attempRequest struct {
 	TestID string `json:"test_id" binding:"required"`
+	Mode   string `json:"mode"`
+}
+
+type recallRequest struct {
+	Quality *int `json:"quality" binding:"required"`
+}
+
+type attemptAnswerRequest struct {
+	AttemptID  string        `json:"attempt_id" binding:"required"`
+	QuestionID string        `json:"question_id" binding:"required"`
+	ChoiceID   string        `json:"choice_id"`
+	ChoiceIDs  []string      `json:"choice_ids"`
+	Answer     string        `json:"answer"`
+	Matches    []matchAnswer `json:"matches"`
+	Blanks     []string      `json:"blanks"`
 }
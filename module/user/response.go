@@ -1,6 +1,11 @@
 package user
 
-import uuid "github.com/satori/go.uuid"
+import (
+	"okkybudiman/srs"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
 
 type result struct {
 	ID                 uuid.UUID `json:"id" binding:"required"`
@@ -11,4 +16,214 @@ type result struct {
 	TotalNotAnswered   int       `json:"total_not_answered" binding:"required"`
 	Score              int       `json:"score" binding:"required"`
 	TimeComplete       string    `json:"time_complete" binding:"required"`
+	Position           int       `json:"position"`
+	PercentileRank     float64   `json:"percentile_rank"`
+	TotalParticipant   int       `json:"total_participant"`
+	Theta              *float64  `json:"theta"`
+	StandardError      *float64  `json:"standard_error"`
+	//subscores of the sections of the test
+	Sections []sectionScore `json:"sections,omitempty"`
+}
+
+type attemptResponse struct {
+	ID     uuid.UUID `json:"id"`
+	TestID uuid.UUID `json:"test_id"`
+	Mode   string    `json:"mode"`
+	//first section of an official attempt of a test with sections
+	Section *sectionStatus `json:"section,omitempty"`
+}
+
+// sectionStatus section an attempt is in, remaining_time is the seconds left before its deadline
+type sectionStatus struct {
+	ID            uuid.UUID  `json:"id"`
+	Name          string     `json:"name"`
+	Number        int        `json:"number"`
+	TotalSection  int        `json:"total_section"`
+	TimeLimit     int        `json:"time_limit"`
+	StartedAt     time.Time  `json:"started_at"`
+	Deadline      *time.Time `json:"deadline"`
+	RemainingTime *int       `json:"remaining_time"`
+}
+
+type sectionScore struct {
+	ID                 uuid.UUID `json:"id"`
+	Name               string    `json:"name"`
+	TotalRightAnswered int       `json:"total_right_answered"`
+	TotalWrongAnswered int       `json:"total_wrong_answered"`
+	TotalNotAnswered   int       `json:"total_not_answered"`
+	Score              int       `json:"score"`
+}
+
+type practiceResult struct {
+	Question reviewQuestion `json:"question"`
+	//feedback of the picked choices
+	Feedback []reviewChoice `json:"feedback"`
+}
+
+type practiceSummary struct {
+	ID                 uuid.UUID `json:"id"`
+	TestID             uuid.UUID `json:"test_id"`
+	TotalQuestion      int       `json:"total_question"`
+	TotalRightAnswered int       `json:"total_right_answered"`
+	TotalWrongAnswered int       `json:"total_wrong_answered"`
+	TotalNotAnswered   int       `json:"total_not_answered"`
+	Point              int       `json:"point"`
+}
+
+type deckItem struct {
+	ID             uuid.UUID       `json:"id"`
+	QuestionID     uuid.UUID       `json:"question_id"`
+	DueAt          time.Time       `json:"due_at"`
+	Interval       int             `json:"interval"`
+	Repetitions    int             `json:"repetitions"`
+	Lapses         int             `json:"lapses"`
+	EaseFactor     float64         `json:"ease_factor"`
+	LastReviewedAt *time.Time      `json:"last_reviewed_at"`
+	Question       servedQuestion  `json:"question"`
+	Answer         *reviewQuestion `json:"answer,omitempty"`
+}
+
+// servedQuestion question sent without its answer
+type servedQuestion struct {
+	ID           uuid.UUID      `json:"id"`
+	Question     string         `json:"question"`
+	QuestionHTML string         `json:"question_html"`
+	Type         string         `json:"type"`
+	Format       string         `json:"format"`
+	Choices      []servedChoice `json:"choices"`
+
+	//language, limits and example test cases of programming questions
+	Language    string           `json:"language,omitempty"`
+	TimeLimit   int              `json:"time_limit,omitempty"`
+	MemoryLimit int              `json:"memory_limit,omitempty"`
+	Examples    []servedTestCase `json:"examples,omitempty"`
+
+	//items the choices of a matching question are paired with
+	Matches []servedMatch `json:"matches,omitempty"`
+	//blanks of a cloze question, blank n is written [[n]] in the question
+	Blanks []servedBlank `json:"blanks,omitempty"`
+}
+
+type servedBlank struct {
+	Number  int      `json:"number"`
+	Type    string   `json:"type"`
+	Options []string `json:"options,omitempty"`
+}
+
+type servedMatch struct {
+	ID        uuid.UUID `json:"id"`
+	Match     string    `json:"match"`
+	MatchHTML string    `json:"match_html"`
+}
+
+type servedTestCase struct {
+	Input          string `json:"input"`
+	ExpectedOutput string `json:"expected_output"`
+}
+
+type servedChoice struct {
+	ID         uuid.UUID `json:"id"`
+	Key        int       `json:"key"`
+	Choice     string    `json:"choice"`
+	ChoiceHTML string    `json:"choice_html"`
+}
+
+// the ability of an adaptive attempt is only sent once it is finished, its change would tell whether an answer is right
+type adaptiveResponse struct {
+	AttemptID     uuid.UUID       `json:"attempt_id"`
+	Finished      bool            `json:"finished"`
+	Answered      int             `json:"answered"`
+	Theta         *float64        `json:"theta"`
+	StandardError *float64        `json:"standard_error"`
+	Question      *servedQuestion `json:"question"`
+}
+
+type deckStats struct {
+	TotalItem     int       `json:"total_item"`
+	Due           int       `json:"due"`
+	New           int       `json:"new"`
+	Mature        int       `json:"mature"`
+	TotalReview   int       `json:"total_review"`
+	TotalRecalled int       `json:"total_recalled"`
+	Retention     float64   `json:"retention"`
+	Days          []srs.Day `json:"days"`
+}
+
+type leaderboardEntry struct {
+	Position       int      `json:"position"`
+	Name           string   `json:"name"`
+	Score          int      `json:"score"`
+	Duration       *float64 `json:"duration"`
+	PercentileRank float64  `json:"percentile_rank"`
+	Me             bool     `json:"me"`
+}
+
+type reviewQuestion struct {
+	ID           uuid.UUID      `json:"id"`
+	SectionID    *uuid.UUID     `json:"section_id,omitempty"`
+	Number       int            `json:"number"`
+	Question     string         `json:"question"`
+	QuestionHTML string         `json:"question_html"`
+	Type         string         `json:"type"`
+	Format       string         `json:"format"`
+	Choices      []reviewChoice `json:"choices"`
+	AnswerKeys   []int          `json:"answer_keys"`
+	Answer       string         `json:"answer"`
+	CorrectKeys  []int          `json:"correct_keys"`
+	Correct      string         `json:"correct_answer"`
+	Status       string         `json:"status"`
+	Point        int            `json:"point"`
+	Explanation  string         `json:"explanation"`
+	//explanation rendered as sanitized html
+	ExplanationHTML string `json:"explanation_html"`
+
+	//language of the code and result of every test case of programming answers
+	Language      string           `json:"language,omitempty"`
+	GradingStatus string           `json:"grading_status,omitempty"`
+	TestCases     []reviewTestCase `json:"test_cases,omitempty"`
+
+	//pairs answered to a matching question, the right pairs are the match of the choices
+	AnswerMatches []reviewMatch `json:"answer_matches,omitempty"`
+	//answer and accepted answers of every blank of a cloze question
+	Blanks []reviewBlank `json:"blanks,omitempty"`
+}
+
+type reviewBlank struct {
+	Number   int      `json:"number"`
+	Type     string   `json:"type"`
+	Options  []string `json:"options,omitempty"`
+	Answer   string   `json:"answer"`
+	Correct  []string `json:"correct_answers"`
+	Point    int      `json:"point"`
+	MaxPoint int      `json:"max_point"`
+}
+
+type reviewMatch struct {
+	Key   int    `json:"key"`
+	Match string `json:"match"`
+}
+
+// reviewTestCase result of a test case, the input, the expected output and the output of hidden test cases are not shown
+type reviewTestCase struct {
+	Key            int    `json:"key"`
+	Hidden         bool   `json:"hidden"`
+	Input          string `json:"input,omitempty"`
+	ExpectedOutput string `json:"expected_output,omitempty"`
+	Output         string `json:"output,omitempty"`
+	Message        string `json:"message,omitempty"`
+	Status         string `json:"status"`
+	Point          int    `json:"point"`
+	MaxPoint       int    `json:"max_point"`
+	TimeMs         int    `json:"time_ms"`
+}
+
+type reviewChoice struct {
+	ID           uuid.UUID `json:"id"`
+	Key          int       `json:"key"`
+	Choice       string    `json:"choice"`
+	ChoiceHTML   string    `json:"choice_html"`
+	Feedback     string    `json:"feedback"`
+	FeedbackHTML string    `json:"feedback_html"`
+	Match        string    `json:"match,omitempty"`
+	MatchHTML    string    `json:"match_html,omitempty"`
 }
@@ -0,0 +1,364 @@
+package user
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	dataModel "okkybudiman/data/model"
+	"okkybudiman/sandbox"
+	u "okkybudiman/utility"
+	"sort"
+	"strings"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+// Review show every question of a submitted test with the answer of the user in the last finished attempt, the
+// correct answer, the point and the explanation. It is available following the review policy of the test.
+func (ctrl *Controller) Review(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+	claims := jwt.ExtractClaims(c)
+	name := claims["id"].(string)
+	var user dataModel.User
+	db.Where("name = ?", name).Find(&user)
+
+	var test dataModel.Test
+	var questions []dataModel.Question
+	var answers []dataModel.UserAnswer
+
+	testID, _ := uuid.FromString(c.Param("id"))
+	if err := db.Where("id = ?", testID).First(&test).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find Test",
+		})
+		return
+	}
+	attempt, score, err := latestScore(db, testID, user.ID)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "test is not submitted yet",
+		})
+		return
+	}
+
+	if message, ok := reviewReleased(test, time.Now()); !ok {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  http.StatusForbidden,
+			"message": message,
+		})
+		return
+	}
+
+	if err := db.Where("test_id = ?", testID).Preload("QuestionChoices").Preload("TestCases").Order("created_at").Find(&questions).Error; err != nil {
+		glog.Errorf("Failed to load questions: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	db.Where("user_attempt_test_id = ?", attempt.ID).Preload("TestCaseResults").Find(&answers)
+
+	answered := make(map[uuid.UUID]dataModel.UserAnswer)
+	for _, v := range answers {
+		answered[v.QuestionID] = v
+	}
+
+	var responses []reviewQuestion
+	for k, q := range questions {
+		//calculated questions are reviewed with the values of the attempt
+		q = instantiateQuestion(q, attempt.Seed)
+
+		res, keys := newReviewQuestion(k+1, q)
+		if v, ok := answered[q.ID]; ok {
+			res.setAnswer(q.Type, keys, v.Response, v.QuestionChoiceID)
+			res.Point = v.Point
+			res.Status = pointStatus(v.Point)
+			if q.Type == dataModel.QuestionTypeProgramming {
+				res.setTestCases(v, q.TestCases)
+				if v.GradingStatus == dataModel.GradingPending {
+					res.Status = answerPending
+				}
+			}
+		}
+		responses = append(responses, res)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "success get review",
+		"data":    responses,
+		"score":   score.Score,
+	})
+	return
+}
+
+// newReviewQuestion build the review of a question with its choices loaded, without the answer of the user.
+// It returns the key of the choices by their id.
+func newReviewQuestion(number int, q dataModel.Question) (reviewQuestion, map[string]int) {
+	sort.Slice(q.QuestionChoices, func(i, j int) bool { return q.QuestionChoices[i].Key < q.QuestionChoices[j].Key })
+
+	res := reviewQuestion{
+		ID:          q.ID,
+		SectionID:   q.SectionID,
+		Number:      number,
+		Question:    q.Question,
+		Type:        q.Type,
+		Status:      answerEmpty,
+		Explanation: q.Explanation,
+
+		QuestionHTML:    u.RenderContent(q.Question, q.Format),
+		Format:          q.Format,
+		ExplanationHTML: u.RenderContent(q.Explanation, q.ExplanationFormat()),
+	}
+	if !q.HasChoices() {
+		res.Correct = q.Answer
+	}
+
+	//the right order of an ordering question is the order of the keys
+	keys := make(map[string]int)
+	for _, v := range q.QuestionChoices {
+		keys[v.ID.String()] = v.Key
+		choice := reviewChoice{
+			ID:           v.ID,
+			Key:          v.Key,
+			Choice:       v.Choice,
+			ChoiceHTML:   u.RenderContent(v.Choice, q.Format),
+			Feedback:     v.Feedback,
+			FeedbackHTML: u.RenderContent(v.Feedback, q.ExplanationFormat()),
+		}
+		if q.Type == dataModel.QuestionTypeMatching {
+			choice.Match = v.Match
+			choice.MatchHTML = u.RenderContent(v.Match, q.Format)
+		}
+		res.Choices = append(res.Choices, choice)
+		if v.IsCorrect || q.Type == dataModel.QuestionTypeOrdering {
+			res.CorrectKeys = append(res.CorrectKeys, v.Key)
+		}
+	}
+	for k, v := range q.ClozeBlanks() {
+		res.Blanks = append(res.Blanks, reviewBlank{Number: k + 1, Type: v.Type, Options: v.Options, Correct: v.Answers, MaxPoint: v.Point})
+	}
+
+	return res, keys
+}
+
+// setAnswer set the answer of the user from a saved response, keys are the key of the choices by their id.
+// The keys of an ordering answer are in the order of the user, the pairs of a matching answer by key. The blanks of a
+// cloze answer get the point they are worth when they are right.
+func (res *reviewQuestion) setAnswer(questionType string, keys map[string]int, response string, choiceID uuid.UUID) {
+	switch questionType {
+	case dataModel.QuestionTypeOrdering:
+		var ids []string
+		json.Unmarshal([]byte(response), &ids)
+		for _, id := range ids {
+			if key, ok := keys[id]; ok {
+				res.AnswerKeys = append(res.AnswerKeys, key)
+			}
+		}
+	case dataModel.QuestionTypeMatching:
+		matches := make(map[string]string)
+		for _, v := range res.Choices {
+			matches[dataModel.MatchID(res.ID, v.Match).String()] = v.Match
+		}
+		var pairs map[string]string
+		json.Unmarshal([]byte(response), &pairs)
+		for id, matchID := range pairs {
+			key, ok := keys[id]
+			match, found := matches[matchID]
+			if ok && found {
+				res.AnswerMatches = append(res.AnswerMatches, reviewMatch{Key: key, Match: match})
+			}
+		}
+		sort.Slice(res.AnswerMatches, func(i, j int) bool { return res.AnswerMatches[i].Key < res.AnswerMatches[j].Key })
+	case dataModel.QuestionTypeCloze:
+		var blanks []string
+		json.Unmarshal([]byte(response), &blanks)
+		for k := range res.Blanks {
+			if k >= len(blanks) {
+				break
+			}
+			res.Blanks[k].Answer = blanks[k]
+			blank := dataModel.ClozeBlank{Type: res.Blanks[k].Type, Answers: res.Blanks[k].Correct}
+			if blankRight(blank, blanks[k]) {
+				res.Blanks[k].Point = res.Blanks[k].MaxPoint
+			}
+		}
+	case dataModel.QuestionTypeProgramming:
+		var code dataModel.ProgrammingResponse
+		json.Unmarshal([]byte(response), &code)
+		res.Answer = code.Code
+		res.Language = code.Language
+	case dataModel.QuestionTypeTextEntry, dataModel.QuestionTypeCalculated:
+		json.Unmarshal([]byte(response), &res.Answer)
+	case dataModel.QuestionTypeMultipleChoice:
+		var ids []string
+		json.Unmarshal([]byte(response), &ids)
+		for _, id := range ids {
+			if key, ok := keys[id]; ok {
+				res.AnswerKeys = append(res.AnswerKeys, key)
+			}
+		}
+		sort.Ints(res.AnswerKeys)
+	default:
+		if key, ok := keys[choiceID.String()]; ok {
+			res.AnswerKeys = append(res.AnswerKeys, key)
+		}
+	}
+}
+
+// setTestCases set the result of every test case of a programming answer, testCases are the test cases of the question
+func (res *reviewQuestion) setTestCases(answer dataModel.UserAnswer, testCases []dataModel.TestCase) {
+	res.GradingStatus = answer.GradingStatus
+
+	results := make(map[uuid.UUID]dataModel.TestCaseResult)
+	for _, v := range answer.TestCaseResults {
+		results[v.TestCaseID] = v
+	}
+
+	sort.Slice(testCases, func(i, j int) bool { return testCases[i].Key < testCases[j].Key })
+	for _, v := range testCases {
+		result, ok := results[v.ID]
+		if !ok {
+			continue
+		}
+		testCase := reviewTestCase{
+			Key:      v.Key,
+			Hidden:   v.Hidden,
+			Status:   result.Status,
+			Point:    result.Point,
+			MaxPoint: v.Point,
+			TimeMs:   result.TimeMs,
+		}
+		if !v.Hidden {
+			testCase.Input = v.Input
+			testCase.ExpectedOutput = v.ExpectedOutput
+			testCase.Output = result.Output
+			testCase.Message = result.Message
+		} else if result.Status == sandbox.StatusCompileError {
+			//compiler errors do not depend on the test case
+			testCase.Message = result.Message
+		}
+		res.TestCases = append(res.TestCases, testCase)
+	}
+}
+
+// reviewReleased check whether the answers of a test can be reviewed at now, the message tells why when they cannot
+func reviewReleased(test dataModel.Test, now time.Time) (string, bool) {
+	switch test.ReviewPolicy {
+	case dataModel.ReviewImmediately:
+		return "", true
+	case dataModel.ReviewAfterClose:
+		if test.AvailableUntil == nil {
+			return "review is available after the test is closed", false
+		}
+		if now.Before(*test.AvailableUntil) {
+			return "review is available after " + test.AvailableUntil.Format(time.RFC3339), false
+		}
+		return "", true
+	}
+
+	return "review is not available for this test", false
+}
+
+// releasedTests query of the ids of the tests whose answers can be reviewed at now, the same tests as reviewReleased
+func releasedTests(db *gorm.DB, now time.Time) interface{} {
+	return db.Table("tests").Select("id").
+		Where("deleted_at IS NULL").
+		Where("review_policy = ? OR (review_policy = ? AND available_until <= ?)", dataModel.ReviewImmediately, dataModel.ReviewAfterClose, now).
+		QueryExpr()
+}
+
+// pointStatus the status of a graded answer from its point
+func pointStatus(point int) string {
+	switch {
+	case point > 0:
+		return answerRight
+	case point < 0:
+		return answerWrong
+	}
+
+	return answerEmpty
+}
+
+// newServedQuestion build the response of a question sent to be answered, without its answer. The choices of matching
+// and ordering questions are shuffled with seed, so an attempt always gets them in the same order, and numbered in
+// that order. The matches are shuffled on their own and sent once, a distractor being only a match.
+func newServedQuestion(q dataModel.Question, seed int64) servedQuestion {
+	res := servedQuestion{
+		ID:           q.ID,
+		Question:     q.Question,
+		QuestionHTML: u.RenderContent(q.Question, q.Format),
+		Type:         q.Type,
+		Format:       q.Format,
+	}
+	if q.Type == dataModel.QuestionTypeMatching || q.Type == dataModel.QuestionTypeOrdering {
+		return arrangeQuestion(res, q, seed)
+	}
+	for _, v := range q.QuestionChoices {
+		res.Choices = append(res.Choices, servedChoice{ID: v.ID, Key: v.Key, Choice: v.Choice, ChoiceHTML: u.RenderContent(v.Choice, q.Format)})
+	}
+	for k, v := range q.ClozeBlanks() {
+		res.Blanks = append(res.Blanks, servedBlank{Number: k + 1, Type: v.Type, Options: v.Options})
+	}
+	if q.Type == dataModel.QuestionTypeProgramming {
+		res.Language = q.Language
+		res.TimeLimit = q.TimeLimit
+		res.MemoryLimit = q.MemoryLimit
+		sort.Slice(q.TestCases, func(i, j int) bool { return q.TestCases[i].Key < q.TestCases[j].Key })
+		for _, v := range q.TestCases {
+			if !v.Hidden {
+				res.Examples = append(res.Examples, servedTestCase{Input: v.Input, ExpectedOutput: v.ExpectedOutput})
+			}
+		}
+	}
+
+	return res
+}
+
+// arrangeQuestion set the shuffled choices and matches of a served matching or ordering question
+func arrangeQuestion(res servedQuestion, q dataModel.Question, seed int64) servedQuestion {
+	choices := append([]dataModel.QuestionChoice{}, q.QuestionChoices...)
+	sort.Slice(choices, func(i, j int) bool { return choices[i].Key < choices[j].Key })
+	r := rand.New(rand.NewSource(questionSeed(seed, q.ID)))
+
+	for _, k := range r.Perm(len(choices)) {
+		v := choices[k]
+		if strings.TrimSpace(v.Choice) == "" {
+			continue
+		}
+		res.Choices = append(res.Choices, servedChoice{
+			ID:         v.ID,
+			Key:        len(res.Choices) + 1,
+			Choice:     v.Choice,
+			ChoiceHTML: u.RenderContent(v.Choice, q.Format),
+		})
+	}
+
+	if q.Type == dataModel.QuestionTypeMatching {
+		seen := make(map[string]bool)
+		var matches []servedMatch
+		for _, v := range choices {
+			if seen[v.Match] {
+				continue
+			}
+			seen[v.Match] = true
+			matches = append(matches, servedMatch{ID: dataModel.MatchID(q.ID, v.Match), Match: v.Match, MatchHTML: u.RenderContent(v.Match, q.Format)})
+		}
+		for _, k := range r.Perm(len(matches)) {
+			res.Matches = append(res.Matches, matches[k])
+		}
+	}
+
+	return res
+}
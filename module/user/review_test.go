@@ -0,0 +1,38 @@
+package user
+
+import (
+	dataModel "okkybudiman/data/model"
+	"testing"
+	"time"
+)
+
+func TestReviewReleased(t *testing.T) {
+	now := time.Date(2020, 3, 1, 10, 0, 0, 0, time.UTC)
+	closed := now.Add(-time.Minute)
+	open := now.Add(time.Minute)
+
+	tests := []struct {
+		name     string
+		test     dataModel.Test
+		released bool
+	}{
+		{"immediately", dataModel.Test{ReviewPolicy: dataModel.ReviewImmediately}, true},
+		{"immediately while open", dataModel.Test{ReviewPolicy: dataModel.ReviewImmediately, AvailableUntil: &open}, true},
+		{"after close once closed", dataModel.Test{ReviewPolicy: dataModel.ReviewAfterClose, AvailableUntil: &closed}, true},
+		{"after close at the closing time", dataModel.Test{ReviewPolicy: dataModel.ReviewAfterClose, AvailableUntil: &now}, true},
+		{"after close while open", dataModel.Test{ReviewPolicy: dataModel.ReviewAfterClose, AvailableUntil: &open}, false},
+		{"after close never closing", dataModel.Test{ReviewPolicy: dataModel.ReviewAfterClose}, false},
+		{"never", dataModel.Test{ReviewPolicy: dataModel.ReviewNever, AvailableUntil: &closed}, false},
+		{"no policy", dataModel.Test{}, false},
+	}
+
+	for _, tt := range tests {
+		message, released := reviewReleased(tt.test, now)
+		if released != tt.released {
+			t.Errorf("%s: released %v, want %v", tt.name, released, tt.released)
+		}
+		if released == (message != "") {
+			t.Errorf("%s: message %q with released %v", tt.name, message, released)
+		}
+	}
+}
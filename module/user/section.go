@@ -0,0 +1,376 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	dataModel "okkybudiman/data/model"
+	u "okkybudiman/utility"
+	"sort"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+// answers of a section sent up to sectionGrace after its deadline are accepted, they may be delayed on the way
+const sectionGrace = 30 * time.Second
+
+// errSectionLocked the section is already submitted
+var errSectionLocked = errors.New("section is locked")
+
+// sectionState progress of an official attempt through the sections of its test, current is nil once every
+// section is locked
+type sectionState struct {
+	sections []dataModel.Section
+	current  *dataModel.Section
+	number   int
+	started  dataModel.UserAttemptSection
+}
+
+// SubmitSection grade and save the answers of the current section of an official attempt, lock it and start the next
+// one. The attempt is finished with the last section. Questions of the section which are not answered are saved as
+// not answered.
+func (ctrl *Controller) SubmitSection(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+	claims := jwt.ExtractClaims(c)
+	name := claims["id"].(string)
+	var user dataModel.User
+	db.Where("name = ?", name).Find(&user)
+
+	var req sectionAnswerRequest
+	if !u.BindJSON(c, &req) {
+		return
+	}
+	if errors := validateCode(req.Answers); len(errors) > 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": errors})
+		return
+	}
+
+	var attempt dataModel.UserAttemptTest
+	attemptID, _ := uuid.FromString(req.AttemptID)
+	if err := db.Where("id = ? AND user_id = ? AND mode = ?", attemptID, user.ID, dataModel.AttemptModeOfficial).First(&attempt).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find attempt",
+		})
+		return
+	}
+	if attempt.IsFinished {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  http.StatusForbidden,
+			"message": "attempt is already finished",
+		})
+		return
+	}
+
+	now := time.Now()
+	state, err := loadSectionState(db, &attempt, now)
+	if err != nil {
+		glog.Errorf("Failed to load sections: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if len(state.sections) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  http.StatusForbidden,
+			"message": "test has no sections",
+		})
+		return
+	}
+
+	//the section may have been locked by its time limit since the user got its questions
+	sectionID, _ := uuid.FromString(req.SectionID)
+	if state.current == nil || state.current.ID != sectionID {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  http.StatusForbidden,
+			"message": "section is locked, its time is up or it is already submitted",
+		})
+		return
+	}
+
+	var questions []dataModel.Question
+	db.Where("test_id = ? AND section_id = ?", attempt.TestID, sectionID).Find(&questions)
+	inSection := make(map[string]bool)
+	for _, v := range questions {
+		inSection[v.ID.String()] = true
+	}
+	answers := make(map[string]answerData)
+	for _, v := range req.Answers {
+		if !inSection[v.QuestionID] {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": []string{fmt.Sprintf("question %s is not in the section", v.QuestionID)}})
+			return
+		}
+		answers[v.QuestionID] = v
+	}
+
+	if err := closeSection(db, attempt, *state.current, answers, now); err != nil {
+		if err == errSectionLocked {
+			c.JSON(http.StatusForbidden, gin.H{
+				"status":  http.StatusForbidden,
+				"message": "section is locked, its time is up or it is already submitted",
+			})
+			return
+		}
+		glog.Errorf("Failed to submit section: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	//the next section starts now
+	if state, err = loadSectionState(db, &attempt, now); err != nil {
+		glog.Errorf("Failed to start next section: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"message":  "success submit section",
+		"finished": attempt.IsFinished,
+		"section":  newSectionStatus(state, now),
+	})
+	return
+}
+
+// loadSectionState get the section an official attempt is in. Sections are started one after the other, a section
+// whose time is up is locked with the answers already saved and the next one starts at its deadline. The attempt is
+// finished once every section is locked. It returns no sections for tests without sections.
+func loadSectionState(db *gorm.DB, attempt *dataModel.UserAttemptTest, now time.Time) (sectionState, error) {
+	var state sectionState
+	var started []dataModel.UserAttemptSection
+
+	if err := db.Where("test_id = ?", attempt.TestID).Find(&state.sections).Error; err != nil {
+		return state, err
+	}
+	sortSections(state.sections)
+	if len(state.sections) == 0 || attempt.Mode != dataModel.AttemptModeOfficial {
+		return sectionState{}, nil
+	}
+	if err := db.Where("user_attempt_test_id = ?", attempt.ID).Find(&started).Error; err != nil {
+		return state, err
+	}
+
+	rows := make(map[uuid.UUID]dataModel.UserAttemptSection)
+	for _, v := range started {
+		rows[v.SectionID] = v
+	}
+
+	startAt := now
+	end := attempt.StartTest
+	for k, v := range state.sections {
+		row, ok := rows[v.ID]
+		if !ok && !attempt.IsFinished {
+			//a request loading the attempt at the same time may start the section first, its row is kept
+			row = dataModel.UserAttemptSection{UserAttemptTestID: attempt.ID, SectionID: v.ID, StartedAt: startAt}
+			if err := db.Create(&row).Error; err != nil {
+				row = dataModel.UserAttemptSection{}
+				if db.Where("user_attempt_test_id = ? AND section_id = ?", attempt.ID, v.ID).First(&row).Error != nil {
+					return state, err
+				}
+			}
+		}
+		if row.SubmittedAt != nil || attempt.IsFinished {
+			if row.SubmittedAt != nil && row.SubmittedAt.After(end) {
+				end = *row.SubmittedAt
+			}
+			startAt = now
+			continue
+		}
+
+		deadline := v.Deadline(row.StartedAt)
+		if deadline != nil && now.After(deadline.Add(sectionGrace)) {
+			if err := closeSection(db, *attempt, v, nil, *deadline); err != nil && err != errSectionLocked {
+				return state, err
+			}
+			startAt = *deadline
+			end = *deadline
+			continue
+		}
+
+		state.current = &state.sections[k]
+		state.number = k + 1
+		state.started = row
+		return state, nil
+	}
+
+	if !attempt.IsFinished {
+		return state, finishSections(db, attempt, end)
+	}
+	return state, nil
+}
+
+// closeSection lock a section of an attempt at closedAt and save the answers of its questions by question id,
+// the questions without answer are saved as not answered. It returns errSectionLocked when it is already locked.
+func closeSection(db *gorm.DB, attempt dataModel.UserAttemptTest, section dataModel.Section, answers map[string]answerData, closedAt time.Time) error {
+	tx := db.Begin()
+	res := tx.Model(&dataModel.UserAttemptSection{}).
+		Where("user_attempt_test_id = ? AND section_id = ? AND submitted_at IS NULL", attempt.ID, section.ID).
+		Update("submitted_at", closedAt)
+	if res.Error != nil {
+		tx.Rollback()
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		tx.Rollback()
+		return errSectionLocked
+	}
+
+	var questions []dataModel.Question
+	if err := tx.Where("test_id = ? AND section_id = ?", attempt.TestID, section.ID).Preload("QuestionChoices").Find(&questions).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, q := range questions {
+		data := answers[q.ID.String()]
+		data.QuestionID = q.ID.String()
+		if _, _, err := saveAnswer(tx, attempt.UserID, attempt.TestID, attempt, q, q.QuestionChoices, data); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// finishSections finish an attempt whose sections are all locked, the last one at end. Its score is counted from its
+// answers and its programming answers are queued to be graded. The attempt is finished by the first request seeing
+// its last section locked, the other ones only reload it.
+func finishSections(db *gorm.DB, attempt *dataModel.UserAttemptTest, end time.Time) error {
+	finishTime := time.Time{}.Add(end.Sub(attempt.StartTest)).Format("15:04:05")
+
+	tx := db.Begin()
+	res := tx.Model(&dataModel.UserAttemptTest{}).Where("id = ? AND is_finished = ?", attempt.ID, false).
+		Updates(map[string]interface{}{"is_finished": true, "end_test": end, "finish_time": finishTime})
+	if res.Error != nil {
+		tx.Rollback()
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		tx.Rollback()
+		return db.Where("id = ?", attempt.ID).First(attempt).Error
+	}
+
+	score := dataModel.UserScore{UserID: attempt.UserID, TestID: attempt.TestID, UserAttemptTestID: attempt.ID}
+	if err := tx.Create(&score).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := rescoreAttempt(tx, attempt.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	//the code is graded once the score exists, the grader updates it with the points of the test cases
+	var pending []dataModel.UserAnswer
+	if err := tx.Where("user_attempt_test_id = ? AND grading_status = ?", attempt.ID, dataModel.GradingPending).Find(&pending).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, v := range pending {
+		if err := tx.Create(&dataModel.GradingJob{UserAnswerID: v.ID, Status: dataModel.GradingJobQueued}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	attempt.IsFinished = true
+	attempt.EndTest = end
+	attempt.FinishTime = finishTime
+	return nil
+}
+
+// sortSections sort sections by their key, the order they are answered in
+func sortSections(sections []dataModel.Section) {
+	sort.Slice(sections, func(i, j int) bool { return sections[i].Key < sections[j].Key })
+}
+
+// newSectionStatus the section an attempt is in with the time left to answer it, nil once every section is locked
+func newSectionStatus(state sectionState, now time.Time) *sectionStatus {
+	if state.current == nil {
+		return nil
+	}
+
+	res := sectionStatus{
+		ID:           state.current.ID,
+		Name:         state.current.Name,
+		Number:       state.number,
+		TotalSection: len(state.sections),
+		TimeLimit:    state.current.TimeLimit,
+		StartedAt:    state.started.StartedAt,
+		Deadline:     state.current.Deadline(state.started.StartedAt),
+	}
+	if res.Deadline != nil {
+		remaining := int(res.Deadline.Sub(now).Seconds())
+		if remaining < 0 {
+			remaining = 0
+		}
+		res.RemainingTime = &remaining
+	}
+
+	return &res
+}
+
+// sectionScores the subscore of every section of a test in an attempt, counted from the answers of the attempt
+func sectionScores(db *gorm.DB, testID uuid.UUID, attemptID uuid.UUID) ([]sectionScore, error) {
+	var sections []dataModel.Section
+	var questions []dataModel.Question
+	var answers []dataModel.UserAnswer
+
+	if err := db.Where("test_id = ?", testID).Find(&sections).Error; err != nil {
+		return nil, err
+	}
+	sortSections(sections)
+	if len(sections) == 0 {
+		return nil, nil
+	}
+	if err := db.Where("test_id = ? AND section_id IS NOT NULL", testID).Find(&questions).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("user_attempt_test_id = ?", attemptID).Find(&answers).Error; err != nil {
+		return nil, err
+	}
+
+	bySection := make(map[uuid.UUID]int)
+	scores := make([]sectionScore, len(sections))
+	for k, v := range sections {
+		bySection[v.ID] = k
+		scores[k] = sectionScore{ID: v.ID, Name: v.Name}
+	}
+	sectionOf := make(map[uuid.UUID]int)
+	for _, v := range questions {
+		if k, ok := bySection[*v.SectionID]; ok {
+			sectionOf[v.ID] = k
+		}
+	}
+
+	for _, v := range answers {
+		k, ok := sectionOf[v.QuestionID]
+		if !ok {
+			continue
+		}
+		scores[k].Score += v.Point
+		switch pointStatus(v.Point) {
+		case answerRight:
+			scores[k].TotalRightAnswered++
+		case answerWrong:
+			scores[k].TotalWrongAnswered++
+		default:
+			scores[k].TotalNotAnswered++
+		}
+	}
+
+	return scores, nil
+}
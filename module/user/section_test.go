@@ -0,0 +1,223 @@
+package user
+
+import (
+	dataModel "okkybudiman/data/model"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// openTestDB an empty database in memory with the tables of the attempts
+func openTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//every connection to :memory: opens a database of its own
+	db.DB().SetMaxOpenConns(1)
+	err = db.AutoMigrate(&dataModel.Test{}, &dataModel.Section{}, &dataModel.Question{}, &dataModel.QuestionChoice{},
+		&dataModel.UserAttemptTest{}, &dataModel.UserAttemptSection{}, &dataModel.UserAnswer{}, &dataModel.UserScore{},
+		&dataModel.GradingJob{}, &dataModel.ReviewItem{}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// sectionFixture a test with a first section of a minute and a second one without limit, one text entry question each
+type sectionFixture struct {
+	test      dataModel.Test
+	sections  []dataModel.Section
+	questions []dataModel.Question
+}
+
+func newSectionFixture(t *testing.T, db *gorm.DB) sectionFixture {
+	var f sectionFixture
+	f.test = dataModel.Test{Name: "sections", ReviewPolicy: dataModel.ReviewNever}
+	if err := db.Create(&f.test).Error; err != nil {
+		t.Fatal(err)
+	}
+	for k, limit := range []int{60, 0} {
+		section := dataModel.Section{TestID: f.test.ID, Key: k + 1, TimeLimit: limit}
+		if err := db.Create(&section).Error; err != nil {
+			t.Fatal(err)
+		}
+		question := dataModel.Question{TestID: f.test.ID, SectionID: &section.ID, Type: dataModel.QuestionTypeTextEntry, Answer: "right"}
+		if err := db.Create(&question).Error; err != nil {
+			t.Fatal(err)
+		}
+		f.sections = append(f.sections, section)
+		f.questions = append(f.questions, question)
+	}
+
+	return f
+}
+
+func (f sectionFixture) attempt(t *testing.T, db *gorm.DB, start time.Time) *dataModel.UserAttemptTest {
+	attempt := dataModel.UserAttemptTest{TestID: f.test.ID, StartTest: start, EndTest: start, Mode: dataModel.AttemptModeOfficial}
+	if err := db.Create(&attempt).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return &attempt
+}
+
+func TestSectionSubmitted(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	f := newSectionFixture(t, db)
+	start := time.Date(2020, 3, 1, 10, 0, 0, 0, time.UTC)
+	attempt := f.attempt(t, db, start)
+
+	//the first section starts with the attempt
+	if _, err := loadSectionState(db, attempt, start); err != nil {
+		t.Fatal(err)
+	}
+	state, err := loadSectionState(db, attempt, start.Add(10*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.current == nil || state.current.ID != f.sections[0].ID || state.number != 1 {
+		t.Fatalf("attempt is in %v, want the first section", state.current)
+	}
+	status := newSectionStatus(state, start.Add(10*time.Second))
+	if status.RemainingTime == nil || *status.RemainingTime != 50 || status.TotalSection != 2 {
+		t.Errorf("got %+v, want 50 seconds left of 2 sections", status)
+	}
+
+	answers := map[string]answerData{f.questions[0].ID.String(): {Answer: "right"}}
+	if err := closeSection(db, *attempt, f.sections[0], answers, start.Add(20*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	//a locked section cannot be submitted again
+	if err := closeSection(db, *attempt, f.sections[0], nil, start.Add(30*time.Second)); err != errSectionLocked {
+		t.Errorf("second submit returned %v, want %v", err, errSectionLocked)
+	}
+
+	state, err = loadSectionState(db, attempt, start.Add(40*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.current == nil || state.current.ID != f.sections[1].ID || !state.started.StartedAt.Equal(start.Add(40*time.Second)) {
+		t.Fatalf("attempt is in %v started at %v, want the second section started when it is loaded", state.current, state.started.StartedAt)
+	}
+	if status := newSectionStatus(state, start.Add(40*time.Second)); status.Deadline != nil || status.RemainingTime != nil {
+		t.Errorf("got %+v, want no time limit", status)
+	}
+
+	if err := closeSection(db, *attempt, f.sections[1], nil, start.Add(50*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if state, err = loadSectionState(db, attempt, start.Add(50*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if state.current != nil || !attempt.IsFinished || !attempt.EndTest.Equal(start.Add(50*time.Second)) {
+		t.Errorf("attempt in %v finished %v at %v, want finished at the last submit", state.current, attempt.IsFinished, attempt.EndTest)
+	}
+
+	var score dataModel.UserScore
+	if err := db.Where("user_attempt_test_id = ?", attempt.ID).First(&score).Error; err != nil {
+		t.Fatal(err)
+	}
+	if score.Score != pointRight || score.TotalRightAnswered != 1 || score.TotalNotAnswered != 1 {
+		t.Errorf("got score %d with %d right and %d not answered, want %d with 1 and 1",
+			score.Score, score.TotalRightAnswered, score.TotalNotAnswered, pointRight)
+	}
+}
+
+func TestSectionTimeUp(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	f := newSectionFixture(t, db)
+	start := time.Date(2020, 3, 1, 10, 0, 0, 0, time.UTC)
+	attempt := f.attempt(t, db, start)
+	deadline := start.Add(time.Minute)
+
+	if _, err := loadSectionState(db, attempt, start); err != nil {
+		t.Fatal(err)
+	}
+
+	//answers are still accepted during the grace after the deadline
+	state, err := loadSectionState(db, attempt, deadline.Add(sectionGrace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.current == nil || state.current.ID != f.sections[0].ID {
+		t.Fatalf("attempt is in %v, want the first section", state.current)
+	}
+	if status := newSectionStatus(state, deadline.Add(sectionGrace)); *status.RemainingTime != 0 {
+		t.Errorf("got %d seconds left, want 0", *status.RemainingTime)
+	}
+
+	//past the grace the section is locked at its deadline and the next one starts then
+	state, err = loadSectionState(db, attempt, deadline.Add(sectionGrace+time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.current == nil || state.current.ID != f.sections[1].ID || !state.started.StartedAt.Equal(deadline) {
+		t.Fatalf("attempt is in %v started at %v, want the second section started at the deadline", state.current, state.started.StartedAt)
+	}
+	if err := closeSection(db, *attempt, f.sections[0], nil, deadline); err != errSectionLocked {
+		t.Errorf("submit after the deadline returned %v, want %v", err, errSectionLocked)
+	}
+
+	var locked dataModel.UserAttemptSection
+	db.Where("user_attempt_test_id = ? AND section_id = ?", attempt.ID, f.sections[0].ID).First(&locked)
+	if locked.SubmittedAt == nil || !locked.SubmittedAt.Equal(deadline) {
+		t.Errorf("first section submitted at %v, want %v", locked.SubmittedAt, deadline)
+	}
+	var answer dataModel.UserAnswer
+	if err := db.Where("user_attempt_test_id = ? AND question_id = ?", attempt.ID, f.questions[0].ID).First(&answer).Error; err != nil {
+		t.Fatal(err)
+	}
+	if answer.Point != pointEmpty {
+		t.Errorf("unanswered question got %d points, want %d", answer.Point, pointEmpty)
+	}
+}
+
+func TestSectionFinishedOnce(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+	f := newSectionFixture(t, db)
+	start := time.Date(2020, 3, 1, 10, 0, 0, 0, time.UTC)
+	attempt := f.attempt(t, db, start)
+
+	if _, err := loadSectionState(db, attempt, start); err != nil {
+		t.Fatal(err)
+	}
+	//a section is started once by attempt
+	duplicate := dataModel.UserAttemptSection{UserAttemptTestID: attempt.ID, SectionID: f.sections[0].ID, StartedAt: start}
+	if err := db.Create(&duplicate).Error; err == nil {
+		t.Error("section started twice, want the second start refused")
+	}
+
+	if err := closeSection(db, *attempt, f.sections[0], nil, start.Add(10*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadSectionState(db, attempt, start.Add(10*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := closeSection(db, *attempt, f.sections[1], nil, start.Add(20*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	//two requests loaded the attempt before its last section was locked, both see it finished
+	other := *attempt
+	for _, v := range []*dataModel.UserAttemptTest{attempt, &other} {
+		if _, err := loadSectionState(db, v, start.Add(30*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		if !v.IsFinished || !v.EndTest.Equal(start.Add(20*time.Second)) {
+			t.Errorf("attempt finished %v at %v, want finished at the last submit", v.IsFinished, v.EndTest)
+		}
+	}
+
+	var total int
+	db.Model(&dataModel.UserScore{}).Where("user_attempt_test_id = ?", attempt.ID).Count(&total)
+	if total != 1 {
+		t.Errorf("attempt has %d scores, want 1", total)
+	}
+}
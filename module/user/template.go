@@ -0,0 +1,150 @@
+package user
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	dataModel "okkybudiman/data/model"
+	"okkybudiman/formula"
+	"sort"
+	"time"
+
+	jwt "github.com/appleboy/gin-jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	uuid "github.com/satori/go.uuid"
+)
+
+// instantiateQuestion draw the values of a calculated question from seed and render its text, its explanation and
+// its answer with them. The values only depend on the seed and the question, so grading and review of an attempt
+// get the same numbers. Other questions are returned as they are.
+func instantiateQuestion(q dataModel.Question, seed int64) dataModel.Question {
+	if q.Type != dataModel.QuestionTypeCalculated {
+		return q
+	}
+
+	var variables []formula.Variable
+	if q.Variables != "" {
+		if err := json.Unmarshal([]byte(q.Variables), &variables); err != nil {
+			glog.Errorf("Failed to read variables of question %s: %s", q.ID, err)
+			q.Answer = ""
+			return q
+		}
+	}
+	template, err := formula.NewTemplate(variables, q.AnswerExpression)
+	if err != nil {
+		glog.Errorf("Failed to read template of question %s: %s", q.ID, err)
+		q.Answer = ""
+		return q
+	}
+
+	sample, err := template.Draw(questionSeed(seed, q.ID))
+	if err != nil {
+		glog.Errorf("Failed to draw values of question %s: %s", q.ID, err)
+		q.Answer = ""
+		return q
+	}
+
+	q.Question = template.Render(q.Question, sample)
+	q.Explanation = template.Render(q.Explanation, sample)
+	q.Answer = formula.Format(sample.Answer)
+
+	return q
+}
+
+// newSeed a random seed for a new attempt
+func newSeed() int64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return time.Now().UnixNano()
+	}
+
+	return int64(binary.LittleEndian.Uint64(b[:]))
+}
+
+// questionSeed the seed of a question in an attempt, every question of the attempt draws different values
+func questionSeed(seed int64, questionID uuid.UUID) int64 {
+	h := fnv.New64a()
+	h.Write(questionID.Bytes())
+
+	return seed ^ int64(h.Sum64())
+}
+
+// itemSeed the seed of the calculated questions of a review item, a review is drawn with the values of its item
+func itemSeed(itemID uuid.UUID) int64 {
+	h := fnv.New64a()
+	h.Write(itemID.Bytes())
+
+	return int64(h.Sum64())
+}
+
+// AttemptQuestions get the questions of an official or practice attempt without their answer, calculated questions
+// are sent with the values drawn for the attempt. Official attempts of a test with sections only get the questions of
+// the section they are in.
+func (ctrl *Controller) AttemptQuestions(c *gin.Context) {
+	db, err := ctrl.dbFactory.DBConnection()
+	if err != nil {
+		glog.Errorf("Failed to open db connection: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer db.Close()
+	claims := jwt.ExtractClaims(c)
+	name := claims["id"].(string)
+	var user dataModel.User
+	db.Where("name = ?", name).Find(&user)
+
+	var attempt dataModel.UserAttemptTest
+	var questions []dataModel.Question
+
+	//adaptive attempts are served one question at a time
+	attemptID, _ := uuid.FromString(c.Param("id"))
+	if err := db.Where("id = ? AND user_id = ? AND mode <> ?", attemptID, user.ID, dataModel.AttemptModeAdaptive).First(&attempt).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "cannot find attempt",
+		})
+		return
+	}
+
+	now := time.Now()
+	state, err := loadSectionState(db, &attempt, now)
+	if err != nil {
+		glog.Errorf("Failed to load sections: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	query := db.Where("test_id = ?", attempt.TestID)
+	if len(state.sections) > 0 {
+		if state.current == nil {
+			c.JSON(http.StatusForbidden, gin.H{
+				"status":  http.StatusForbidden,
+				"message": "every section of the attempt is submitted",
+			})
+			return
+		}
+		query = query.Where("section_id = ?", state.current.ID)
+	}
+
+	if err := query.Preload("QuestionChoices").Preload("TestCases").Order("created_at").Find(&questions).Error; err != nil {
+		glog.Errorf("Failed to load questions: %s", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	var responses []servedQuestion
+	for _, v := range questions {
+		sort.Slice(v.QuestionChoices, func(i, j int) bool { return v.QuestionChoices[i].Key < v.QuestionChoices[j].Key })
+		responses = append(responses, newServedQuestion(instantiateQuestion(v, attempt.Seed), attempt.Seed))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "success get questions",
+		"data":    responses,
+		"section": newSectionStatus(state, now),
+	})
+	return
+}
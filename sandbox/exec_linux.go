@@ -0,0 +1,266 @@
+//go:build linux
+// +build linux
+
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// wall clock allowed to a run on top of its cpu time, a run waiting without using the cpu is killed after it
+const wallMargin = time.Second
+
+// exit code of the shell when the sandbox cannot be set up
+const setupFailed = 125
+
+// temporary directory shared by the processes of the server, the code gets an empty one of its own
+const privateTmp = "/tmp"
+
+// execute run args in dir with the limits, the program can write files up to fileSize bytes. The resource limits
+// are set by the shell before it executes the program, with isolation the program runs in new user, network, pid,
+// ipc, uts and mount namespaces so it has no network and cannot see nor signal the other processes. The process
+// group is killed when the run takes too long or writes too much.
+func (r *Runner) execute(dir string, args []string, env []string, stdin string, limits Limits, fileSize int64) (execution, error) {
+	//the cpu limit is rounded up to the second and a second is added, so a run killed by it has used more than
+	//its time and is graded as such
+	cpu := int64((limits.Time+time.Second-1)/time.Second) + 1
+	//the memory is limited with the data segment, which counts the memory written to but not the address space the go
+	//runtime reserves when it starts
+	script := fmt.Sprintf("ulimit -d %d && ulimit -t %d && ulimit -f %d", limits.Memory>>10, cpu, fileSize>>10)
+	if r.cfg.MaxProcesses > 0 {
+		script += fmt.Sprintf(" && ulimit -u %d", r.cfg.MaxProcesses)
+	}
+	script += ` && exec "$@"`
+	if r.cfg.Isolate {
+		script = r.isolation(dir, env) + script
+	}
+
+	cmd := exec.Command("/bin/sh", append([]string{"-c", script, "sh"}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Pdeathsig: syscall.SIGKILL}
+	if r.cfg.Isolate {
+		cmd.SysProcAttr.Cloneflags = syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET | syscall.CLONE_NEWPID |
+			syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS | syscall.CLONE_NEWNS
+		cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+		cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+	}
+
+	var mu sync.Mutex
+	var started bool
+	kill := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if started {
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+	}
+	stdout := &limitedBuffer{max: maxOutput, onExceed: kill}
+	stderr := &limitedBuffer{max: maxMessage * 4}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	mu.Lock()
+	if err := cmd.Start(); err != nil {
+		mu.Unlock()
+		return execution{}, err
+	}
+	started = true
+	mu.Unlock()
+
+	var timedOut bool
+	timer := time.AfterFunc(time.Duration(cpu)*time.Second+wallMargin, func() {
+		mu.Lock()
+		timedOut = true
+		mu.Unlock()
+		kill()
+	})
+	err := cmd.Wait()
+	timer.Stop()
+	//the children left by the program are killed with it
+	kill()
+
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return execution{}, err
+		}
+	}
+	if cmd.ProcessState.Sys().(syscall.WaitStatus).ExitStatus() == setupFailed && strings.HasPrefix(stderr.String(), "sandbox: ") {
+		return execution{}, fmt.Errorf("%s", strings.TrimSpace(stderr.String()))
+	}
+
+	ran := execution{
+		stdout:         stdout.String(),
+		stderr:         stderr.String(),
+		outputExceeded: stdout.exceeded,
+	}
+	mu.Lock()
+	ran.timedOut = timedOut
+	mu.Unlock()
+
+	if usage, ok := cmd.ProcessState.SysUsage().(*syscall.Rusage); ok {
+		ran.cpu = time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+	}
+	status := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	switch {
+	case status.Signaled():
+		ran.exitCode = 128 + int(status.Signal())
+		if status.Signal() == syscall.SIGXCPU {
+			ran.timedOut = true
+		}
+	default:
+		ran.exitCode = status.ExitStatus()
+	}
+
+	return ran, nil
+}
+
+// isolation the commands hiding the server from the code: the processes of the new pid namespace are the only ones
+// in /proc, /tmp is private to the run, and the working directory, the home of the server, the shared go cache and
+// the hidden paths are covered with empty read only directories. The paths the run needs, the directory of the run
+// and the compilers, are bound back in place when they are inside a hidden path. The compiler given the shared go
+// cache in env sees it through an overlay, its writes are kept in the directory of the run.
+func (r *Runner) isolation(dir string, env []string) string {
+	fail := fmt.Sprintf(` || { echo "sandbox: cannot %%s" >&2; exit %d; }`, setupFailed)
+
+	cache := ""
+	shared := false
+	if r.cfg.GoCache != "" {
+		cache = filepath.Clean(r.cfg.GoCache)
+		for _, v := range env {
+			shared = shared || v == "GOCACHE="+r.cfg.GoCache
+		}
+	}
+
+	//the shared cache is covered by its overlay instead
+	paths := append([]string{privateTmp}, r.cfg.HiddenPaths...)
+	if !shared {
+		paths = append(paths, cache)
+	}
+	if wd, err := os.Getwd(); err == nil {
+		paths = append(paths, wd)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, home)
+	}
+	hidden := hiddenPaths(paths)
+
+	//the paths needed inside a hidden path are bound in the directory of the run before they are hidden, the
+	//directory of the run is kept by the shell as its working directory and bound back from the /proc of the server
+	script := "mkdir -p .sandbox" + fmt.Sprintf(fail, "prepare the directory of the run") + " && "
+	var staged, stages []string
+	needed := []string{r.goRoot, r.python}
+	if shared {
+		needed = append(needed, cache)
+	}
+	for _, v := range needed {
+		if v == "" || !contains(v, hidden) {
+			continue
+		}
+		stage := fmt.Sprintf(".sandbox/%d", len(staged))
+		script += mountPoint(stage, v) + " && mount --bind " + quote(v) + " " + stage + fmt.Sprintf(fail, "bind "+quote(v)) + " && "
+		staged = append(staged, v)
+		stages = append(stages, stage)
+	}
+
+	for _, v := range hidden {
+		if v == privateTmp {
+			script += fmt.Sprintf("mount -t tmpfs -o size=%d,mode=1777 tmpfs %s", maxFileSize, v) + fmt.Sprintf(fail, "hide "+v) + " && "
+			continue
+		}
+		script += "mount -t tmpfs -o size=4k,mode=0555 tmpfs " + quote(v) + fmt.Sprintf(fail, "hide "+quote(v)) + " && "
+	}
+
+	if contains(dir, hidden) {
+		script += "mkdir -p " + quote(dir) + " && mount --no-canonicalize --rbind /proc/self/cwd " + quote(dir) +
+			fmt.Sprintf(fail, "bind the directory of the run") + " && "
+	}
+	for k, v := range staged {
+		if v == cache {
+			continue
+		}
+		script += mountPoint(v, v) + " && mount --no-canonicalize --bind " + stages[k] + " " + quote(v) + fmt.Sprintf(fail, "bind "+quote(v)) + " && "
+	}
+	if shared {
+		lower := quote(cache)
+		for k, v := range staged {
+			if v == cache {
+				lower = stages[k]
+				script += "mkdir -p " + quote(cache) + " && "
+			}
+		}
+		script += "mkdir -p .sandbox/cache .sandbox/cache-work && mount -t overlay overlay -o lowerdir=" + lower +
+			",upperdir=.sandbox/cache,workdir=.sandbox/cache-work " + quote(cache) + fmt.Sprintf(fail, "protect the go cache") + " && "
+	}
+
+	for _, v := range hidden {
+		if v != privateTmp {
+			script += "mount -o remount,ro " + quote(v) + fmt.Sprintf(fail, "hide "+quote(v)) + " && "
+		}
+	}
+
+	return script + "{ mount -t proc proc /proc 2>/dev/null || mount -t tmpfs -o ro,size=4k tmpfs /proc; }" +
+		fmt.Sprintf(fail, "hide /proc") + " && "
+}
+
+// hiddenPaths the existing absolute paths to hide, without the ones inside another one of them
+func hiddenPaths(paths []string) []string {
+	var cleaned, hidden []string
+	for _, v := range paths {
+		path := filepath.Clean(v)
+		if v == "" || path == "/" || !filepath.IsAbs(path) {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		cleaned = append(cleaned, path)
+	}
+
+	for k, v := range cleaned {
+		covered := false
+		for i, parent := range cleaned {
+			if i != k && contains(v, []string{parent}) && (v != parent || i < k) {
+				covered = true
+			}
+		}
+		if !covered {
+			hidden = append(hidden, v)
+		}
+	}
+
+	return hidden
+}
+
+// mountPoint the command creating the mount point at path of the file or directory like
+func mountPoint(path string, like string) string {
+	if info, err := os.Stat(like); err == nil && !info.IsDir() {
+		return "mkdir -p " + quote(filepath.Dir(path)) + " && touch " + quote(path)
+	}
+
+	return "mkdir -p " + quote(path)
+}
+
+// contains check whether path is one of the paths or is inside one of them
+func contains(path string, paths []string) bool {
+	for _, v := range paths {
+		if v != "" && (v == path || strings.HasPrefix(path, strings.TrimSuffix(v, string(filepath.Separator))+string(filepath.Separator))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// quote quote a word for the shell
+func quote(word string) string {
+	return "'" + strings.Replace(word, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,166 @@
+//go:build linux
+// +build linux
+
+package sandbox
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"okkybudiman/config"
+)
+
+// judge judge python code against a case, the test is skipped when python or the namespaces are not available
+func judge(t *testing.T, isolate bool, code string, c Case, limits Limits) Result {
+	t.Helper()
+
+	r := New(config.SandboxConfiguration{Isolate: isolate})
+	if r.python == "" {
+		t.Skip("python is not installed")
+	}
+	results, err := r.Judge(LanguagePython, code, []Case{c}, limits)
+	if err != nil && isolate && (strings.Contains(err.Error(), "operation not permitted") || strings.HasPrefix(err.Error(), "sandbox: ")) {
+		t.Skipf("namespaces are not available: %s", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return results[0]
+}
+
+func TestJudge(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   string
+		input  string
+		limits Limits
+		want   string
+	}{
+		{"passed", "print(int(input()) * 2)", "21", Limits{}, StatusPassed},
+		{"wrong answer", "print(int(input()) + 2)", "21", Limits{}, StatusWrongAnswer},
+		{"runtime error", "raise SystemExit(3)", "", Limits{}, StatusRuntimeError},
+		{"compile error", "print(", "", Limits{}, StatusCompileError},
+		{"cpu time limit", "while True:\n    pass", "", Limits{Time: 500 * time.Millisecond}, StatusTimeLimit},
+		{"wall time limit", "import time\ntime.sleep(60)", "", Limits{Time: 500 * time.Millisecond}, StatusTimeLimit},
+		{"memory limit", "x = bytearray(512 << 20)", "", Limits{Memory: 64 << 20}, StatusMemoryLimit},
+	}
+
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			start := time.Now()
+			res := judge(t, false, v.code, Case{Input: v.input, Expected: "42"}, v.limits)
+			if res.Status != v.want {
+				t.Errorf("status %s, want %s: %s", res.Status, v.want, res.Message)
+			}
+			//runs past their limits are killed instead of left running
+			if elapsed := time.Since(start); elapsed > 10*time.Second {
+				t.Errorf("run took %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestJudgeOutputLimit(t *testing.T) {
+	res := judge(t, false, "while True:\n    print('x' * 1000)", Case{Expected: "x"}, Limits{})
+	if res.Status != StatusOutputLimit {
+		t.Fatalf("status %s, want %s", res.Status, StatusOutputLimit)
+	}
+	if len(res.Output) > maxMessage+len("...") {
+		t.Errorf("output of %d bytes is not cut", len(res.Output))
+	}
+}
+
+func TestJudgeIsolateNetwork(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	code := fmt.Sprintf(`import socket
+try:
+    socket.create_connection(("127.0.0.1", %d), timeout=2).close()
+    print("connected")
+except OSError:
+    print("blocked")`, port)
+
+	//the dial works without isolation, so a blocked dial is the work of the network namespace
+	if res := judge(t, false, code, Case{Expected: "connected"}, Limits{}); res.Status != StatusPassed {
+		t.Fatalf("without isolation: status %s, output %q", res.Status, res.Output)
+	}
+	if res := judge(t, true, code, Case{Expected: "blocked"}, Limits{}); res.Status != StatusPassed {
+		t.Errorf("with isolation: status %s, output %q %s", res.Status, res.Output, res.Message)
+	}
+}
+
+func TestJudgeIsolatePaths(t *testing.T) {
+	parent, err := ioutil.TempDir("", "hidden-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(parent)
+	secret := filepath.Join(parent, "secret")
+	cache := filepath.Join(parent, "cache")
+	probe := filepath.Join(os.TempDir(), filepath.Base(parent)+"-probe")
+	defer os.Remove(probe)
+	if err := ioutil.WriteFile(secret, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(cache, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	//the shared cache is inside a hidden path, it must not make its parent visible
+	r := New(config.SandboxConfiguration{Isolate: true, HiddenPaths: []string{parent}, GoCache: cache})
+	if r.goPath == "" {
+		t.Skip("go is not installed")
+	}
+	code := fmt.Sprintf(`package main
+
+import (
+	"fmt"
+	"io/ioutil"
+)
+
+func main() {
+	_, errSecret := ioutil.ReadFile(%q)
+	entries, _ := ioutil.ReadDir(%q)
+	errProbe := ioutil.WriteFile(%q, nil, 0644)
+	fmt.Println(errSecret != nil, len(entries), errProbe == nil)
+}`, secret, cache, probe)
+	results, err := r.Judge(LanguageGo, code, []Case{{Expected: "true 0 true"}}, Limits{Time: 5 * time.Second})
+	if err != nil && (strings.Contains(err.Error(), "operation not permitted") || strings.HasPrefix(err.Error(), "sandbox: ")) {
+		t.Skipf("namespaces are not available: %s", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Status != StatusPassed {
+		t.Errorf("status %s, output %q %s", results[0].Status, results[0].Output, results[0].Message)
+	}
+
+	//the code wrote in its own /tmp and the compiler in its overlay
+	if _, err := os.Stat(probe); err == nil {
+		t.Errorf("%s is written outside of the sandbox", probe)
+	}
+	if entries, _ := ioutil.ReadDir(cache); len(entries) > 0 {
+		t.Errorf("shared cache has %d entries written by the compiler, want none", len(entries))
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package sandbox
+
+// execute code can only be run in a sandbox on linux
+func (r *Runner) execute(dir string, args []string, env []string, stdin string, limits Limits, fileSize int64) (execution, error) {
+	return execution{}, ErrUnsupported
+}
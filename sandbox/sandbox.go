@@ -0,0 +1,314 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"okkybudiman/config"
+)
+
+// languages the code can be written in
+const (
+	LanguageGo     = "go"
+	LanguagePython = "python"
+)
+
+// status of a test case
+const (
+	StatusPassed       = "passed"
+	StatusWrongAnswer  = "wrong_answer"
+	StatusTimeLimit    = "time_limit"
+	StatusMemoryLimit  = "memory_limit"
+	StatusOutputLimit  = "output_limit"
+	StatusRuntimeError = "runtime_error"
+	StatusCompileError = "compile_error"
+)
+
+// limits of the runs, output beyond maxOutput fails the test case and messages are cut at maxMessage
+const (
+	defaultTimeLimit          = 2 * time.Second
+	defaultMemoryLimit        = 256 << 20
+	defaultCompileTimeLimit   = time.Minute
+	defaultCompileMemoryLimit = 2 << 30
+	maxOutput                 = 1 << 20
+	maxMessage                = 2048
+	// size of the files the code and the compiler can write, in bytes
+	maxFileSize        = 16 << 20
+	maxCompileFileSize = 1 << 30
+)
+
+// ErrUnsupported the platform cannot run code in a sandbox
+var ErrUnsupported = errors.New("sandbox is not supported on this platform")
+
+// Limits limits of a run, Time is the cpu time and Memory the data memory in bytes
+type Limits struct {
+	Time   time.Duration
+	Memory int64
+}
+
+// Case test case, the code reads Input from its standard input and must write Expected on its standard output
+type Case struct {
+	Input    string
+	Expected string
+}
+
+// Result result of a test case, Time is the cpu time used. Output is the start of the output of the code
+// and Message the start of its error output, or the compiler errors.
+type Result struct {
+	Status  string
+	Time    time.Duration
+	Output  string
+	Message string
+}
+
+// Runner compile and run code against test cases, each run is isolated and limited
+type Runner struct {
+	cfg    config.SandboxConfiguration
+	goPath string
+	goRoot string
+	python string
+}
+
+// execution outcome of a process run by execute
+type execution struct {
+	stdout         string
+	stderr         string
+	exitCode       int
+	timedOut       bool
+	outputExceeded bool
+	cpu            time.Duration
+}
+
+// New create a runner, the compilers of the languages which are not installed are reported by Judge
+func New(cfg config.SandboxConfiguration) *Runner {
+	r := &Runner{cfg: cfg}
+
+	if cfg.GoBinary == "" {
+		cfg.GoBinary = "go"
+	}
+	r.goPath, _ = exec.LookPath(cfg.GoBinary)
+	if r.goPath != "" {
+		r.goRoot = goRoot(r.goPath)
+	}
+
+	if cfg.PythonBinary == "" {
+		cfg.PythonBinary = "python3"
+	}
+	//python is often installed behind a shim which needs the environment of the server,
+	//the real interpreter is run instead
+	if path, err := exec.LookPath(cfg.PythonBinary); err == nil {
+		out, err := exec.Command(path, "-c", "import sys; print(sys.executable)").Output()
+		if err == nil && strings.TrimSpace(string(out)) != "" {
+			r.python = strings.TrimSpace(string(out))
+		}
+	}
+
+	return r
+}
+
+// IsSupportedLanguage check whether code can be written in language
+func IsSupportedLanguage(language string) bool {
+	return language == LanguageGo || language == LanguagePython
+}
+
+// Judge compile the code and run it against every case. The error is only set when the code could not be judged,
+// code which does not compile fails every case with StatusCompileError.
+func (r *Runner) Judge(language string, code string, cases []Case, limits Limits) ([]Result, error) {
+	if limits.Time <= 0 {
+		limits.Time = r.duration(r.cfg.TimeLimit, defaultTimeLimit)
+	}
+	if limits.Memory <= 0 {
+		limits.Memory = r.size(r.cfg.MemoryLimit, defaultMemoryLimit)
+	}
+	compileLimits := Limits{
+		Time:   r.duration(r.cfg.CompileTimeLimit, defaultCompileTimeLimit),
+		Memory: r.size(r.cfg.CompileMemoryLimit, defaultCompileMemoryLimit),
+	}
+
+	dir, err := ioutil.TempDir("", "sandbox-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	var source string
+	var compile, run []string
+	env := []string{"PATH=/usr/local/bin:/usr/bin:/bin", "HOME=" + dir, "TMPDIR=" + dir, "LANG=C.UTF-8"}
+	compileEnv := env
+
+	switch language {
+	case LanguageGo:
+		if r.goPath == "" {
+			return nil, fmt.Errorf("go is not installed")
+		}
+		cache := r.cfg.GoCache
+		if cache == "" {
+			cache = filepath.Join(dir, ".cache")
+		}
+		source = "main.go"
+		compile = []string{r.goPath, "build", "-o", "main", "main.go"}
+		run = []string{"./main"}
+		compileEnv = append(env,
+			"GOCACHE="+cache,
+			"GOPATH="+filepath.Join(dir, ".gopath"),
+			"GOROOT="+r.goRoot,
+			"GOPROXY=off",
+			"GOTOOLCHAIN=local",
+			"CGO_ENABLED=0",
+		)
+	case LanguagePython:
+		if r.python == "" {
+			return nil, fmt.Errorf("python is not installed")
+		}
+		source = "main.py"
+		compile = []string{r.python, "-I", "-m", "py_compile", "main.py"}
+		run = []string{r.python, "-I", "main.py"}
+	default:
+		return nil, fmt.Errorf("language %s is not supported", language)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, source), []byte(code), 0644); err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, len(cases))
+	compiled, err := r.execute(dir, compile, compileEnv, "", compileLimits, maxCompileFileSize)
+	if err != nil {
+		return nil, err
+	}
+	if compiled.exitCode != 0 || compiled.timedOut || compiled.outputExceeded {
+		message := cut(hidePath(compiled.stderr+compiled.stdout, dir), maxMessage)
+		if compiled.timedOut {
+			message = "compilation takes too long"
+		}
+		for k := range results {
+			results[k] = Result{Status: StatusCompileError, Message: message}
+		}
+		return results, nil
+	}
+
+	for k, v := range cases {
+		ran, err := r.execute(dir, run, env, v.Input, limits, maxFileSize)
+		if err != nil {
+			return nil, err
+		}
+		results[k] = result(ran, v, limits, dir)
+	}
+
+	return results, nil
+}
+
+// result grade the run of a case
+func result(ran execution, c Case, limits Limits, dir string) Result {
+	res := Result{
+		Time:    ran.cpu,
+		Output:  cut(ran.stdout, maxMessage),
+		Message: cut(hidePath(ran.stderr, dir), maxMessage),
+	}
+
+	switch {
+	case ran.outputExceeded:
+		res.Status = StatusOutputLimit
+	case ran.timedOut || ran.cpu > limits.Time:
+		res.Status = StatusTimeLimit
+	case ran.exitCode != 0 && isOutOfMemory(ran.stderr):
+		res.Status = StatusMemoryLimit
+	case ran.exitCode != 0:
+		res.Status = StatusRuntimeError
+	case normalizeOutput(ran.stdout) == normalizeOutput(c.Expected):
+		res.Status = StatusPassed
+	default:
+		res.Status = StatusWrongAnswer
+	}
+
+	return res
+}
+
+// normalizeOutput outputs are compared ignoring the spaces at the end of the lines and the empty lines at the end
+func normalizeOutput(output string) string {
+	lines := strings.Split(strings.Replace(output, "\r\n", "\n", -1), "\n")
+	for k, v := range lines {
+		lines[k] = strings.TrimRight(v, " \t")
+	}
+
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+// isOutOfMemory check whether a run failed allocating memory, as go and python report it
+func isOutOfMemory(stderr string) bool {
+	return strings.Contains(stderr, "out of memory") || strings.Contains(stderr, "MemoryError") ||
+		strings.Contains(stderr, "cannot allocate memory")
+}
+
+// hidePath remove the directory of the run from a message, the files are named as the candidate sees them
+func hidePath(message string, dir string) string {
+	return strings.Replace(message, dir+string(filepath.Separator), "", -1)
+}
+
+// goRoot the root of the go installation of the go binary
+func goRoot(goPath string) string {
+	if out, err := exec.Command(goPath, "env", "GOROOT").Output(); err == nil {
+		return strings.TrimSpace(string(out))
+	}
+
+	return filepath.Dir(filepath.Dir(goPath))
+}
+
+func (r *Runner) duration(milliseconds int, fallback time.Duration) time.Duration {
+	if milliseconds <= 0 {
+		return fallback
+	}
+
+	return time.Duration(milliseconds) * time.Millisecond
+}
+
+func (r *Runner) size(megabytes int, fallback int64) int64 {
+	if megabytes <= 0 {
+		return fallback
+	}
+
+	return int64(megabytes) << 20
+}
+
+func cut(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+
+	return text[:max] + "..."
+}
+
+// limitedBuffer buffer keeping the first max bytes written, exceeded is set and onExceed called once when more
+// is written
+type limitedBuffer struct {
+	data     []byte
+	max      int
+	exceeded bool
+	onExceed func()
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if len(b.data)+len(p) > b.max {
+		b.data = append(b.data, p[:b.max-len(b.data)]...)
+		if !b.exceeded {
+			b.exceeded = true
+			if b.onExceed != nil {
+				b.onExceed()
+			}
+		}
+		return len(p), nil
+	}
+	b.data = append(b.data, p...)
+
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	return string(b.data)
+}
@@ -0,0 +1,54 @@
+package srs
+
+import "time"
+
+// Recall a recorded review
+type Recall struct {
+	Quality int
+	At      time.Time
+}
+
+// Day reviews of a day, Retention is the share of the reviews recalled with a passing quality, 0 without review
+type Day struct {
+	Date      string  `json:"date"`
+	Reviews   int     `json:"reviews"`
+	Recalled  int     `json:"recalled"`
+	Retention float64 `json:"retention"`
+}
+
+// Retention group recalls by day over the days ending with the day of now, the oldest day first.
+// Recalls outside of the days are ignored.
+func Retention(recalls []Recall, days int, now time.Time) []Day {
+	if days < 1 {
+		return nil
+	}
+
+	first := StartOfDay(now).AddDate(0, 0, -days+1)
+	result := make([]Day, days)
+	for k := range result {
+		result[k].Date = first.AddDate(0, 0, k).Format("2006-01-02")
+	}
+
+	for _, v := range recalls {
+		at := StartOfDay(v.At.In(now.Location()))
+		if at.Before(first) {
+			continue
+		}
+		k := int(at.Sub(first).Hours()/24 + 0.5)
+		if k >= days {
+			continue
+		}
+		result[k].Reviews++
+		if v.Quality >= QualityPass {
+			result[k].Recalled++
+		}
+	}
+
+	for k, v := range result {
+		if v.Reviews > 0 {
+			result[k].Retention = float64(v.Recalled) / float64(v.Reviews)
+		}
+	}
+
+	return result
+}
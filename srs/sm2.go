@@ -0,0 +1,86 @@
+package srs
+
+import (
+	"math"
+	"time"
+)
+
+// bounds of the recall quality, answers with a quality below QualityPass are forgotten
+const (
+	QualityMin  = 0
+	QualityMax  = 5
+	QualityPass = 3
+)
+
+// ease factor of new cards and its lower bound
+const (
+	DefaultEase = 2.5
+	MinEase     = 1.3
+)
+
+// Card schedule of a review item, Interval is in days
+type Card struct {
+	Ease        float64
+	Interval    int
+	Repetitions int
+	Lapses      int
+	Due         time.Time
+}
+
+// NewCard create a card due at now
+func NewCard(now time.Time) Card {
+	return Card{Ease: DefaultEase, Due: now}
+}
+
+// Review schedule the card after it is recalled at now with quality, following SM-2.
+// A forgotten card starts again from the first interval, the ease factor is changed in both cases.
+func Review(card Card, quality int, now time.Time) Card {
+	if quality < QualityMin {
+		quality = QualityMin
+	}
+	if quality > QualityMax {
+		quality = QualityMax
+	}
+
+	if quality >= QualityPass {
+		switch card.Repetitions {
+		case 0:
+			card.Interval = 1
+		case 1:
+			card.Interval = 6
+		default:
+			card.Interval = int(math.Round(float64(card.Interval) * card.Ease))
+		}
+		card.Repetitions++
+	} else {
+		card.Repetitions = 0
+		card.Interval = 1
+		card.Lapses++
+	}
+
+	q := float64(QualityMax - quality)
+	card.Ease += 0.1 - q*(0.08+q*0.02)
+	if card.Ease < MinEase {
+		card.Ease = MinEase
+	}
+
+	card.Due = StartOfDay(now).AddDate(0, 0, card.Interval)
+	return card
+}
+
+// Lapse reschedule the card for now after it is answered wrongly outside of a review
+func Lapse(card Card, now time.Time) Card {
+	if card.Repetitions > 0 {
+		card.Lapses++
+	}
+	card.Repetitions = 0
+	card.Interval = 0
+	card.Due = now
+	return card
+}
+
+// StartOfDay midnight of the day of t in its location
+func StartOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
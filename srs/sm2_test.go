@@ -0,0 +1,107 @@
+package srs
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// review expected state of a card after a review of quality
+type review struct {
+	quality     int
+	interval    int
+	repetitions int
+	lapses      int
+	ease        float64
+}
+
+func TestReview(t *testing.T) {
+	tests := []struct {
+		name    string
+		reviews []review
+	}{
+		{"perfect recall", []review{
+			{5, 1, 1, 0, 2.6},
+			{5, 6, 2, 0, 2.7},
+			{5, 16, 3, 0, 2.8},
+			{5, 45, 4, 0, 2.9},
+		}},
+		{"recall keeping the ease", []review{
+			{4, 1, 1, 0, 2.5},
+			{4, 6, 2, 0, 2.5},
+			{4, 15, 3, 0, 2.5},
+			{4, 38, 4, 0, 2.5},
+		}},
+		{"hard recall", []review{
+			{3, 1, 1, 0, 2.36},
+			{3, 6, 2, 0, 2.22},
+			{3, 13, 3, 0, 2.08},
+			{3, 27, 4, 0, 1.94},
+		}},
+		{"forgotten after recalls", []review{
+			{5, 1, 1, 0, 2.6},
+			{5, 6, 2, 0, 2.7},
+			{5, 16, 3, 0, 2.8},
+			{2, 1, 0, 1, 2.48},
+			{4, 1, 1, 1, 2.48},
+			{4, 6, 2, 1, 2.48},
+			{1, 1, 0, 2, 1.94},
+		}},
+		{"ease bounded by its minimum", []review{
+			{0, 1, 0, 1, 1.7},
+			{0, 1, 0, 2, 1.3},
+			{0, 1, 0, 3, 1.3},
+			{3, 1, 1, 3, 1.3},
+			{3, 6, 2, 3, 1.3},
+			{3, 8, 3, 3, 1.3},
+		}},
+		{"quality out of bounds", []review{
+			{9, 1, 1, 0, 2.6},
+			{-4, 1, 0, 1, 1.8},
+		}},
+	}
+
+	now := time.Date(2026, 10, 19, 15, 30, 0, 0, time.UTC)
+	for _, test := range tests {
+		card := NewCard(now)
+		for k, v := range test.reviews {
+			card = Review(card, v.quality, now)
+			if card.Interval != v.interval || card.Repetitions != v.repetitions || card.Lapses != v.lapses ||
+				math.Abs(card.Ease-v.ease) > 1e-9 {
+				t.Errorf("%s, review %d: interval %d, repetitions %d, lapses %d, ease %v, want %d, %d, %d, %v", test.name, k+1,
+					card.Interval, card.Repetitions, card.Lapses, card.Ease, v.interval, v.repetitions, v.lapses, v.ease)
+			}
+		}
+	}
+}
+
+func TestReviewDue(t *testing.T) {
+	now := time.Date(2026, 10, 19, 23, 59, 0, 0, time.UTC)
+	card := Review(NewCard(now), 5, now)
+	if want := time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC); !card.Due.Equal(want) {
+		t.Errorf("due %s, want %s", card.Due, want)
+	}
+
+	card = Review(card, 5, now)
+	if want := time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC); !card.Due.Equal(want) {
+		t.Errorf("due %s, want %s", card.Due, want)
+	}
+}
+
+func TestLapse(t *testing.T) {
+	now := time.Date(2026, 10, 19, 15, 30, 0, 0, time.UTC)
+
+	card := Lapse(NewCard(now), now)
+	if card.Lapses != 0 || card.Interval != 0 || !card.Due.Equal(now) {
+		t.Errorf("new card lapsed: %+v", card)
+	}
+
+	card = Review(Review(card, 5, now), 5, now)
+	card = Lapse(card, now)
+	if card.Lapses != 1 || card.Repetitions != 0 || card.Interval != 0 || !card.Due.Equal(now) {
+		t.Errorf("recalled card lapsed: %+v", card)
+	}
+	if math.Abs(card.Ease-2.7) > 1e-9 {
+		t.Errorf("lapse changed the ease to %v", card.Ease)
+	}
+}
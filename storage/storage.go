@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"okkybudiman/config"
+)
+
+// ErrNotFound the object does not exist
+var ErrNotFound = errors.New("object not found")
+
+// Storage store of uploaded files, objects are identified by a key made of path segments separated by /
+type Storage interface {
+	// Put save an object of size bytes read from r, an object with the same key is replaced
+	Put(key string, r io.Reader, size int64, contentType string) error
+	// Get open an object, the caller must close it
+	Get(key string) (io.ReadCloser, error)
+	// Delete remove an object, removing a missing object is not an error
+	Delete(key string) error
+}
+
+// New create the storage of the configuration
+func New(cfg config.StorageConfiguration) (Storage, error) {
+	switch cfg.Type {
+	case "", "local":
+		return NewLocal(cfg.Path)
+	case "s3":
+		return NewS3(cfg.Endpoint, cfg.Region, cfg.Bucket, cfg.AccessKey, cfg.SecretKey, cfg.PathStyle)
+	}
+
+	return nil, fmt.Errorf("unknown storage type %s", cfg.Type)
+}
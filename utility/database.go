@@ -0,0 +1,14 @@
+package utils
+
+import "github.com/jinzhu/gorm"
+
+// ForUpdate lock the rows read by the query of db until the end of its transaction. Mysql and postgres lock the rows,
+// sqlite locks the whole database on write and mssql is left without lock hint.
+func ForUpdate(db *gorm.DB) *gorm.DB {
+	switch db.Dialect().GetName() {
+	case "mysql", "postgres":
+		return db.Set("gorm:query_option", "FOR UPDATE")
+	}
+
+	return db
+}
@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	dataModel "okkybudiman/data/model"
+	"strconv"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+)
+
+// policy of the html rendered from markdown written by admins, scripts, styles and event handlers are removed
+var markdownPolicy = bluemonday.UGCPolicy()
+
+// RenderMarkdown render markdown text as sanitized html, empty text renders as an empty string
+func RenderMarkdown(text string) string {
+	if text == "" {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	if err := goldmark.Convert([]byte(text), &buf); err != nil {
+		return ""
+	}
+
+	return markdownPolicy.Sanitize(buf.String())
+}
+
+// RenderContent render a text of a question written in format (plain, markdown or markdown_math) as sanitized html
+func RenderContent(text string, format string) string {
+	switch format {
+	case dataModel.QuestionFormatMarkdown:
+		return RenderMarkdown(text)
+	case dataModel.QuestionFormatMarkdownMath:
+		return RenderMarkdownMath(text)
+	}
+
+	return RenderPlain(text)
+}
+
+// RenderPlain render plain text as html, the text is escaped and line breaks are kept
+func RenderPlain(text string) string {
+	if text == "" {
+		return ""
+	}
+
+	return strings.Replace(html.EscapeString(text), "\n", "<br>\n", -1)
+}
+
+// RenderMarkdownMath render markdown text with LaTeX math as sanitized html. Inline math is written between $ and
+// display math between $$, it is left to the client (KaTeX or MathJax) in <span class="math inline">\(...\)</span>
+// and <span class="math display">\[...\]</span>. Math in code is not converted and \$ is a dollar sign.
+func RenderMarkdownMath(text string) string {
+	if text == "" {
+		return ""
+	}
+
+	segments := mathSegments(text)
+	if len(segments) == 0 {
+		return RenderMarkdown(text)
+	}
+
+	//math is replaced by placeholders markdown leaves as they are, and put back after the html is sanitized
+	prefix := "mathplaceholder"
+	for strings.Contains(text, prefix) {
+		prefix += "x"
+	}
+
+	var source strings.Builder
+	last := 0
+	for k, v := range segments {
+		source.WriteString(text[last:v.start])
+		source.WriteString(prefix + strconv.Itoa(k) + "z")
+		last = v.end
+	}
+	source.WriteString(text[last:])
+
+	rendered := RenderMarkdown(source.String())
+	for k := len(segments) - 1; k >= 0; k-- {
+		v := segments[k]
+		var math string
+		if v.display {
+			math = `<span class="math display">\[` + html.EscapeString(v.math) + `\]</span>`
+		} else {
+			math = `<span class="math inline">\(` + html.EscapeString(v.math) + `\)</span>`
+		}
+		rendered = strings.Replace(rendered, prefix+strconv.Itoa(k)+"z", math, -1)
+	}
+
+	return rendered
+}
+
+// ValidateMath check the math of markdown text: display math must be closed, and math must not be empty and
+// have balanced braces
+func ValidateMath(text string) error {
+	segments := mathSegments(text)
+	for _, v := range segments {
+		if strings.TrimSpace(v.math) == "" {
+			return fmt.Errorf("math at position %d is empty", v.start+1)
+		}
+		if !balancedBraces(v.math) {
+			return fmt.Errorf("math at position %d has unbalanced braces", v.start+1)
+		}
+	}
+
+	//an unclosed $$ is never a math segment, look for the ones left outside the segments and code
+	last := 0
+	for _, v := range append(segments, mathSegment{start: len(text), end: len(text)}) {
+		outside := stripCode(text[last:v.start])
+		if i := indexUnescaped(outside, "$$"); i >= 0 {
+			return fmt.Errorf("display math is not closed")
+		}
+		last = v.end
+	}
+
+	return nil
+}
+
+type mathSegment struct {
+	start   int
+	end     int
+	math    string
+	display bool
+}
+
+// mathSegments find the math of markdown text, skipping code spans and fenced code blocks
+func mathSegments(text string) []mathSegment {
+	var segments []mathSegment
+
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '\\':
+			i++
+
+		case text[i] == '`':
+			n := 1
+			for i+n < len(text) && text[i+n] == '`' {
+				n++
+			}
+			fence := strings.Repeat("`", n)
+			end := strings.Index(text[i+n:], fence)
+			if end < 0 {
+				i += n - 1
+				continue
+			}
+			i += n + end + n - 1
+
+		case strings.HasPrefix(text[i:], "$$"):
+			end := indexUnescaped(text[i+2:], "$$")
+			if end < 0 {
+				i++
+				continue
+			}
+			segments = append(segments, mathSegment{start: i, end: i + 2 + end + 2, math: text[i+2 : i+2+end], display: true})
+			i += 2 + end + 1
+
+		case text[i] == '$':
+			//as in pandoc, inline math does not start with a space, end with a space or come right before a digit,
+			//so prices such as $5 and $10 stay text
+			if i+1 >= len(text) || isSpace(text[i+1]) {
+				continue
+			}
+			end := -1
+			for j := i + 1; j < len(text); j++ {
+				if text[j] == '\\' {
+					j++
+					continue
+				}
+				if text[j] == '\n' && j+1 < len(text) && text[j+1] == '\n' {
+					break
+				}
+				if text[j] == '$' && !isSpace(text[j-1]) && (j+1 >= len(text) || !isDigit(text[j+1])) {
+					end = j
+					break
+				}
+			}
+			if end < 0 {
+				continue
+			}
+			segments = append(segments, mathSegment{start: i, end: end + 1, math: text[i+1 : end]})
+			i = end
+		}
+	}
+
+	return segments
+}
+
+// indexUnescaped the index of the first sub in text not preceded by a backslash, or -1
+func indexUnescaped(text string, sub string) int {
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(text[i:], sub) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// stripCode remove the code spans and fenced code blocks of markdown text
+func stripCode(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) {
+			b.WriteString(text[i : i+2])
+			i++
+			continue
+		}
+		if text[i] != '`' {
+			b.WriteByte(text[i])
+			continue
+		}
+
+		n := 1
+		for i+n < len(text) && text[i+n] == '`' {
+			n++
+		}
+		end := strings.Index(text[i+n:], strings.Repeat("`", n))
+		if end < 0 {
+			b.WriteString(text[i : i+n])
+			i += n - 1
+			continue
+		}
+		i += n + end + n - 1
+	}
+
+	return b.String()
+}
+
+func balancedBraces(math string) bool {
+	depth := 0
+	for i := 0; i < len(math); i++ {
+		switch math[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+
+	return depth == 0
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// Pagination offset pagination, sorting and search parameters of a list endpoint
+type Pagination struct {
+	Page    int
+	PerPage int
+	Sort    string
+	Order   string
+	Search  string
+
+	column string
+}
+
+// PaginationMeta pagination metadata returned by list endpoints
+type PaginationMeta struct {
+	Page      int    `json:"page"`
+	PerPage   int    `json:"per_page"`
+	Total     int    `json:"total"`
+	TotalPage int    `json:"total_page"`
+	Sort      string `json:"sort"`
+	Order     string `json:"order"`
+}
+
+// NewPagination read page, per_page, sort, order and q query parameters.
+// sortable maps every sort value accepted by the endpoint to its column, defaultSort must be one of them.
+func NewPagination(c *gin.Context, sortable map[string]string, defaultSort string) (Pagination, error) {
+	p := Pagination{
+		Page:    1,
+		PerPage: defaultPerPage,
+		Sort:    c.DefaultQuery("sort", defaultSort),
+		Order:   strings.ToLower(c.DefaultQuery("order", "asc")),
+		Search:  strings.TrimSpace(c.Query("q")),
+	}
+
+	if v := c.Query("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return p, fmt.Errorf("page must be a positive number")
+		}
+		p.Page = page
+	}
+
+	if v := c.Query("per_page"); v != "" {
+		perPage, err := strconv.Atoi(v)
+		if err != nil || perPage < 1 || perPage > maxPerPage {
+			return p, fmt.Errorf("per_page must be between 1 and %d", maxPerPage)
+		}
+		p.PerPage = perPage
+	}
+
+	column, ok := sortable[p.Sort]
+	if !ok {
+		var keys []string
+		for k := range sortable {
+			keys = append(keys, k)
+		}
+		return p, fmt.Errorf("sort must be one of %s", strings.Join(keys, ", "))
+	}
+	p.column = column
+
+	if p.Order != "asc" && p.Order != "desc" {
+		return p, fmt.Errorf("order must be asc or desc")
+	}
+
+	return p, nil
+}
+
+// Apply add order, offset and limit of the current page to the query
+func (p Pagination) Apply(db *gorm.DB) *gorm.DB {
+	return db.Order(p.column + " " + p.Order).Offset((p.Page - 1) * p.PerPage).Limit(p.PerPage)
+}
+
+// searchEscape escape character of the LIKE patterns, it is bound as a parameter since the databases do not read a
+// backslash in a string literal the same way
+const searchEscape = `\`
+
+// SearchPattern LIKE pattern of the search text, the wildcards and escape character typed are matched as they are
+func (p Pagination) SearchPattern() string {
+	escaped := strings.NewReplacer(searchEscape, searchEscape+searchEscape, "%", searchEscape+"%", "_", searchEscape+"_").Replace(p.Search)
+	return "%" + escaped + "%"
+}
+
+// SearchWhere filter the query on the rows with the search text in any of columns
+func (p Pagination) SearchWhere(db *gorm.DB, columns ...string) *gorm.DB {
+	var conditions []string
+	var args []interface{}
+	for _, v := range columns {
+		conditions = append(conditions, v+" LIKE ? ESCAPE ?")
+		args = append(args, p.SearchPattern(), searchEscape)
+	}
+
+	return db.Where(strings.Join(conditions, " OR "), args...)
+}
+
+// Meta build pagination metadata from the total rows matching the filters
+func (p Pagination) Meta(total int) PaginationMeta {
+	return PaginationMeta{
+		Page:      p.Page,
+		PerPage:   p.PerPage,
+		Total:     total,
+		TotalPage: (total + p.PerPage - 1) / p.PerPage,
+		Sort:      p.Sort,
+		Order:     p.Order,
+	}
+}
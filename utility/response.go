@@ -1,8 +1,13 @@
 package utils
 
 import (
+	"fmt"
+	"net/http"
+
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"golang.org/x/crypto/bcrypt"
+	validator "gopkg.in/go-playground/validator.v8"
 )
 
 func ResError(c *gin.Context, err error) {
@@ -21,3 +26,23 @@ func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// BindJSON bind the json body of the request into req.
+// On failure the request is aborted with the validation errors and false is returned.
+func BindJSON(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindWith(req, binding.JSON); err != nil {
+		var errors []string
+		ve, ok := err.(validator.ValidationErrors)
+		if ok {
+			for _, v := range ve {
+				errors = append(errors, fmt.Sprintf("%s is %s", v.Field, v.Tag))
+			}
+		} else {
+			errors = append(errors, err.Error())
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"errors": errors})
+		return false
+	}
+
+	return true
+}